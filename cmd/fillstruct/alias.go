@@ -0,0 +1,41 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "go/types"
+
+// namedOf returns the named type of t, also if t is an alias of it,
+// or nil.
+func namedOf(t types.Type) *types.Named {
+	named, _ := types.Unalias(t).(*types.Named)
+	return named
+}
+
+// isNamed reports whether t has a name: whether it is a named type or
+// an alias, e.g. type Tags = map[string]string, whose name is printed.
+func isNamed(t types.Type) bool {
+	_, alias := t.(*types.Alias)
+	return alias || namedOf(t) != nil
+}
+
+// setType sets the type of the literal of info to t. An alias keeps its
+// name, e.g. Money of type Money = decimal.Decimal, see typeName.
+func (info *litInfo) setType(t types.Type) {
+	info.alias, _ = t.(*types.Alias)
+	info.name = namedOf(t)
+	info.typ = t.Underlying()
+}
+
+// typeName returns the type by which the literal of info is referred to:
+// the alias it is written as, its named type, or nil if it has no name.
+func (info litInfo) typeName() types.Type {
+	if info.alias != nil {
+		return info.alias
+	}
+	if info.name != nil {
+		return info.name
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// findCall returns the innermost call enclosing pos, if it has no
+// arguments and calls a function with parameters, e.g. NewServer().
+func findCall(f *ast.File, info *types.Info, pos token.Pos) (*ast.CallExpr, *types.Signature) {
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	for _, n := range path {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			continue
+		}
+		if len(call.Args) > 0 || info.Types[call.Fun].IsType() {
+			return nil, nil
+		}
+		sig, ok := info.TypeOf(call.Fun).(*types.Signature)
+		if !ok || fixedParams(sig) == 0 {
+			return nil, nil
+		}
+		return call, sig
+	}
+	return nil, nil
+}
+
+// fixedParams returns the number of parameters of sig,
+// without the final variadic one.
+func fixedParams(sig *types.Signature) int {
+	if sig.Variadic() {
+		return sig.Params().Len() - 1
+	}
+	return sig.Params().Len()
+}
+
+// fillCall returns the output for the call, which is filled with the
+// zero value of every parameter, e.g. NewServer(Config{...}, nil).
+// Variadic parameters are left out.
+func fillCall(pkg *packages.Package, importNames map[string]string, call *ast.CallExpr, sig *types.Signature, start, end int, opts options) (output, error) {
+	f := newFiller(pkg.Types, importNames, opts)
+	fun := copyExpr(call.Fun)
+	f.fixExprPos(fun)
+	newcall := &ast.CallExpr{Fun: fun, Lparen: f.pos}
+	for i := 0; i < fixedParams(sig); i++ {
+		param := sig.Params().At(i)
+		f.path = []string{param.Name()}
+		if param.Name() == "" || param.Name() == "_" {
+			f.path[0] = fmt.Sprintf("arg%d", i)
+		}
+		arg := f.zero(litInfo{typ: param.Type()}, make([]types.Type, 0, 8))
+		if arg == nil {
+			return output{}, fmt.Errorf("cannot create a value for parameter %d of type %s", i, param.Type())
+		}
+		newcall.Args = append(newcall.Args, arg)
+	}
+	newcall.Rparen = f.pos
+
+	fi := fillInfo{
+		fset:        pkg.Fset,
+		pkg:         pkg.Types,
+		importNames: importNames,
+		existing:    make(map[string]bool),
+	}
+	return prepareOutput(newcall, f.lines, start, end, fi, opts)
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// checkCgo type-checks the original files of the cgo packages in pkgs
+// again. For a package importing "C", go/packages type-checks the files
+// generated by cgo instead, whose names and offsets differ, such that no
+// literal would be found in the original files. References to package C
+// are faked, i.e. they have invalid types, but everything else has the
+// types of the package.
+func checkCgo(cfg *packages.Config, pkgs []*packages.Package) {
+	for _, pkg := range pkgs {
+		if usesCgo(pkg) && pkg.Types != nil {
+			checkFiles(cfg, pkg)
+		}
+	}
+}
+
+// checkFiles parses the files of pkg again, from the overlay of cfg or
+// from disk, and type-checks them with the packages imported by pkg. It
+// reports false and keeps pkg as it is if a file cannot be read or if
+// it imports a package which pkg does not import. The replaced files are
+// removed from the file set of cfg, which would otherwise grow with each
+// reload of -watch.
+func checkFiles(cfg *packages.Config, pkg *packages.Package) bool {
+	var files []*ast.File
+	for _, name := range pkg.GoFiles {
+		var src interface{}
+		if b, ok := cfg.Overlay[name]; ok {
+			src = b
+		}
+		// Like go/packages, keep what parses of files with errors.
+		f, _ := parser.ParseFile(cfg.Fset, name, src, parser.AllErrors|parser.ParseComments)
+		if f == nil {
+			return false
+		}
+		for _, imp := range f.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err == nil && path != "C" && path != "unsafe" && pkg.Imports[path] == nil {
+				return false
+			}
+		}
+		files = append(files, f)
+	}
+
+	info := newTypesInfo()
+	conf := types.Config{
+		Importer:    importerOf(pkg),
+		FakeImportC: true,
+		Error:       func(error) {},
+		Sizes:       pkg.TypesSizes,
+	}
+	tpkg := types.NewPackage(pkg.PkgPath, pkg.Name)
+	types.NewChecker(&conf, cfg.Fset, tpkg, info).Files(files)
+	for _, f := range pkg.Syntax {
+		if file := cfg.Fset.File(f.Pos()); file != nil {
+			cfg.Fset.RemoveFile(file)
+		}
+	}
+	pkg.Syntax, pkg.Types, pkg.TypesInfo = files, tpkg, info
+	return true
+}
+
+// newTypesInfo returns a types.Info recording everything that
+// go/packages records.
+func newTypesInfo() *types.Info {
+	return &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Instances:  make(map[*ast.Ident]types.Instance),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+}
+
+// usesCgo reports whether pkg was compiled from files generated by cgo,
+// i.e. whether one of its files is not compiled as it is.
+func usesCgo(pkg *packages.Package) bool {
+	compiled := make(map[string]bool, len(pkg.CompiledGoFiles))
+	for _, name := range pkg.CompiledGoFiles {
+		compiled[name] = true
+	}
+	for _, name := range pkg.GoFiles {
+		if !compiled[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// importerOf returns an importer of the packages imported by pkg.
+func importerOf(pkg *packages.Package) types.Importer {
+	return importerFunc(func(path string) (*types.Package, error) {
+		if path == "unsafe" {
+			return types.Unsafe, nil
+		}
+		if imp := pkg.Imports[path]; imp != nil && imp.Types != nil {
+			return imp.Types, nil
+		}
+		return nil, fmt.Errorf("could not import %s", path)
+	})
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
@@ -0,0 +1,131 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// elementComment contains the comments attached to
+// an element of an existing literal.
+type elementComment struct {
+	lead  []string // comments on the lines above the element
+	trail string   // comments on the line of the end of the element
+}
+
+// elementComments returns the comments attached to the elements of
+// the literal lit and its nested literals, indexed by the paths of
+// the elements, see elementPath. comments are the comments of the
+// file containing lit, whose positions must not have been changed.
+func elementComments(fset *token.FileSet, comments []*ast.CommentGroup, lit *ast.CompositeLit) map[string]elementComment {
+	line := func(pos token.Pos) int { return fset.PositionFor(pos, false).Line }
+
+	res := make(map[string]elementComment)
+	var walk func(e ast.Expr, path string)
+	walk = func(e ast.Expr, path string) {
+		if u, ok := e.(*ast.UnaryExpr); ok {
+			e = u.X
+		}
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok {
+			return
+		}
+		prev := lit.Lbrace
+		for i, elt := range lit.Elts {
+			p, v := elementPath(path, i, elt)
+			start := elt.Pos()
+			if !start.IsValid() {
+				// The key was added by -keyify.
+				start = v.Pos()
+			}
+			next := lit.Rbrace
+			if i+1 < len(lit.Elts) {
+				next = lit.Elts[i+1].Pos()
+			}
+
+			var c elementComment
+			for _, cg := range comments {
+				switch {
+				case cg.Pos() > prev && line(cg.Pos()) > line(prev) && line(cg.End()) < line(start):
+					for _, com := range cg.List {
+						c.lead = append(c.lead, com.Text)
+					}
+				case cg.Pos() >= elt.End() && cg.Pos() < next && line(cg.Pos()) == line(elt.End()):
+					for _, com := range cg.List {
+						c.trail = strings.TrimSpace(c.trail + " " + com.Text)
+					}
+				}
+			}
+			if len(c.lead) > 0 || c.trail != "" {
+				res[p] = c
+			}
+			prev = elt.End()
+			walk(v, p)
+		}
+	}
+	walk(lit, "")
+	return res
+}
+
+// restoreComments adds the comments of the existing elements to the
+// literal in code. Leading comments are only added to elements which
+// start a line, trailing comments only to elements which end one.
+func restoreComments(code string, comments map[string]elementComment) (string, error) {
+	fset, expr, shift, err := parseCode(code)
+	if err != nil {
+		return "", err
+	}
+	src := strings.Repeat("_", shift) + code
+
+	type insertion struct {
+		offset int
+		text   string
+	}
+	var ins []insertion
+	var walk func(e ast.Expr, path string)
+	walk = func(e ast.Expr, path string) {
+		if u, ok := e.(*ast.UnaryExpr); ok {
+			e = u.X
+		}
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok {
+			return
+		}
+		for i, elt := range lit.Elts {
+			p, v := elementPath(path, i, elt)
+			if c, ok := comments[p]; ok {
+				start := fset.Position(elt.Pos()).Offset
+				lineStart := strings.LastIndexByte(src[:start], '\n') + 1
+				if indent := src[lineStart:start]; len(c.lead) > 0 && strings.TrimSpace(indent) == "" {
+					var buf strings.Builder
+					for _, com := range c.lead {
+						buf.WriteString(com + "\n" + indent)
+					}
+					ins = append(ins, insertion{start, buf.String()})
+				}
+
+				end := fset.Position(elt.End()).Offset
+				lineEnd := len(src)
+				if j := strings.IndexByte(src[end:], '\n'); j >= 0 {
+					lineEnd = end + j
+				}
+				if rest := strings.TrimSpace(src[end:lineEnd]); c.trail != "" && (rest == "" || rest == ",") {
+					ins = append(ins, insertion{lineEnd, " " + c.trail})
+				}
+			}
+			walk(v, p)
+		}
+	}
+	walk(expr, "")
+
+	sort.SliceStable(ins, func(i, j int) bool { return ins[i].offset > ins[j].offset })
+	for _, in := range ins {
+		src = src[:in.offset] + in.text + src[in.offset:]
+	}
+	return src[shift:], nil
+}
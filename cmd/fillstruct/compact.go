@@ -0,0 +1,36 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// compact returns the literal n printed on a single line, e.g.
+// Point{X: 0, Y: 0}, if it fits within width columns. The positions
+// of n are moved to the first line of fset.
+func compact(n ast.Node, fset *token.FileSet, width int) (string, bool) {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return "", false
+	}
+	f := filler{pos: 1, flat: true}
+	f.fixExprPos(e)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, e); err != nil {
+		return "", false
+	}
+	line := buf.String()
+	if strings.Contains(line, "\n") || utf8.RuneCountInString(line) > width {
+		return "", false
+	}
+	return line, true
+}
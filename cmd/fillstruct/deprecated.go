@@ -0,0 +1,46 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// deprecations reports fields whose documentation
+// marks them as deprecated, see isDeprecated.
+type deprecations struct {
+	fset  *token.FileSet
+	files syntaxFiles // the files declaring the fields
+}
+
+func newDeprecations(fset *token.FileSet, files syntaxFiles) *deprecations {
+	return &deprecations{fset: fset, files: files}
+}
+
+// isDeprecated reports whether the doc comment or the line comment of
+// the field v contains a paragraph starting with "Deprecated:". It is
+// false for a nil receiver.
+func (d *deprecations) isDeprecated(v *types.Var) bool {
+	if d == nil {
+		return false
+	}
+	field := d.files.findField(d.fset, v.Pos())
+	if field == nil {
+		return false
+	}
+	for _, cg := range []*ast.CommentGroup{field.Doc, field.Comment} {
+		paragraphStart := true
+		for _, line := range strings.Split(cg.Text(), "\n") {
+			if paragraphStart && strings.HasPrefix(line, "Deprecated:") {
+				return true
+			}
+			paragraphStart = strings.TrimSpace(line) == ""
+		}
+	}
+	return false
+}
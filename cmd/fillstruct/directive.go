@@ -0,0 +1,99 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// ignoreDirective marks fields and types which are never filled,
+// e.g. mutexes or internal bookkeeping fields.
+const ignoreDirective = "//fillstruct:ignore"
+
+// directives reports fields and types annotated
+// with ignoreDirective, see isIgnored.
+type directives struct {
+	fset  *token.FileSet
+	files syntaxFiles // the files declaring the fields and types
+}
+
+// newDirectives returns the directives of files, or nil if none of them
+// contains ignoreDirective.
+func newDirectives(fset *token.FileSet, files syntaxFiles) *directives {
+	for _, f := range files {
+		if hasIgnore(f.Comments...) {
+			return &directives{fset: fset, files: files}
+		}
+	}
+	return nil
+}
+
+// isIgnored reports whether the field v or its type, or the type
+// its pointer type points to, is annotated with //fillstruct:ignore
+// in its doc comment or line comment. It is false for a nil receiver.
+func (d *directives) isIgnored(v *types.Var) bool {
+	if d == nil {
+		return false
+	}
+	if field := d.files.findField(d.fset, v.Pos()); field != nil && hasIgnore(field.Doc, field.Comment) {
+		return true
+	}
+	t := v.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n := namedOf(t)
+	if n == nil {
+		return false
+	}
+	return hasIgnore(d.files.typeDocs(d.fset, n.Obj().Pos())...)
+}
+
+// hasIgnore reports whether one of the comment groups contains
+// ignoreDirective, optionally followed by a reason.
+func hasIgnore(groups ...*ast.CommentGroup) bool {
+	for _, cg := range groups {
+		if cg == nil {
+			continue
+		}
+		for _, c := range cg.List {
+			if c.Text == ignoreDirective || strings.HasPrefix(c.Text, ignoreDirective+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeDocs returns the doc and line comments of the type declared
+// at pos, including the doc comment of an ungrouped declaration.
+func (files syntaxFiles) typeDocs(fset *token.FileSet, pos token.Pos) []*ast.CommentGroup {
+	f := files[fset.File(pos)]
+	if f == nil {
+		return nil
+	}
+
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Pos() != pos {
+				continue
+			}
+			docs := []*ast.CommentGroup{ts.Doc, ts.Comment}
+			if !gd.Lparen.IsValid() {
+				docs = append(docs, gd.Doc)
+			}
+			return docs
+		}
+	}
+	return nil
+}
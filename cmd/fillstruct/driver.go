@@ -0,0 +1,31 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// packagesDriver returns the external driver which go/packages uses
+// instead of the go command to load packages with env, e.g. the one of
+// Bazel, or the empty string if there is none. Like go/packages, it
+// looks at GOPACKAGESDRIVER, which may be set to off, and otherwise for
+// gopackagesdriver in PATH.
+func packagesDriver(env []string) string {
+	driver := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GOPACKAGESDRIVER=") {
+			driver = strings.TrimPrefix(kv, "GOPACKAGESDRIVER=")
+		}
+	}
+	if driver == "off" {
+		return ""
+	}
+	if driver == "" {
+		driver, _ = exec.LookPath("gopackagesdriver")
+	}
+	return driver
+}
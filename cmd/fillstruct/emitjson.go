@@ -0,0 +1,426 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonObject is a JSON object whose members keep their order.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value interface{}
+
+	// The fields of structs compete for their names like with
+	// encoding/json, see set.
+	depth    int  // of embedded structs
+	tagged   bool // named by a json tag
+	omitted  bool // e.g. empty with omitempty
+	conflict bool // with another field of the same depth
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// set adds the member m to o. Like with encoding/json, the field with the
+// smallest depth of embedded structs takes precedence. Of several fields
+// of the same depth, the only one named by a json tag does; otherwise all
+// of them are left out.
+func (o jsonObject) set(m jsonMember) jsonObject {
+	for i := range o {
+		if o[i].key != m.key {
+			continue
+		}
+		switch {
+		case m.depth < o[i].depth, m.depth == o[i].depth && m.tagged && !o[i].tagged:
+			o[i] = m
+		case m.depth == o[i].depth && m.tagged == o[i].tagged:
+			o[i].conflict = true
+		}
+		return o
+	}
+	return append(o, m)
+}
+
+// jsonExample returns the JSON document which encoding/json produces for
+// the value of type t of the filled literal e, see -emit-json. Values
+// only known at run time, e.g. variables or constants, are encoded as
+// zero values, and values of types with MarshalJSON or MarshalText
+// methods, except for time.Time, as null.
+func jsonExample(e ast.Expr, t types.Type) (json.RawMessage, error) {
+	v, ok := jsonValue(e, t)
+	if !ok {
+		return nil, fmt.Errorf("cannot encode %s as JSON", t)
+	}
+	b, err := json.Marshal(v)
+	return json.RawMessage(b), err
+}
+
+// jsonValue returns the value of the expression e of type t to encode
+// as JSON, or false if encoding/json cannot encode it, e.g. a channel.
+// If e is nil or unknown, the zero value of t is returned.
+func jsonValue(e ast.Expr, t types.Type) (interface{}, bool) {
+	if p, ok := e.(*ast.ParenExpr); ok {
+		e = p.X
+	}
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		e = u.X
+	}
+	lit, _ := e.(*ast.CompositeLit)
+	if id, ok := e.(*ast.Ident); ok && id.Name == "nil" {
+		e = nil
+	}
+
+	if n := namedOf(t); n != nil && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == "time" && n.Obj().Name() == "Time" {
+		return "0001-01-01T00:00:00Z", true
+	}
+	if hasMarshaler(t) {
+		return nil, true
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return basicJSON(e, u)
+	case *types.Pointer:
+		if e == nil {
+			return nil, true
+		}
+		return jsonValue(e, u.Elem())
+	case *types.Struct:
+		return structJSON(lit, u), true
+	case *types.Slice:
+		if lit == nil {
+			return nil, true
+		}
+		if b, ok := u.Elem().Underlying().(*types.Basic); ok && b.Kind() == types.Uint8 {
+			var buf []byte
+			for _, elt := range lit.Elts {
+				v, _ := basicJSON(elt, b)
+				n, _ := v.(uint64)
+				buf = append(buf, byte(n))
+			}
+			return base64.StdEncoding.EncodeToString(buf), true
+		}
+		return sequenceJSON(lit, u.Elem(), 0)
+	case *types.Array:
+		return sequenceJSON(lit, u.Elem(), u.Len())
+	case *types.Map:
+		if lit == nil {
+			return nil, true
+		}
+		return mapJSON(lit, u)
+	case *types.Interface:
+		if e == nil {
+			return nil, true
+		}
+		return untypedJSON(e), true
+	default:
+		// Channels, functions and unsafe.Pointer.
+		return nil, false
+	}
+}
+
+// hasMarshaler reports whether values of type t encode themselves, with
+// a MarshalJSON or MarshalText method.
+func hasMarshaler(t types.Type) bool {
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		return false
+	}
+	mset := types.NewMethodSet(types.NewPointer(t))
+	return mset.Lookup(nil, "MarshalJSON") != nil || mset.Lookup(nil, "MarshalText") != nil
+}
+
+// basicJSON returns the value of the literal e of the basic type t.
+func basicJSON(e ast.Expr, t *types.Basic) (interface{}, bool) {
+	text := ""
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		text = e.Value
+	case *ast.Ident:
+		text = e.Name
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.BasicLit); ok && e.Op == token.SUB {
+			text = "-" + lit.Value
+		}
+	}
+	info := t.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		return text == "true", true
+	case info&types.IsString != 0:
+		s, _ := strconv.Unquote(text)
+		return s, true
+	case info&types.IsInteger != 0 && strings.HasPrefix(text, "'"):
+		s, _ := strconv.Unquote(text)
+		for _, r := range s {
+			return int64(r), true
+		}
+		return int64(0), true
+	case info&types.IsUnsigned != 0:
+		n, _ := strconv.ParseUint(text, 0, 64)
+		return n, true
+	case info&types.IsInteger != 0:
+		n, _ := strconv.ParseInt(text, 0, 64)
+		return n, true
+	case t.Kind() == types.Float32:
+		f, _ := strconv.ParseFloat(text, 32)
+		return float32(f), true
+	case info&types.IsFloat != 0:
+		f, _ := strconv.ParseFloat(text, 64)
+		return f, true
+	default:
+		// Complex numbers and unsafe.Pointer.
+		return nil, false
+	}
+}
+
+// untypedJSON returns the value of the untyped literal e, e.g. of an
+// interface field filled with -from, or nil.
+func untypedJSON(e ast.Expr) interface{} {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.STRING:
+			s, _ := strconv.Unquote(e.Value)
+			return s
+		case token.INT:
+			n, _ := strconv.ParseInt(e.Value, 0, 64)
+			return n
+		case token.FLOAT:
+			f, _ := strconv.ParseFloat(e.Value, 64)
+			return f
+		}
+	case *ast.Ident:
+		if e.Name == "true" || e.Name == "false" {
+			return e.Name == "true"
+		}
+	}
+	return nil
+}
+
+// structJSON returns the JSON object of the struct literal lit of type s,
+// with the names and options of the json tags of the fields. Fields of
+// embedded structs without a name are promoted, like encoding/json does.
+// A nil literal is the zero value.
+func structJSON(lit *ast.CompositeLit, s *types.Struct) jsonObject {
+	obj := jsonObject{}
+	for _, m := range structMembers(lit, s, 0, nil) {
+		if !m.omitted && !m.conflict {
+			obj = append(obj, m)
+		}
+	}
+	return obj
+}
+
+// structMembers returns the members of the JSON object of the struct
+// literal lit of type s, whose fields have the given depth, including
+// the omitted and conflicting ones, see set. Embedded types are only
+// followed once on a path, so embedded pointers cannot recurse.
+func structMembers(lit *ast.CompositeLit, s *types.Struct, depth int, path []types.Type) jsonObject {
+	values := make(map[string]ast.Expr)
+	if lit != nil {
+		for i, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if id, ok := kv.Key.(*ast.Ident); ok {
+					values[id.Name] = kv.Value
+				}
+			} else if i < s.NumFields() {
+				values[s.Field(i).Name()] = elt
+			}
+		}
+	}
+
+	obj := jsonObject{}
+	for i := 0; i < s.NumFields(); i++ {
+		field := s.Field(i)
+		tag := reflect.StructTag(s.Tag(i)).Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		value := values[field.Name()]
+
+		if field.Embedded() && name == "" {
+			t := field.Type()
+			isNil := false
+			if p, ok := t.(*types.Pointer); ok {
+				isNil = value == nil // the fields of nil pointers are left out
+				t = p.Elem()
+			}
+			if es, ok := t.Underlying().(*types.Struct); ok && !hasMarshaler(t) {
+				if onPath(path, t) {
+					continue
+				}
+				if u, ok := value.(*ast.UnaryExpr); ok && u.Op == token.AND {
+					value = u.X
+				}
+				elit, _ := value.(*ast.CompositeLit)
+				for _, m := range structMembers(elit, es, depth+1, append(path, t)) {
+					m.omitted = m.omitted || isNil
+					obj = obj.set(m)
+				}
+				continue
+			}
+		}
+		if !field.Exported() {
+			continue
+		}
+		m := jsonMember{key: name, depth: depth, tagged: name != ""}
+		if name == "" {
+			m.key = field.Name()
+		}
+		v, ok := jsonValue(value, field.Type())
+		m.omitted = !ok || hasOption(opts, "omitempty") && isEmptyJSON(v, field.Type())
+		if _, basic := field.Type().Underlying().(*types.Basic); basic && hasOption(opts, "string") && !m.omitted {
+			b, err := json.Marshal(v)
+			m.omitted = err != nil
+			v = string(b)
+		}
+		m.value = v
+		obj = obj.set(m)
+	}
+	return obj
+}
+
+// onPath reports whether t is one of the types on path.
+func onPath(path []types.Type, t types.Type) bool {
+	for _, p := range path {
+		if types.Identical(p, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOption reports whether the comma-separated options of a json tag
+// contain opt.
+func hasOption(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyJSON reports whether the value v of type t is left out with
+// the omitempty option: false, 0, "", nil and empty arrays, slices and
+// maps, but not structs.
+func isEmptyJSON(v interface{}, t types.Type) bool {
+	if _, ok := t.Underlying().(*types.Struct); ok {
+		return false
+	}
+	switch v := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case string:
+		return v == ""
+	case int64:
+		return v == 0
+	case uint64:
+		return v == 0
+	case float32:
+		return v == 0
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case jsonObject:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+// sequenceJSON returns the elements of the slice or array literal lit
+// with elements of type elem, and zero values up to the length n of an
+// array.
+func sequenceJSON(lit *ast.CompositeLit, elem types.Type, n int64) (interface{}, bool) {
+	vals := []interface{}{}
+	if lit != nil {
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			v, ok := jsonValue(elt, elem)
+			if !ok {
+				return nil, false
+			}
+			vals = append(vals, v)
+		}
+	}
+	for int64(len(vals)) < n {
+		v, ok := jsonValue(nil, elem)
+		if !ok {
+			return nil, false
+		}
+		vals = append(vals, v)
+	}
+	return vals, true
+}
+
+// mapJSON returns the JSON object of the map literal lit of type t. Like
+// with encoding/json, the keys are sorted and integer keys are quoted.
+func mapJSON(lit *ast.CompositeLit, t *types.Map) (interface{}, bool) {
+	if _, ok := t.Key().Underlying().(*types.Basic); !ok || hasMarshaler(t.Key()) {
+		return nil, false
+	}
+	obj := jsonObject{}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		k, ok := jsonValue(kv.Key, t.Key())
+		if !ok {
+			return nil, false
+		}
+		switch k.(type) {
+		case string, int64, uint64:
+		default:
+			return nil, false // e.g. bool or float keys
+		}
+		v, ok := jsonValue(kv.Value, t.Elem())
+		if !ok {
+			return nil, false
+		}
+		obj = obj.set(jsonMember{key: fmt.Sprint(k), value: v})
+	}
+	sort.SliceStable(obj, func(i, j int) bool { return obj[i].key < obj[j].key })
+	return obj, true
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings of offsets and columns, selected with the -encoding flag.
+// The names are the position encodings of the Language Server Protocol.
+const (
+	encodingUTF8  = "utf-8"  // bytes, like go/token
+	encodingUTF16 = "utf-16" // UTF-16 code units, the default of LSP clients
+	encodingUTF32 = "utf-32" // Unicode code points
+)
+
+func validEncoding(encoding string) bool {
+	switch encoding {
+	case "", encodingUTF8, encodingUTF16, encodingUTF32:
+		return true
+	default:
+		return false
+	}
+}
+
+// isBytes reports whether offsets in encoding are byte offsets.
+func isBytes(encoding string) bool {
+	return encoding == "" || encoding == encodingUTF8
+}
+
+// needsEncoding reports whether the byte offsets in src differ from the
+// offsets in encoding: if encoding does not count bytes or src starts
+// with a byte order mark, which is not counted.
+func needsEncoding(src []byte, encoding string) bool {
+	return !isBytes(encoding) || bomLen(src) > 0
+}
+
+// units returns the length of b in the units of encoding.
+func units(b []byte, encoding string) int {
+	if isBytes(encoding) {
+		return len(b)
+	}
+	n := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		if encoding == encodingUTF16 && utf16.RuneLen(r) == 2 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// byteOffset returns the byte offset in src of the offset off in the
+// units of encoding, not counting a byte order mark. An offset inside
+// of a character, e.g. between the two UTF-16 code units of an emoji,
+// is the start of the character.
+func byteOffset(src []byte, off int, encoding string) (int, error) {
+	start := bomLen(src)
+	if isBytes(encoding) {
+		return start + off, nil
+	}
+	n := 0
+	for i := start; i < len(src); {
+		_, size := utf8.DecodeRune(src[i:])
+		next := n + units(src[i:i+size], encoding)
+		if off < next {
+			return i, nil
+		}
+		i += size
+		n = next
+	}
+	if off == n {
+		return len(src), nil
+	}
+	return 0, fmt.Errorf("file size (%d %s units) is smaller than given offset (%d)", n, encoding, off)
+}
+
+// encodedOffset returns the offset of the byte offset off in src
+// in the units of encoding, not counting a byte order mark.
+func encodedOffset(src []byte, off int, encoding string) int {
+	start := bomLen(src)
+	if off < start {
+		return 0
+	}
+	return units(src[start:off], encoding)
+}
+
+// encodedCol returns the 1-based column of the byte offset off in src
+// in the units of encoding, not counting a byte order mark.
+func encodedCol(src []byte, off int, encoding string) int {
+	start := bytes.LastIndexByte(src[:off], '\n') + 1
+	if start == 0 {
+		start = min(bomLen(src), off)
+	}
+	return units(src[start:off], encoding) + 1
+}
+
+// encodePositions returns a copy of outs whose offsets and columns are
+// in the units of encoding instead of bytes, see needsEncoding. The
+// offsets must be valid in src, see setRegions.
+func encodePositions(src []byte, outs []output, encoding string) []output {
+	if !needsEncoding(src, encoding) {
+		return outs
+	}
+	encoded := make([]output, len(outs))
+	for i, out := range outs {
+		out.StartCol = encodedCol(src, out.Start, encoding)
+		out.EndCol = encodedCol(src, out.End, encoding)
+		out.Start = encodedOffset(src, out.Start, encoding)
+		out.End = encodedOffset(src, out.End, encoding)
+		if out.Candidates != nil {
+			cands := make([]candidate, len(out.Candidates))
+			for j, c := range out.Candidates {
+				c.Start = encodedOffset(src, c.Start, encoding)
+				c.End = encodedOffset(src, c.End, encoding)
+				cands[j] = c
+			}
+			out.Candidates = cands
+		}
+		encoded[i] = out
+	}
+	return encoded
+}
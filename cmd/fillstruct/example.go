@@ -0,0 +1,174 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readExample reads an example JSON or YAML document from the given file.
+// Objects are decoded as map[string]interface{}, arrays as []interface{}
+// and numbers as json.Number.
+func readExample(filename string) (interface{}, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("invalid YAML document %s: %v", filename, err)
+		}
+		return normalizeYAML(v), nil
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("invalid JSON document %s: %v", filename, err)
+		}
+		return v, nil
+	}
+}
+
+// normalizeYAML converts the values of a decoded YAML document
+// to the representation used for JSON documents.
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeYAML(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeYAML(e)
+		}
+		return v
+	case int:
+		return json.Number(strconv.Itoa(v))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	default:
+		return v
+	}
+}
+
+// fieldValue returns the value of the i-th field of the struct s in
+// the example object v. The field is looked up by its json and yaml
+// tags first and then by its name, ignoring case.
+func fieldValue(v interface{}, s *types.Struct, i int) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	field := s.Field(i)
+	tag := reflect.StructTag(s.Tag(i))
+	for _, key := range []string{"json", "yaml"} {
+		name := strings.Split(tag.Get(key), ",")[0]
+		if name == "-" {
+			return nil
+		}
+		if e, ok := obj[name]; name != "" && ok {
+			return e
+		}
+	}
+	if e, ok := obj[field.Name()]; ok {
+		return e
+	}
+	for k, e := range obj {
+		if strings.EqualFold(k, field.Name()) {
+			return e
+		}
+	}
+	return nil
+}
+
+// basicValue returns the Go literal for the example value v of the
+// basic type t, or false if v is not a valid t, e.g. if it overflows t.
+func basicValue(t *types.Basic, v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case bool:
+		if t.Info()&types.IsBoolean != 0 {
+			return strconv.FormatBool(v), true
+		}
+	case string:
+		if t.Info()&types.IsString != 0 {
+			return strconv.Quote(v), true
+		}
+	case json.Number:
+		s := v.String()
+		switch {
+		case t.Info()&types.IsUnsigned != 0:
+			if _, err := strconv.ParseUint(s, 10, bitSize(t)); err == nil {
+				return s, true
+			}
+		case t.Info()&types.IsInteger != 0:
+			if _, err := strconv.ParseInt(s, 10, bitSize(t)); err == nil {
+				return s, true
+			}
+		case t.Info()&types.IsFloat != 0:
+			if _, err := strconv.ParseFloat(s, bitSize(t)); err == nil {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
+
+// bitSize returns the size in bits of the values of the numeric basic
+// type t for the target architecture, e.g. 8 for int8.
+func bitSize(t *types.Basic) int {
+	if sizes := types.SizesFor("gc", build.Default.GOARCH); sizes != nil {
+		return 8 * int(sizes.Sizeof(t))
+	}
+	return 64
+}
+
+// untypedValue returns the Go literal for the example value v
+// if it is a scalar value, e.g. for a field of type interface{}.
+func untypedValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v), true
+	case string:
+		return strconv.Quote(v), true
+	case json.Number:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
+
+// sortedKeys returns the keys of the example object m in sorted order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// fieldDoc describes the declaration of a newly filled field.
+type fieldDoc struct {
+	Path string `json:"path"`          // path of the field in the literal, see elementPath
+	Line int    `json:"line"`          // line of the field in the generated code, starting at 1
+	Decl string `json:"decl"`          // position of the field declaration, as file:line
+	Doc  string `json:"doc,omitempty"` // first sentence of the field documentation
+}
+
+// fieldDocs returns the declarations and documentation of every newly
+// filled field of the literal in code, looked up in files.
+func fieldDocs(code string, fi fillInfo, files syntaxFiles) ([]fieldDoc, error) {
+	cfset, expr, _, err := parseCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := []fieldDoc{}
+	var walk func(e ast.Expr, t types.Type, path string)
+	walk = func(e ast.Expr, t types.Type, path string) {
+		if u, ok := e.(*ast.UnaryExpr); ok {
+			e = u.X
+			if p, ok := t.Underlying().(*types.Pointer); ok {
+				t = p.Elem()
+			}
+		}
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok {
+			return
+		}
+		switch u := t.Underlying().(type) {
+		case *types.Struct:
+			for i, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				id, ok := kv.Key.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				field := lookupField(u, id.Name)
+				if field == nil {
+					continue
+				}
+				p, _ := elementPath(path, i, elt)
+				if !fi.existing[p] {
+					// The declaration is reported like by the compiler,
+					// honoring //line directives.
+					pos := fi.fset.Position(field.Pos())
+					docs = append(docs, fieldDoc{
+						Path: p,
+						Line: cfset.Position(kv.Pos()).Line,
+						Decl: fmt.Sprintf("%s:%d", pos.Filename, pos.Line),
+						Doc:  fieldComment(files.findField(fi.fset, field.Pos())),
+					})
+				}
+				walk(kv.Value, field.Type(), p)
+			}
+		case *types.Map:
+			for i, elt := range lit.Elts {
+				p, v := elementPath(path, i, elt)
+				walk(v, u.Elem(), p)
+			}
+		case sequence:
+			for i, elt := range lit.Elts {
+				p, v := elementPath(path, i, elt)
+				walk(v, u.Elem(), p)
+			}
+		}
+	}
+	walk(expr, fi.typ, "")
+	return docs, nil
+}
+
+// fieldComment returns the first sentence of the doc comment,
+// or else of the line comment, of the field or "" if it is nil.
+func fieldComment(field *ast.Field) string {
+	if field == nil {
+		return ""
+	}
+	comment := field.Doc
+	if comment == nil {
+		comment = field.Comment
+	}
+	return new(doc.Package).Synopsis(comment.Text())
+}
+
+// syntaxFiles are the syntax trees of the loaded packages, which are
+// parsed from the overlay of -modified, by their files in the file set.
+type syntaxFiles map[*token.File]*ast.File
+
+// newSyntaxFiles returns the syntax trees of pkgs
+// and their dependencies, loaded with fset.
+func newSyntaxFiles(fset *token.FileSet, pkgs []*packages.Package) syntaxFiles {
+	files := make(syntaxFiles)
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, f := range pkg.Syntax {
+			files[fset.File(f.Pos())] = f
+		}
+	})
+	return files
+}
+
+// findField returns the declaration of the field declared at pos
+// or nil if it cannot be found.
+func (files syntaxFiles) findField(fset *token.FileSet, pos token.Pos) *ast.Field {
+	f := files[fset.File(pos)]
+	if f == nil {
+		return nil
+	}
+
+	var field *ast.Field
+	ast.Inspect(f, func(n ast.Node) bool {
+		if field != nil {
+			return false
+		}
+		fld, ok := n.(*ast.Field)
+		if !ok {
+			return true
+		}
+		for _, name := range fld.Names {
+			if name.Pos() == pos {
+				field = fld
+			}
+		}
+		// The position of an embedded field is the one of its type name.
+		if len(fld.Names) == 0 && fld.Type.Pos() <= pos && pos < fld.Type.End() {
+			field = fld
+		}
+		return true
+	})
+	return field
+}
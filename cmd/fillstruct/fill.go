@@ -5,10 +5,14 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
+	"math/rand"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -16,39 +20,108 @@ import (
 // litInfo contains the information about
 // a literal to fill with zero values.
 type litInfo struct {
-	typ       types.Type   // the base type of the literal
-	name      *types.Named // name of the type or nil, e.g. for an anonymous struct type
-	hideType  bool         // flag to hide the element type inside an array, slice or map literal
-	isPointer bool         // true if the literal is of a pointer type
+	typ       types.Type        // the base type of the literal
+	name      *types.Named      // name of the type or nil, e.g. for an anonymous struct type
+	alias     *types.Alias      // alias the type is written as or nil, see typeName
+	hideType  bool              // flag to hide the element type inside an array, slice or map literal
+	isPointer bool              // true if the literal is of a pointer type
+	value     interface{}       // example value to fill the literal with or nil, see readExample
+	lit       *ast.CompositeLit // existing literal whose values are preserved or nil, see mergeable
+	template  bool              // add a template element to an empty slice literal
 }
 
 type filler struct {
 	pkg         *types.Package
 	pos         token.Pos
 	lines       int
-	existing    map[string]*ast.KeyValueExpr
-	first       bool
 	importNames map[string]string // import path -> import name
+	mode        string            // fill mode, see validMode
+	order       string            // field order, see validOrder
+	rand        *rand.Rand        // source of pseudo-random values for modeFuzz
+	path        []string          // path of the field being filled, see fieldPath
+	counter     int               // last number used by modePlaceholder
+	flip        bool              // last boolean used by modePlaceholder
+	depth       int               // number of struct literals enclosing the one being filled
+	maxDepth    int               // number of nested struct literals to expand, 0 means no limit
+	flat        bool              // place literals on a single line, see fixExprPos
+	deprecated  *deprecations     // deprecated fields to skip, nil if they are filled
+	directives  *directives       // fields and types annotated with //fillstruct:ignore, nil if there are none
+	ignored     *[]string         // collects the paths of the fields skipped by directives, or nil
+	names       []string          // names of the fields enclosing the one being filled, see matchField
+	only        *regexp.Regexp    // fields to fill, nil if all are filled
+	ignore      *regexp.Regexp    // fields not to fill, nil if all are filled
+	matched     int               // number of fields matched by only
+	exported    bool              // fill only exported fields, even of types of the same package
+	embedded    string            // handling of embedded fields, see validEmbedded
+	recursion   string            // handling of recursive types, see validRecursion
+	shallow     bool              // do not expand nested structs of the same package with unexported fields
+	vars        []*types.Var      // variables in scope to fill fields with, see scopeVar
+	params      []*types.Var      // parameters to fill the fields of the literal with, see constructorParams
+	required    bool              // fill only the fields needed by validators, see isNeeded
+
+	filledVars int                        // number of fields filled with variables, see fieldVar
+	shareable  map[*ast.CompositeLit]bool // collects the literals which can be shared, see share; nil if not collected
 }
 
-func zeroValue(pkg *types.Package, importNames map[string]string, lit *ast.CompositeLit, info litInfo) (ast.Expr, int) {
-	f := filler{
+func newFiller(pkg *types.Package, importNames map[string]string, opts options) filler {
+	return filler{
 		pkg:         pkg,
 		pos:         1,
-		first:       true,
-		existing:    make(map[string]*ast.KeyValueExpr),
 		importNames: importNames,
+		mode:        opts.mode,
+		order:       opts.order,
+		rand:        rand.New(rand.NewSource(opts.seed)),
+		maxDepth:    opts.depth,
+		deprecated:  opts.deprecated,
+		directives:  opts.directives,
+		ignored:     opts.ignored,
+		only:        opts.only,
+		ignore:      opts.ignore,
+		exported:    opts.exportedOnly,
+		embedded:    opts.embedded,
+		recursion:   opts.recursion,
+		shallow:     opts.shallow,
+		vars:        opts.vars,
+		params:      opts.params,
+		required:    opts.requiredOnly,
+		shareable:   opts.shareable,
 	}
-	for _, e := range lit.Elts {
-		kv := e.(*ast.KeyValueExpr)
-		f.existing[kv.Key.(*ast.Ident).Name] = kv
+}
+
+func zeroValue(pkg *types.Package, importNames map[string]string, lit *ast.CompositeLit, info litInfo, opts options) (ast.Expr, int) {
+	f := newFiller(pkg, importNames, opts)
+	info.lit = lit
+	f.path = []string{"value"}
+	if info.name != nil {
+		f.path[0] = strings.ToLower(info.name.Obj().Name())
+	}
+	if _, ok := info.typ.(*types.Struct); ok || info.typeName() == nil {
+		return f.zero(info, make([]types.Type, 0, 8)), f.lines
 	}
-	return f.zero(info, make([]types.Type, 0, 8)), f.lines
+
+	// The literal is of a named map, slice or array type:
+	// fill it with a template entry and keep the type name.
+	name := info.typeName()
+	info.name, info.alias = nil, nil
+	info.template = true
+	expr := f.zero(info, make([]types.Type, 0, 8))
+	if lit, ok := expr.(*ast.CompositeLit); ok && !info.hideType {
+		if typeName, ok := typeString(f.pkg, f.importNames, name); ok {
+			lit.Type = ast.NewIdent(typeName)
+		}
+	}
+	return expr, f.lines
 }
 
 func (f *filler) zero(info litInfo, visited []types.Type) ast.Expr {
 	switch t := info.typ.(type) {
 	case *types.Basic:
+		if v, ok := basicValue(t, info.value); ok {
+			return &ast.BasicLit{Value: v, ValuePos: f.pos}
+		}
+		if v, ok := f.basicValue(t); ok {
+			return &ast.BasicLit{Value: v, ValuePos: f.pos}
+		}
 		switch t.Kind() {
 		case types.Bool:
 			return &ast.Ident{Name: "false", NamePos: f.pos}
@@ -85,6 +158,11 @@ func (f *filler) zero(info litInfo, visited []types.Type) ast.Expr {
 		case types.RecvOnly:
 			dir = ast.RECV
 		}
+		var val ast.Expr = ast.NewIdent(valTypeName)
+		if c, ok := t.Elem().(*types.Chan); ok && dir == ast.SEND|ast.RECV && c.Dir() == types.RecvOnly {
+			// chan <-chan T is parsed as chan<- chan T.
+			val = &ast.ParenExpr{X: val}
+		}
 
 		return &ast.CallExpr{
 			Fun: &ast.Ident{
@@ -95,30 +173,45 @@ func (f *filler) zero(info litInfo, visited []types.Type) ast.Expr {
 			Args: []ast.Expr{
 				&ast.ChanType{
 					Dir:   dir,
-					Value: ast.NewIdent(valTypeName),
+					Value: val,
 				},
 			},
 			Rparen: f.pos,
 		}
 	case *types.Interface:
+		if v, ok := untypedValue(info.value); ok && t.NumMethods() == 0 {
+			return &ast.BasicLit{Value: v, ValuePos: f.pos}
+		}
 		return &ast.Ident{Name: "nil", NamePos: f.pos}
 	case *types.Map:
-		keyTypeName, ok := typeString(f.pkg, f.importNames, t.Key())
+		mapPos := f.pos
+		key, ok := f.typeExpr(t.Key())
 		if !ok {
 			return nil
 		}
-		valTypeName, ok := typeString(f.pkg, f.importNames, t.Elem())
+		val, ok := f.typeExpr(t.Elem())
 		if !ok {
 			return nil
 		}
 		lit := &ast.CompositeLit{
 			Lbrace: f.pos,
 			Type: &ast.MapType{
-				Map:   f.pos,
-				Key:   ast.NewIdent(keyTypeName),
-				Value: ast.NewIdent(valTypeName),
+				Map:   mapPos,
+				Key:   key,
+				Value: val,
 			},
 		}
+		elts := f.existingEntries(t, info, visited)
+		if elts == nil {
+			elts = f.mapEntries(t, info, visited)
+		}
+		if elts != nil {
+			lit.Elts = elts
+			f.pos++
+			lit.Rbrace = f.pos
+			f.lines += len(elts) + 1
+			return lit
+		}
 		f.pos++
 		lit.Elts = []ast.Expr{
 			&ast.KeyValueExpr{
@@ -177,9 +270,30 @@ func (f *filler) zero(info litInfo, visited []types.Type) ast.Expr {
 		info.typ = t.Underlying()
 		return f.zero(info, visited)
 
+	case *types.Alias:
+		// Struct literals are written with the name of the alias.
+		info.alias = t
+		info.typ = types.Unalias(t)
+		v := f.zero(info, visited)
+		switch t.Underlying().(type) {
+		case *types.Map, *types.Slice, *types.Array:
+			// So are map, slice and array literals, unlike
+			// those of named types, which use the underlying type.
+			if lit, ok := v.(*ast.CompositeLit); ok && lit.Type != nil {
+				if typeName, ok := typeString(f.pkg, f.importNames, t); ok {
+					lit.Type = &ast.Ident{Name: typeName, NamePos: lit.Type.Pos()}
+				}
+			}
+		}
+		return v
+
 	case *types.Pointer:
+		if call := f.wellKnown(t.Elem()); call != nil && info.lit == nil {
+			return call
+		}
 		if _, ok := t.Elem().Underlying().(*types.Struct); ok {
 			info.typ = t.Elem()
+			info.alias = nil // of the pointer type
 			info.isPointer = true
 			return f.zero(info, visited)
 		}
@@ -187,8 +301,8 @@ func (f *filler) zero(info litInfo, visited []types.Type) ast.Expr {
 
 	case *types.Struct:
 		newlit := &ast.CompositeLit{Lbrace: f.pos}
-		if !info.hideType && info.name != nil {
-			typeName, ok := typeString(f.pkg, f.importNames, info.name)
+		if name := info.typeName(); !info.hideType && name != nil {
+			typeName, ok := typeString(f.pkg, f.importNames, name)
 			if !ok {
 				return nil
 			}
@@ -196,63 +310,223 @@ func (f *filler) zero(info litInfo, visited []types.Type) ast.Expr {
 			if info.isPointer {
 				newlit.Type.(*ast.Ident).Name = "&" + newlit.Type.(*ast.Ident).Name
 			}
-		} else if !info.hideType && info.name == nil {
-			typeName, ok := typeString(f.pkg, f.importNames, t)
+		} else if !info.hideType {
+			typ, ok := f.structType(t)
 			if !ok {
 				return nil
 			}
-			newlit.Type = ast.NewIdent(typeName)
+			newlit.Type = typ
+			newlit.Lbrace = f.pos
 		}
 
+		// Recursive types and literals beyond the maximum depth are
+		// not expanded; only the fields of an existing literal are kept.
+		expand := f.maxDepth <= 0 || f.depth < f.maxDepth
+		visits := 0
 		for _, typ := range visited {
 			if t == typ {
-				return newlit
+				visits++
+			}
+		}
+		recursive := visits > 1 || visits == 1 && f.recursion != recursionOnce
+		if recursive {
+			expand = false
+		}
+		if f.shallow && f.depth > 0 && info.name != nil && !isImported(f.pkg, info.name) && hasUnexported(t) {
+			expand = false
+		}
+		if !expand && info.lit == nil {
+			if recursive && info.isPointer && f.recursion == recursionNil {
+				return &ast.Ident{Name: "nil", NamePos: f.pos}
 			}
+			return addressOf(newlit, info)
+		}
+		if expand {
+			visited = append(visited, t)
+			f.depth++
+			defer func() { f.depth-- }()
 		}
-		visited = append(visited, t)
 
-		first := f.first
-		f.first = false
+		existing := make(map[string]*ast.KeyValueExpr)
+		if info.lit != nil {
+			for _, e := range info.lit.Elts {
+				kv := e.(*ast.KeyValueExpr)
+				existing[kv.Key.(*ast.Ident).Name] = kv
+			}
+		}
 		lines := 0
-		imported := isImported(f.pkg, info.name)
+		imported := f.exported || isImported(f.pkg, info.name) || isMessage(t)
 
-		for i := 0; i < t.NumFields(); i++ {
+		enclosing, filledVars := len(f.names), f.filledVars
+		for _, i := range fieldOrder(t, info.lit, f.order) {
 			field := t.Field(i)
 			// don't fill the field if it a gRPC system field
 			if strings.HasPrefix(field.Name(), "XXX_") {
 				continue
 			}
-			if kv, ok := f.existing[field.Name()]; first && ok {
+			f.names = append(f.names[:enclosing], field.Name())
+			if kv, ok := existing[field.Name()]; ok {
 				f.pos++
 				lines++
-				f.fixExprPos(kv)
+				if lit := mergeable(kv.Value, field.Type()); lit != nil && expand {
+					// Preserve the fields of the nested literal and add the missing ones.
+					only := f.only
+					if f.only != nil && matchField(f.only, f.names) {
+						f.only = nil
+					}
+					k := &ast.Ident{Name: field.Name(), NamePos: f.pos}
+					f.path = append(f.path, strings.ToLower(field.Name()))
+					v := f.zero(litInfo{typ: field.Type(), lit: lit, value: fieldValue(info.value, t, i)}, visited)
+					f.path = f.path[:len(f.path)-1]
+					f.only = only
+					kv = &ast.KeyValueExpr{Key: k, Value: v}
+				} else {
+					kv = copyExpr(kv).(*ast.KeyValueExpr)
+					f.fixExprPos(kv)
+				}
 				newlit.Elts = append(newlit.Elts, kv)
-			} else if !ok && !imported || field.Exported() {
+			} else if !expand || skipField(t, i) || f.deprecated.isDeprecated(field) || f.ignore != nil && matchField(f.ignore, f.names) || field.Embedded() && f.embedded == embeddedSkip || f.required && !isNeeded(t, i) {
+				continue
+			} else if !imported || field.Exported() {
+				if f.directives.isIgnored(field) {
+					if f.ignored != nil {
+						*f.ignored = append(*f.ignored, strings.Join(f.names, "."))
+					}
+					continue
+				}
+				// With -only, the fields matching neither the filter nor
+				// enclosing a matching field are dropped after filling.
+				pos, flines, matched, only := f.pos, f.lines, f.matched, f.only
+				if f.only != nil && matchField(f.only, f.names) {
+					f.only = nil
+					f.matched++
+				}
 				f.pos++
 				k := &ast.Ident{Name: field.Name(), NamePos: f.pos}
-				if v := f.zero(litInfo{typ: field.Type(), name: nil}, visited); v != nil {
+				var v ast.Expr
+				value := fieldValue(info.value, t, i)
+				if sv := f.fieldVar(field); sv != nil && value == nil {
+					v = &ast.Ident{Name: sv.Name(), NamePos: f.pos}
+					f.filledVars++
+				} else {
+					f.path = append(f.path, strings.ToLower(field.Name()))
+					v = f.zero(litInfo{typ: field.Type(), name: nil, value: value}, visited)
+					f.path = f.path[:len(f.path)-1]
+				}
+				f.only = only
+				if v != nil && (only == nil || f.matched > matched) {
 					lines++
 					newlit.Elts = append(newlit.Elts, &ast.KeyValueExpr{
 						Key:   k,
 						Value: v,
 					})
 				} else {
-					f.pos--
+					f.pos, f.lines = pos, flines
 				}
 			}
 		}
+		f.names = f.names[:enclosing]
 		if lines > 0 {
 			f.lines += lines + 2
 			f.pos++
 		}
 		newlit.Rbrace = f.pos
-		return newlit
+		if f.shareable != nil && info.lit == nil && info.value == nil && !info.isPointer && f.filledVars == filledVars && (f.mode == "" || f.mode == modeZero) && !hasReferences(t) {
+			// Only generated zero values are shared, and only
+			// if the copies of the variable do not alias.
+			f.shareable[newlit] = true
+		}
+		return addressOf(newlit, info)
 
 	default:
 		panic(fmt.Sprintf("unexpected type %T", t))
 	}
 }
 
+// fieldVar returns the variable to fill the field with: a parameter of
+// the constructor of the literal, only for the fields of the literal
+// itself, or a variable in scope, or nil.
+func (f *filler) fieldVar(field *types.Var) *types.Var {
+	if f.depth == 1 {
+		if v := namedVar(f.params, field); v != nil {
+			return v
+		}
+	}
+	return scopeVar(f.vars, field)
+}
+
+// addressOf returns the literal lit of an anonymous struct type, see
+// structType, with its address taken if info is of a pointer type. The
+// type names of other literals are prefixed with "&" instead, see zero.
+func addressOf(lit *ast.CompositeLit, info litInfo) ast.Expr {
+	if _, ok := lit.Type.(*ast.StructType); ok && info.isPointer {
+		return &ast.UnaryExpr{OpPos: lit.Type.Pos(), Op: token.AND, X: lit}
+	}
+	return lit
+}
+
+// structType returns the inline type of the anonymous struct t, e.g. of
+// a field, laid out like gofmt does, see fixExprPos. The types of its
+// fields are rendered by typeString, except for anonymous structs and
+// pointers to them, which are laid out like t.
+func (f *filler) structType(t *types.Struct) (ast.Expr, bool) {
+	st := &ast.StructType{Fields: &ast.FieldList{}}
+	for i := 0; i < t.NumFields(); i++ {
+		field := t.Field(i)
+		typ, ok := f.typeExpr(field.Type())
+		if !ok {
+			return nil, false
+		}
+		af := &ast.Field{Type: typ}
+		if !field.Embedded() {
+			af.Names = []*ast.Ident{ast.NewIdent(field.Name())}
+		}
+		if tag := t.Tag(i); tag != "" {
+			af.Tag = &ast.BasicLit{Kind: token.STRING, Value: quoteTag(tag)}
+		}
+		st.Fields.List = append(st.Fields.List, af)
+	}
+	f.fixExprPos(st)
+	return st, true
+}
+
+// typeExpr returns the type t, e.g. of a field or of the elements of a
+// slice, as an expression: anonymous structs and pointers to them are
+// laid out like gofmt does, see structType, other types are rendered by
+// typeString.
+func (f *filler) typeExpr(t types.Type) (ast.Expr, bool) {
+	switch t := t.(type) {
+	case *types.Struct:
+		return f.structType(t)
+	case *types.Pointer:
+		if s, ok := t.Elem().(*types.Struct); ok {
+			typ, ok := f.typeExpr(s)
+			return &ast.StarExpr{X: typ}, ok
+		}
+	}
+	typeName, ok := typeString(f.pkg, f.importNames, t)
+	return ast.NewIdent(typeName), ok
+}
+
+// multiLine reports whether gofmt puts the fields of a struct type on
+// lines of their own: if there are several or if a field is of such a
+// struct type.
+func multiLine(fields *ast.FieldList) bool {
+	if len(fields.List) > 1 {
+		return true
+	}
+	for _, field := range fields.List {
+		typ := field.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		if st, ok := typ.(*ast.StructType); ok && multiLine(st.Fields) {
+			return true
+		}
+	}
+	return false
+}
+
 // sequence is a interface that abstracts
 // between *types.Slice and *types.Array
 type sequence interface {
@@ -262,23 +536,67 @@ type sequence interface {
 func (f *filler) fillSequence(info litInfo, visited []types.Type, t sequence, length ast.Expr) ast.Expr {
 	lit := &ast.CompositeLit{Lbrace: f.pos}
 	if !info.hideType {
-		typeName, ok := typeString(f.pkg, f.importNames, t.Elem())
+		// Keep the length of an existing [...]T literal elided.
+		if at, ok := litArrayType(info.lit); ok {
+			if _, ok := at.Len.(*ast.Ellipsis); ok {
+				length = &ast.Ellipsis{Ellipsis: f.pos}
+			}
+		}
+		lbrack := f.pos
+		elt, ok := f.typeExpr(t.Elem())
 		if !ok {
 			return nil
 		}
 		lit.Type = &ast.ArrayType{
-			Lbrack: f.pos,
+			Lbrack: lbrack,
 			Len:    length,
-			Elt:    ast.NewIdent(typeName),
+			Elt:    elt,
 		}
+		lit.Lbrace = f.pos
+	}
+	values, _ := info.value.([]interface{})
+	var elts []ast.Expr // existing elements
+	if info.lit != nil {
+		elts = info.lit.Elts
 	}
+	n := int64(len(values))
 	if arr, isArray := t.(*types.Array); isArray {
-		lit.Elts = make([]ast.Expr, 0, arr.Len())
-		for i := int64(0); i < arr.Len(); i++ {
+		n = arr.Len()
+	} else if len(elts) > 0 {
+		n = int64(len(elts))
+	} else if n == 0 {
+		n = f.sequenceLen()
+		if n == 0 && info.template {
+			n = 1
+		}
+		for _, typ := range visited {
+			if t.(types.Type) == typ {
+				n = 0
+			}
+		}
+		visited = append(visited, t.(types.Type))
+	}
+	if n > 0 {
+		lit.Elts = make([]ast.Expr, 0, n)
+		for i := int64(0); i < n; i++ {
 			f.pos++
 			elemInfo := litInfo{typ: t.Elem().Underlying(), hideType: true}
-			elemInfo.name, _ = t.Elem().(*types.Named)
-			if v := f.zero(elemInfo, visited); v != nil {
+			elemInfo.name = namedOf(t.Elem())
+			if i < int64(len(values)) {
+				elemInfo.value = values[i]
+			}
+			if i < int64(len(elts)) {
+				if elemInfo.lit = mergeable(elts[i], t.Elem()); elemInfo.lit == nil {
+					elt := copyExpr(elts[i])
+					f.fixExprPos(elt)
+					lit.Elts = append(lit.Elts, elt)
+					continue
+				}
+			}
+			f.path = append(f.path, fmt.Sprintf("[%d]", i))
+			v := f.zero(elemInfo, visited)
+			f.path = f.path[:len(f.path)-1]
+			if v != nil {
 				lit.Elts = append(lit.Elts, v)
 			}
 		}
@@ -290,6 +608,109 @@ func (f *filler) fillSequence(info litInfo, visited []types.Type, t sequence, le
 	return lit
 }
 
+// existingEntries returns the entries of the existing map literal
+// in info.lit, whose keys and values are filled if they are mergeable,
+// or nil if there is no existing literal or it is empty. Filling a key
+// with zero values does not change it.
+func (f *filler) existingEntries(t *types.Map, info litInfo, visited []types.Type) []ast.Expr {
+	if info.lit == nil || len(info.lit.Elts) == 0 {
+		return nil
+	}
+	var elts []ast.Expr
+	for _, e := range info.lit.Elts {
+		f.pos++
+		kv := e.(*ast.KeyValueExpr)
+		key, value := kv.Key, kv.Value
+		if lit := mergeable(key, t.Key()); lit != nil {
+			key = f.zero(litInfo{typ: t.Key(), hideType: true, lit: lit}, visited)
+		} else {
+			key = copyExpr(key)
+			f.fixExprPos(key)
+		}
+		colon := f.pos
+		if lit := mergeable(value, t.Elem()); lit != nil {
+			value = f.zero(litInfo{typ: t.Elem(), hideType: true, lit: lit}, visited)
+		} else {
+			value = copyExpr(value)
+			f.fixExprPos(value)
+		}
+		elts = append(elts, &ast.KeyValueExpr{Key: key, Colon: colon, Value: value})
+	}
+	return elts
+}
+
+// mapEntries returns the entries of a map literal filled with
+// the entries of the example object in info.value, or nil if
+// there is no example object or its keys are invalid.
+func (f *filler) mapEntries(t *types.Map, info litInfo, visited []types.Type) []ast.Expr {
+	obj, ok := info.value.(map[string]interface{})
+	if !ok || len(obj) == 0 {
+		return nil
+	}
+	key, ok := t.Key().Underlying().(*types.Basic)
+	if !ok {
+		return nil
+	}
+	var elts []ast.Expr
+	for _, k := range sortedKeys(obj) {
+		kv, ok := basicValue(key, k)
+		if !ok {
+			kv, ok = basicValue(key, json.Number(k))
+		}
+		if !ok {
+			return nil
+		}
+		f.pos++
+		elts = append(elts, &ast.KeyValueExpr{
+			Key:   &ast.BasicLit{Value: kv, ValuePos: f.pos},
+			Colon: f.pos,
+			Value: f.zero(litInfo{typ: t.Elem(), name: info.name, hideType: true, value: obj[k]}, visited),
+		})
+	}
+	return elts
+}
+
+// mergeable returns the composite literal e of type t, without its
+// address operator, if it can be filled while preserving its values:
+// struct literals with keyed fields and sequences with positional
+// elements. Otherwise, it returns nil and e is preserved as a whole.
+func mergeable(e ast.Expr, t types.Type) *ast.CompositeLit {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		if _, ok := p.Elem().Underlying().(*types.Struct); !ok {
+			return nil
+		}
+		if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+			e = u.X
+		}
+		t = p.Elem()
+	}
+	lit, ok := e.(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+	switch t.Underlying().(type) {
+	case *types.Struct:
+		for _, e := range lit.Elts {
+			kv, ok := e.(*ast.KeyValueExpr)
+			if !ok {
+				return nil
+			}
+			if _, ok := kv.Key.(*ast.Ident); !ok {
+				return nil
+			}
+		}
+	case *types.Slice, *types.Array:
+		for _, e := range lit.Elts {
+			if _, ok := e.(*ast.KeyValueExpr); ok {
+				return nil
+			}
+		}
+	default:
+		return nil
+	}
+	return lit
+}
+
 func (f *filler) fixExprPos(expr ast.Expr) {
 	switch expr := expr.(type) {
 	case nil:
@@ -311,13 +732,17 @@ func (f *filler) fixExprPos(expr ast.Expr) {
 		f.fixExprPos(expr.Type)
 		expr.Lbrace = f.pos
 		for _, e := range expr.Elts {
-			f.pos++
+			if !f.flat {
+				f.pos++
+			}
 			f.fixExprPos(e)
 		}
-		if l := len(expr.Elts); l > 0 {
+		if l := len(expr.Elts); l > 0 && !f.flat {
 			f.lines += l + 2
 		}
-		f.pos++
+		if !f.flat {
+			f.pos++
+		}
 		expr.Rbrace = f.pos
 	case *ast.Ellipsis:
 		expr.Ellipsis = f.pos
@@ -348,12 +773,145 @@ func (f *filler) fixExprPos(expr ast.Expr) {
 	case *ast.StarExpr:
 		expr.Star = f.pos
 		f.fixExprPos(expr.X)
+	case *ast.StructType:
+		expr.Struct = f.pos
+		expr.Fields.Opening = f.pos
+		lines := !f.flat && multiLine(expr.Fields)
+		for _, field := range expr.Fields.List {
+			if lines {
+				f.pos++
+			}
+			for _, name := range field.Names {
+				name.NamePos = f.pos
+			}
+			f.fixExprPos(field.Type)
+			if field.Tag != nil {
+				f.fixExprPos(field.Tag)
+			}
+		}
+		if l := len(expr.Fields.List); lines {
+			f.lines += l + 2
+			f.pos++
+		}
+		expr.Fields.Closing = f.pos
 	case *ast.UnaryExpr:
 		expr.OpPos = f.pos
 		f.fixExprPos(expr.X)
 	}
 }
 
+// copyExpr returns a deep copy of the expression e of the syntax trees,
+// whose positions can then be changed by fixExprPos without affecting
+// the literals filled concurrently. The objects and scopes of the
+// identifiers are shared.
+func copyExpr(e ast.Expr) ast.Expr {
+	if e == nil {
+		return nil
+	}
+	return copyValue(reflect.ValueOf(e)).Interface().(ast.Expr)
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		switch v.Interface().(type) {
+		case *ast.Object, *ast.Scope:
+			return v
+		}
+		c := reflect.New(v.Elem().Type())
+		c.Elem().Set(copyValue(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(copyValue(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			c.Field(i).Set(copyValue(v.Field(i)))
+		}
+		return c
+	}
+	return v
+}
+
+// matchField reports whether re matches the name or the path, e.g.
+// "Addr.ZIP", of the field whose enclosing fields are names.
+func matchField(re *regexp.Regexp, names []string) bool {
+	return re.MatchString(names[len(names)-1]) || re.MatchString(strings.Join(names, "."))
+}
+
+// Handling of embedded fields, selected with the -embedded flag.
+const (
+	embeddedFill = "fill" // fill embedded fields like other fields, e.g. Base: Base{...}
+	embeddedSkip = "skip" // leave embedded fields out, they keep their zero values
+)
+
+// Handling of recursive types, selected with the -recursion flag.
+const (
+	recursionEmpty = "empty" // emit an empty literal, e.g. Next: &Node{}
+	recursionNil   = "nil"   // fill pointers with nil, e.g. Next: nil
+	recursionOnce  = "once"  // expand one more level, e.g. Next: &Node{Val: 0, Next: &Node{}}
+)
+
+func validRecursion(recursion string) bool {
+	switch recursion {
+	case "", recursionEmpty, recursionNil, recursionOnce:
+		return true
+	default:
+		return false
+	}
+}
+
+func validEmbedded(embedded string) bool {
+	switch embedded {
+	case "", embeddedFill, embeddedSkip:
+		return true
+	default:
+		return false
+	}
+}
+
+// skipField reports whether the i-th field of s carries
+// the struct tag `fillstruct:"-"` and must not be filled.
+func skipField(s *types.Struct, i int) bool {
+	return reflect.StructTag(s.Tag(i)).Get("fillstruct") == "-"
+}
+
+// litArrayType returns the array type of the literal lit, if any.
+func litArrayType(lit *ast.CompositeLit) (*ast.ArrayType, bool) {
+	if lit == nil {
+		return nil, false
+	}
+	at, ok := lit.Type.(*ast.ArrayType)
+	return at, ok
+}
+
+// hasUnexported reports whether s has an unexported field.
+func hasUnexported(s *types.Struct) bool {
+	for i := 0; i < s.NumFields(); i++ {
+		if !s.Field(i).Exported() {
+			return true
+		}
+	}
+	return false
+}
+
 func isImported(pkg *types.Package, n *types.Named) bool {
 	return n != nil && pkg != n.Obj().Pkg()
 }
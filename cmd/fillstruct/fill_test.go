@@ -6,13 +6,26 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go/ast"
 	"go/format"
 	"go/importer"
 	"go/parser"
 	"go/token"
 	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/tools/go/packages"
 )
 
 func TestFill(t *testing.T) {
@@ -308,7 +321,7 @@ type myStruct struct {
 		nil,
 	},
 	g: [1]chan (<-chan struct{}){
-		make(chan <-chan struct{}),
+		make(chan (<-chan struct{})),
 	},
 	h: [1]interface{foo(x unsafe.Pointer, args ...string)}{
 		nil,
@@ -318,6 +331,93 @@ type myStruct struct {
 			"": 0,
 		},
 	},
+}`,
+		},
+		{
+			name: "aliases",
+			src: `package p
+
+import "os"
+
+var s = myStruct{}
+
+type myStruct struct {
+	Total Money
+	Tax   *Money
+	Items []Money
+	Tags  Tags
+	Modes []os.FileMode
+}
+
+type Money = amount
+
+type amount struct{ N int }
+
+type Tags = map[string]string`,
+			want: `myStruct{
+	Total: Money{
+		N: 0,
+	},
+	Tax: &Money{
+		N: 0,
+	},
+	Items: []Money{},
+	Tags: Tags{
+		"": "",
+	},
+	Modes: []os.FileMode{},
+}`,
+		},
+		{
+			name: "anonymous struct fields",
+			src: `package p
+
+import "time"
+
+var s = myStruct{}
+
+type myStruct struct {
+	Server struct {
+		Host    string ` + "`json:\"host\"`" + `
+		Timeout time.Duration
+		TLS     *struct{ Cert, Key string }
+	}
+	Limits []struct{ Max int }
+	Meta   *struct{ Debug bool }
+	Tags   map[string]struct{ A, B int }
+}`,
+			want: `myStruct{
+	Server: struct {
+		Host    string ` + "`json:\"host\"`" + `
+		Timeout time.Duration
+		TLS     *struct {
+			Cert string
+			Key  string
+		}
+	}{
+		Host:    "",
+		Timeout: 0,
+		TLS: &struct {
+			Cert string
+			Key  string
+		}{
+			Cert: "",
+			Key:  "",
+		},
+	},
+	Limits: []struct{ Max int }{},
+	Meta: &struct{ Debug bool }{
+		Debug: false,
+	},
+	Tags: map[string]struct {
+		A int
+		B int
+	}{
+		"": {
+			A: 0,
+			B: 0,
+		},
+	},
 }`,
 		},
 		{
@@ -341,7 +441,10 @@ type otherStruct struct {
 	B int
 }`,
 			want: `myStruct{
-	a: [3]struct{a int; b int}{
+	a: [3]struct {
+		a int
+		b int
+	}{
 		{
 			a: 0,
 			b: 0,
@@ -368,7 +471,11 @@ type otherStruct struct {
 			B: 0,
 		},
 	},
-	d: struct{c *list.Element; D complex64; *list.Element}{
+	d: struct {
+		c *list.Element
+		D complex64
+		*list.Element
+	}{
 		c: &list.Element{
 			Value: nil,
 		},
@@ -422,16 +529,74 @@ type otherStruct struct{ a, b, c int}`,
 	c: &otherStruct{
 		a: 1,
 		b: 3,
+		c: 0,
 	},
 	d: "foo",
 	e: otherStruct{
+		a: 0,
 		b: 9,
+		c: 0,
 	},
 	f: [1]otherStruct{
 		{
+			a: 0,
+			b: 0,
 			c: 42,
 		},
 	},
+}`,
+		},
+		{
+			name: "with existing nested key-value exprs",
+			src: `package p
+
+import "strings"
+
+var s = outer{
+	in: &middle{
+		leaf:   leaf{a: 1},
+		leaves: []leaf{{b: 2}, {3, 4}},
+	},
+}
+
+type outer struct {
+	in *middle
+	n  int
+	sb strings.Builder
+}
+
+type middle struct {
+	leaf   leaf
+	leaves []leaf
+	m      map[string]leaf
+}
+
+type leaf struct{ a, b int }`,
+			want: `myStruct{
+	in: &middle{
+		leaf: leaf{
+			a: 1,
+			b: 0,
+		},
+		leaves: []leaf{
+			{
+				a: 0,
+				b: 2,
+			},
+			{
+				3,
+				4,
+			},
+		},
+		m: map[string]leaf{
+			"": {
+				a: 0,
+				b: 0,
+			},
+		},
+	},
+	n:  0,
+	sb: strings.Builder{},
 }`,
 		},
 		{
@@ -627,13 +792,62 @@ type myStruct struct {
 		pkg, importNames, lit, typ := parseStruct(t, test.name, test.src)
 
 		name := types.NewNamed(types.NewTypeName(0, pkg, "myStruct", nil), typ, nil)
-		newlit, lines := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name})
+		newlit, lines := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name}, options{})
 
 		out := printNode(t, test.name, newlit, lines)
 		if test.want != out {
 			t.Errorf("%q: got %v, want %v\n", test.name, out, test.want)
 		}
+		assertReparses(t, test.name, test.src)
+	}
+}
+
+// assertReparses checks that the literal in src, filled in every mode with
+// several seeds and substituted into src, type-checks without errors to the
+// type of the original literal.
+func assertReparses(t *testing.T, name, src string) {
+	t.Helper()
+	want, start, end, _ := checkLit(t, name, src)
+	for _, mode := range []string{modeZero, modeFuzz, modePlaceholder} {
+		for seed := int64(0); seed < 4; seed++ {
+			pkg, importNames, lit, typ := parseStruct(t, name, src)
+			named, _ := pkg.Scope().Lookup(lit.Type.(*ast.Ident).Name).Type().(*types.Named)
+			newlit, lines := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: named}, options{mode: mode, seed: seed})
+			code := printNode(t, name, newlit, lines)
+
+			filled := src[:start] + code + src[end:]
+			got, _, _, errs := checkLit(t, name, filled)
+			if got != want {
+				t.Errorf("%q, mode %s, seed %d: got type %s, want %s", name, mode, seed, got, want)
+			}
+			for _, err := range errs {
+				if off := err.Fset.Position(err.Pos).Offset; start <= off && off < start+len(code) {
+					t.Errorf("%q, mode %s, seed %d: %v in\n%s", name, mode, seed, err, code)
+				}
+			}
+		}
+	}
+}
+
+// checkLit type-checks src and returns the type of the literal of the
+// first variable declaration after the imports, its offsets and the
+// type errors of src.
+func checkLit(t *testing.T, name, src string) (typ string, start, end int, errs []types.Error) {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, 0)
+	if err != nil {
+		t.Fatalf("%q: %v\n%s", name, err, src)
 	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error:    func(err error) { errs = append(errs, err.(types.Error)) },
+	}
+	conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+
+	expr := f.Decls[1].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0]
+	return types.TypeString(info.Types[expr].Type, nil), fset.Position(expr.Pos()).Offset, fset.Position(expr.End()).Offset, errs
 }
 
 func parseStruct(t *testing.T, filename, src string) (*types.Package, map[string]string, *ast.CompositeLit, *types.Struct) {
@@ -669,3 +883,3581 @@ func printNode(t *testing.T, name string, n ast.Node, lines int) string {
 	}
 	return buf.String()
 }
+
+func TestFoldRegions(t *testing.T) {
+	code := `&User{
+	ID:   0,
+	Name: "",
+	Addr: &Address{
+		City: "",
+		ZIP:  0,
+	},
+	Tags: []string{},
+}`
+	tests := [...]struct {
+		name      string
+		code      string
+		threshold int
+		want      string
+	}{
+		{
+			name:      "below threshold",
+			code:      code,
+			threshold: 10,
+			want:      code,
+		},
+		{
+			name:      "above threshold",
+			code:      code,
+			threshold: 5,
+			want: `&User{
+	ID:   0,
+	Name: "",
+	// region Addr &Address
+	Addr: &Address{
+		City: "",
+		ZIP:  0,
+	},
+	// endregion
+	Tags: []string{},
+}`,
+		},
+		{
+			name: "elided type",
+			code: `{
+	{
+		a: 0,
+	},
+}`,
+			threshold: 1,
+			want: `{
+	// region [0]
+	{
+		a: 0,
+	},
+	// endregion
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := foldRegions(test.code, test.threshold, "// region", "// endregion")
+		if err != nil {
+			t.Fatalf("%q: %v", test.name, err)
+		}
+		if got != test.want {
+			t.Errorf("%q: got %v, want %v\n", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBasicValueRange(t *testing.T) {
+	tests := []struct {
+		kind  types.BasicKind
+		value string
+		ok    bool
+	}{
+		{kind: types.Int8, value: "127", ok: true},
+		{kind: types.Int8, value: "1000"},
+		{kind: types.Uint8, value: "255", ok: true},
+		{kind: types.Uint8, value: "-1"},
+		{kind: types.Int64, value: "1000", ok: true},
+		{kind: types.Float32, value: "1.5", ok: true},
+		{kind: types.Float32, value: "1e300"},
+		{kind: types.Float64, value: "1e300", ok: true},
+	}
+	for _, test := range tests {
+		typ := types.Typ[test.kind]
+		if _, ok := basicValue(typ, json.Number(test.value)); ok != test.ok {
+			t.Errorf("%s for %s: got %v, want %v", test.value, typ, ok, test.ok)
+		}
+	}
+}
+
+func TestWrapInt(t *testing.T) {
+	tests := []struct {
+		n    int
+		kind types.BasicKind
+		want int
+	}{
+		{n: 127, kind: types.Int8, want: 127},
+		{n: 128, kind: types.Int8, want: 1},
+		{n: 255, kind: types.Uint8, want: 255},
+		{n: 256, kind: types.Uint8, want: 1},
+		{n: 300, kind: types.Int, want: 300},
+	}
+	for _, test := range tests {
+		if got := wrapInt(test.n, types.Typ[test.kind]); got != test.want {
+			t.Errorf("%d for %s: got %d, want %d", test.n, types.Typ[test.kind], got, test.want)
+		}
+	}
+}
+
+func TestFillFromExample(t *testing.T) {
+	src := `package p
+
+import "io"
+
+var s = myStruct{}
+
+type myStruct struct {
+	ID      int64 ` + "`json:\"id\"`" + `
+	Name    string
+	Ratio   float64 ` + "`yaml:\"ratio\"`" + `
+	Active  bool
+	Tags    []string
+	Addr    *address
+	Labels  map[string]int
+	Data    interface{}
+	R       io.Reader
+	Missing string
+}
+
+type address struct {
+	City string ` + "`json:\"city\"`" + `
+	ZIP  uint
+}`
+	want := `myStruct{
+	ID:     42,
+	Name:   "frank",
+	Ratio:  0.5,
+	Active: true,
+	Tags: []string{
+		"a",
+		"b",
+	},
+	Addr: &address{
+		City: "Zurich",
+		ZIP:  8000,
+	},
+	Labels: map[string]int{
+		"x": 1,
+		"y": 2,
+	},
+	Data:    "any",
+	R:       nil,
+	Missing: "",
+}`
+
+	examples := map[string]string{
+		"example.json": `{
+	"id": 42,
+	"name": "frank",
+	"ratio": 0.5,
+	"active": true,
+	"tags": ["a", "b"],
+	"addr": {"city": "Zurich", "zip": 8000},
+	"labels": {"y": 2, "x": 1},
+	"data": "any",
+	"r": "ignored"
+}`,
+		"example.yaml": `id: 42
+name: frank
+ratio: 0.5
+active: true
+tags: [a, b]
+addr:
+  city: Zurich
+  zip: 8000
+labels:
+  y: 2
+  x: 1
+data: any
+r: ignored
+`,
+	}
+
+	dir := t.TempDir()
+	for filename, doc := range examples {
+		path := filepath.Join(dir, filename)
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		example, err := readExample(path)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+
+		pkg, importNames, lit, typ := parseStruct(t, filename, src)
+		name := types.NewNamed(types.NewTypeName(0, pkg, "myStruct", nil), typ, nil)
+		newlit, lines := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name, value: example}, options{})
+
+		out := printNode(t, filename, newlit, lines)
+		if want != out {
+			t.Errorf("%s: got %v, want %v\n", filename, out, want)
+		}
+	}
+}
+
+func TestFillLocalTypes(t *testing.T) {
+	tests := [...]struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "local types",
+			src: `package p
+
+func f() {
+	type address struct {
+		City string
+	}
+	type user struct {
+		Name  string
+		Addr  *address
+		Addrs [1]address
+		Inner struct {
+			A address
+		}
+	}
+	_ = user{}
+}`,
+			want: `user{
+	Name: "",
+	Addr: &address{
+		City: "",
+	},
+	Addrs: [1]address{
+		{
+			City: "",
+		},
+	},
+	Inner: struct{ A address }{
+		A: address{
+			City: "",
+		},
+	},
+}`,
+		},
+		{
+			name: "nested local types",
+			src: `package p
+
+type address struct {
+	Street string
+}
+
+func f() {
+	type address struct {
+		City string
+	}
+	func() {
+		type user struct {
+			Addr address
+			Next *user
+		}
+		_ = user{}
+	}()
+}`,
+			want: `user{
+	Addr: address{
+		City: "",
+	},
+	Next: &user{},
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, test.name, test.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+		pkg, err := (&types.Config{}).Check(f.Name.Name, fset, []*ast.File{f}, &info)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var lit *ast.CompositeLit
+		ast.Inspect(f, func(n ast.Node) bool {
+			if as, ok := n.(*ast.AssignStmt); ok {
+				lit = as.Rhs[0].(*ast.CompositeLit)
+			}
+			return lit == nil
+		})
+		name := info.Types[lit].Type.(*types.Named)
+		newlit, lines := zeroValue(pkg, buildImportNameMap(f), lit, litInfo{typ: name.Underlying(), name: name}, options{})
+
+		out := printNode(t, test.name, newlit, lines)
+		if test.want != out {
+			t.Errorf("%q: got %v, want %v\n", test.name, out, test.want)
+		}
+	}
+
+	// Local types of other packages cannot be referred to.
+	other := types.NewPackage("example.com/other", "other")
+	scope := types.NewScope(other.Scope(), token.NoPos, token.NoPos, "function")
+	obj := types.NewTypeName(token.NoPos, other, "hidden", nil)
+	scope.Insert(obj)
+	hidden := types.NewNamed(obj, types.NewStruct(nil, nil), nil)
+	if s, ok := typeString(types.NewPackage("example.com/p", "p"), nil, hidden); ok {
+		t.Errorf("got %q for local type of other package, want error", s)
+	}
+}
+
+func TestFillModes(t *testing.T) {
+	src := `package p
+
+import "io"
+
+var s = myStruct{}
+
+type list []list
+
+type myStruct struct {
+	a int
+	b bool
+	c float64
+	d string
+	e []string
+	f map[string]uint8
+	g *myStruct
+	h list
+	i io.Reader
+}`
+	tests := [...]struct {
+		mode string
+		want string
+	}{
+		{
+			mode: modeZero,
+			want: `myStruct{
+	a: 0,
+	b: false,
+	c: 0.0,
+	d: "",
+	e: []string{},
+	f: map[string]uint8{
+		"": 0,
+	},
+	g: &myStruct{},
+	h: []list{},
+	i: nil,
+}`,
+		},
+		{
+			mode: modeFuzz,
+			want: `myStruct{
+	a: 24,
+	b: true,
+	c: 79.3,
+	d: "zgbaicmr",
+	e: []string{
+		"jwwhth",
+	},
+	f: map[string]uint8{
+		"tcuax": 89,
+	},
+	g: &myStruct{},
+	h: []list{
+		{},
+	},
+	i: nil,
+}`,
+		},
+		{
+			mode: modePlaceholder,
+			want: `myStruct{
+	a: 1,
+	b: true,
+	c: 2.0,
+	d: "mystruct.d",
+	e: []string{
+		"mystruct.e[0]",
+	},
+	f: map[string]uint8{
+		"mystruct.f": 3,
+	},
+	g: &myStruct{},
+	h: []list{
+		{},
+	},
+	i: nil,
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		pkg, importNames, lit, typ := parseStruct(t, test.mode, src)
+		name := types.NewNamed(types.NewTypeName(0, pkg, "myStruct", nil), typ, nil)
+		newlit, lines := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name}, options{mode: test.mode, seed: 1})
+
+		out := printNode(t, test.mode, newlit, lines)
+		if test.want != out {
+			t.Errorf("%q: got %v, want %v\n", test.mode, out, test.want)
+		}
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	src := []byte("var a = A{}\nvar b = B{}\n")
+	outs := []output{
+		{Start: 8, End: 11, Code: "A{\n\tX: 0,\n}"},
+		{Start: 20, End: 23, Code: "B{\n\tY: \"\",\n}"},
+	}
+	got, err := applyEdits(src, outs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "var a = A{\n\tX: 0,\n}\nvar b = B{\n\tY: \"\",\n}\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := applyEdits(src, []output{{Start: 0, End: 10}, {Start: 5, End: 12}}); err == nil {
+		t.Errorf("expected error for overlapping edits")
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	code := `&User{
+	Name: "$HOME",
+	Tags: []string{},
+	Addr: Address{
+		ZIP: 0,
+	},
+	Labels: map[string]int{
+		"": 0,
+	},
+	Fn: func() { panic("not implemented") },
+}`
+	want := `&User{
+	Name: ${1:"\$HOME"},
+	Tags: ${2:[]string{\}},
+	Addr: Address{
+		ZIP: ${3:0},
+	},
+	Labels: map[string]int{
+		${4:""}: ${5:0},
+	},
+	Fn: ${6:func() { panic("not implemented") \}},
+}`
+	got, err := snippet(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writable := filepath.Join(dir, "w.go")
+	readOnly := filepath.Join(dir, "r.go")
+	for _, f := range []struct {
+		path string
+		perm os.FileMode
+	}{{writable, 0644}, {readOnly, 0444}} {
+		if err := os.WriteFile(f.path, []byte("package p\n"), f.perm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := [...]struct {
+		path string
+		want bool
+	}{
+		{path: writable, want: false},
+		{path: readOnly, want: true},
+		{path: filepath.Join(root, "p", "p.go"), want: true},
+		{path: filepath.Join(dir, "rootx", "p.go"), want: false},
+	}
+	for _, test := range tests {
+		if got := isReadOnly(test.path, []string{root}); got != test.want {
+			t.Errorf("isReadOnly(%q) = %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestTodoComments(t *testing.T) {
+	code := `User{
+	ID:   42,
+	Name: "",
+	Addr: &Address{
+		City:   "",
+		LatLng: [2]float64{0.0, 0.0},
+	},
+	Tags: map[string]string{
+		"": "",
+	},
+	Created: time.Time{},
+}`
+	want := `User{
+	ID:   42,
+	Name: "", // TODO: set value
+	Addr: &Address{
+		City:   "",
+		LatLng: [2]float64{0.0, 0.0}, // TODO: set value
+	},
+	Tags: map[string]string{ // TODO: set value
+		"": "",
+	},
+	Created: time.Time{}, // TODO: set value
+}`
+
+	existing := map[string]bool{"ID": true, "Addr": true, "Addr.City": true}
+	got, err := todoComments(code, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src, err := format.Source([]byte(got)); err != nil {
+		t.Fatal(err)
+	} else {
+		got = string(src)
+	}
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	src := `package p
+
+import "strings"
+
+var p = point{}
+
+type point struct {
+	X, Y int
+	Tags []string
+}`
+	tests := [...]struct {
+		width     int
+		todo      bool
+		typeHints bool
+		want      string
+	}{
+		{width: 0, want: "point{\n\tX:    0,\n\tY:    0,\n\tTags: []string{},\n}"},
+		{width: 40, want: "point{X: 0, Y: 0, Tags: []string{}}"},
+		{width: 35, want: "point{X: 0, Y: 0, Tags: []string{}}"},
+		{width: 34, want: "point{\n\tX:    0,\n\tY:    0,\n\tTags: []string{},\n}"},
+		{width: 40, todo: true, want: "point{\n\tX:    0,          // TODO: set value\n\tY:    0,          // TODO: set value\n\tTags: []string{}, // TODO: set value\n}"},
+		{width: 40, typeHints: true, want: "point{X: 0, Y: 0, Tags: []string{}}"},
+	}
+
+	for _, test := range tests {
+		pkg, importNames, lit, typ := parseStruct(t, "compact", src)
+		name := pkg.Scope().Lookup("point").Type().(*types.Named)
+		info := litInfo{typ: typ, name: name}
+		opts := options{maxWidth: test.width, todo: test.todo, typeHints: test.typeHints}
+		fi := newFillInfo(token.NewFileSet(), pkg, importNames, lit, info)
+		newlit, lines := zeroValue(pkg, importNames, lit, info, opts)
+		out, err := prepareOutput(newlit, lines, 0, 0, fi, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Code != test.want {
+			t.Errorf("width %d: got\n%s\nwant\n%s", test.width, out.Code, test.want)
+		}
+	}
+}
+
+func TestGofumptCode(t *testing.T) {
+	tests := [...]struct {
+		code string
+		want string
+	}{
+		{
+			code: "User{ID: 1,\n\tName: \"\"}",
+			want: "User{\n\tID:   1,\n\tName: \"\",\n}",
+		},
+		{
+			code: "{ID: 1,\n\tName: \"\"}",
+			want: "{\n\tID:   1,\n\tName: \"\",\n}",
+		},
+		{
+			code: "User{ID: 1, Name: \"\"}",
+			want: "User{ID: 1, Name: \"\"}",
+		},
+	}
+
+	for _, test := range tests {
+		got, err := gofumptCode(test.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("got\n%s\nwant\n%s", got, test.want)
+		}
+	}
+}
+
+func TestNolint(t *testing.T) {
+	src := `package p
+
+import "strings"
+
+var u = user{Name: "frank"}
+
+type user struct {
+	Name  string
+	Port  int
+	Cache map[string]string ` + "`fillstruct:\"-\"`" + `
+	Addr  address
+	sb    strings.Builder
+}
+
+type address struct {
+	ZIP int
+}`
+	want := `user{
+	Name: "frank",
+	Port: 0, //nolint:gomnd,exhaustruct // TODO: set value
+	Addr: address{
+		ZIP: 0, //nolint:gomnd,exhaustruct // TODO: set value
+	},
+	sb: strings.Builder{}, //nolint:gomnd,exhaustruct // TODO: set value
+}`
+
+	pkg, importNames, lit, typ := parseStruct(t, "nolint", src)
+	name := pkg.Scope().Lookup("user").Type().(*types.Named)
+	info := litInfo{typ: typ, name: name}
+	fi := newFillInfo(token.NewFileSet(), pkg, importNames, lit, info)
+	opts := options{nolint: "gomnd,exhaustruct", todo: true}
+	newlit, lines := zeroValue(pkg, importNames, lit, info, opts)
+	out, err := prepareOutput(newlit, lines, 0, 0, fi, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Code != want {
+		t.Errorf("got\n%s\nwant\n%s", out.Code, want)
+	}
+}
+
+func TestSetIndents(t *testing.T) {
+	code := "User{\n\tName: \"\",\n\tAddr: &Address{\n\t\tCity: \"\",\n\t},\n}"
+	tests := [...]struct {
+		src      string
+		reindent bool
+		indent   string
+		want     string
+	}{
+		{
+			src:    "package p\n\nvar u = User{}\n",
+			indent: "",
+			want:   code,
+		},
+		{
+			src:    "package p\n\nfunc f() {\n\tu := User{}\n}\n",
+			indent: "\t",
+			want:   code,
+		},
+		{
+			src:      "package p\n\nfunc f() {\n\tu := User{}\n}\n",
+			reindent: true,
+			indent:   "\t",
+			want:     "User{\n\t\tName: \"\",\n\t\tAddr: &Address{\n\t\t\tCity: \"\",\n\t\t},\n\t}",
+		},
+		{
+			src:      "package p\n\nfunc f() {\n  if true {\n    u := User{}\n  }\n}\n",
+			reindent: true,
+			indent:   "    ",
+			want:     "User{\n      Name: \"\",\n      Addr: &Address{\n        City: \"\",\n      },\n    }",
+		},
+	}
+
+	for _, test := range tests {
+		start := strings.Index(test.src, "User{}")
+		outs := []output{{Start: start, End: start + len("User{}"), Code: code}}
+		setIndents([]byte(test.src), outs, test.reindent)
+		if outs[0].Indent != test.indent {
+			t.Errorf("got indent %q, want %q", outs[0].Indent, test.indent)
+		}
+		if outs[0].Code != test.want {
+			t.Errorf("got\n%s\nwant\n%s", outs[0].Code, test.want)
+		}
+	}
+}
+
+func TestUndoEdit(t *testing.T) {
+	orig := "package p\n\nvar u = User{}\n"
+	start := len("package p\n\nvar u = ")
+	outs := []output{{Start: start, End: start + len("User{}"), Code: "User{\n\tName: \"\",\n}"}}
+	if err := setIDs([]byte(orig), outs); err != nil {
+		t.Fatal(err)
+	}
+	fill := outs[0]
+	filled, err := applyEdits([]byte(orig), []output{fill})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	undo, err := undoEdit(filled, start, fill.ID, []byte("User{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := applyEdits(filled, []output{undo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != orig {
+		t.Errorf("got after undo\n%s\nwant\n%s", got, orig)
+	}
+
+	redo, err := undoEdit(got, start, undo.ID, []byte(fill.Code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redo.ID != fill.ID {
+		t.Errorf("got redo ID %s, want %s", redo.ID, fill.ID)
+	}
+	if got, err = applyEdits(got, []output{redo}); err != nil {
+		t.Fatal(err)
+	} else if string(got) != string(filled) {
+		t.Errorf("got after redo\n%s\nwant\n%s", got, filled)
+	}
+
+	if _, err := undoEdit(filled, start, fill.ID, []byte("User{ID: 1}")); err == nil {
+		t.Error("expected an error for a mismatching edit")
+	}
+
+	// Editors indent the inserted code.
+	indented := "package p\n\nfunc f() {\n\tu := User{\n\t\tName: \"\",\n\t}\n}\n"
+	start = strings.Index(indented, "User{")
+	undo, err = undoEdit([]byte(indented), start, fill.ID, []byte("User{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err = applyEdits([]byte(indented), []output{undo}); err != nil {
+		t.Fatal(err)
+	} else if want := "package p\n\nfunc f() {\n\tu := User{}\n}\n"; string(got) != want {
+		t.Errorf("got after undo of indented code\n%s\nwant\n%s", got, want)
+	}
+
+	// The declarations of -share and filled calls are undone, too.
+	for _, test := range []struct {
+		orig, edited string
+		at           string
+		out          output
+	}{
+		{
+			orig:   "package p\n\nfunc f() {\n\tp := P{A1: a, A2: a}\n}\n",
+			edited: "package p\n\nfunc f() {\n\ta := A{\n\t\tX: 0,\n\t}\n\tp := P{A1: a, A2: a}\n}\n",
+			at:     "p := ",
+			out:    output{Code: "a := A{\n\tX: 0,\n}\n"},
+		},
+		{
+			orig:   "package p\n\nvar p = P{A1: a, A2: a}\n",
+			edited: "package p\n\nvar a = A{\n\tX: 0,\n}\n\nvar p = P{A1: a, A2: a}\n",
+			at:     "var p",
+			out:    output{Code: "var a = A{\n\tX: 0,\n}\n\n"},
+		},
+		{
+			orig:   "package p\n\nvar s = NewServer()\n",
+			edited: "package p\n\nvar s = NewServer(Config{\n\tAddr: \"\",\n}, nil)\n",
+			at:     "NewServer()",
+			out:    output{Code: "NewServer(Config{\n\tAddr: \"\",\n}, nil)"},
+		},
+	} {
+		out := test.out
+		out.Start = strings.Index(test.orig, test.at)
+		out.End = out.Start
+		if strings.HasSuffix(test.at, "()") {
+			out.End += len(test.at)
+		}
+		outs := []output{out}
+		if err := setIDs([]byte(test.orig), outs); err != nil {
+			t.Fatal(err)
+		}
+		orig := test.orig[out.Start:out.End]
+		undo, err := undoEdit([]byte(test.edited), out.Start, outs[0].ID, []byte(orig))
+		if err != nil {
+			t.Errorf("undo of %q: %v", out.Code, err)
+			continue
+		}
+		if got, err = applyEdits([]byte(test.edited), []output{undo}); err != nil {
+			t.Fatal(err)
+		} else if string(got) != test.orig {
+			t.Errorf("got after undo of %q\n%s\nwant\n%s", out.Code, got, test.orig)
+		}
+	}
+}
+
+func TestTypeHints(t *testing.T) {
+	src := `package p
+
+import "time"
+
+type Kind int
+
+type User struct {
+	ID    int
+	Kind  Kind
+	Addr  *Address
+	Wait  time.Duration
+	Err   error
+	Addrs []Address
+}
+
+type Address struct {
+	City string
+	Next *Address
+}
+
+var u = User{ID: 0}`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "fake.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	pkg, err := conf.Check("", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := `User{
+	ID:   0,
+	Kind: 0,
+	Addr: nil,
+	Wait: 0,
+	Err:  nil,
+	Addrs: []Address{
+		{
+			City: "",
+			Next: nil,
+		},
+	},
+}`
+	want := `User{
+	ID:   0,
+	Kind: 0, // Kind
+	Addr: nil, // *Address
+	Wait: 0, // time.Duration
+	Err:  nil, // error
+	Addrs: []Address{
+		{
+			City: "",
+			Next: nil, // *Address
+		},
+	},
+}`
+
+	fi := fillInfo{
+		pkg:      pkg,
+		typ:      pkg.Scope().Lookup("User").Type(),
+		existing: map[string]bool{"ID": true},
+	}
+	got, err := typeHints(code, fi, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFieldDocs(t *testing.T) {
+	src := `package p
+
+type User struct {
+	// ID identifies the user. It is unique.
+	ID   int
+	Name string // Name is the display name.
+	Addr *Address
+}
+
+type Address struct {
+	City string // city of the address
+	Info
+}
+
+// Info describes an address.
+type Info struct{}
+
+var u = User{ID: 0}`
+
+	// The declarations are looked up in the parsed
+	// file, which is not written to disk.
+	path := filepath.Join(t.TempDir(), "p.go")
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	pkg, err := conf.Check("", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := `User{
+	ID:   0,
+	Name: "",
+	Addr: &Address{
+		City: "",
+		Info: Info{},
+	},
+}`
+	fi := fillInfo{
+		fset:     fset,
+		pkg:      pkg,
+		typ:      pkg.Scope().Lookup("User").Type(),
+		existing: map[string]bool{"ID": true},
+	}
+	files := newSyntaxFiles(fset, []*packages.Package{{Syntax: []*ast.File{f}}})
+	got, err := fieldDocs(code, fi, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fieldDoc{
+		{Path: "Name", Line: 3, Decl: path + ":6", Doc: "Name is the display name."},
+		{Path: "Addr", Line: 4, Decl: path + ":7"},
+		{Path: "Addr.City", Line: 5, Decl: path + ":11", Doc: "city of the address"},
+		{Path: "Addr.Info", Line: 6, Decl: path + ":12"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d fields, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %+v, want %+v", got[i], want[i])
+		}
+	}
+}
+
+func TestFillDepth(t *testing.T) {
+	src := `package p
+
+import "time"
+
+var u = user{}
+
+type user struct {
+	Name  string
+	Addr  *address
+	Addrs []address
+}
+
+type address struct {
+	City string
+	Geo  geo
+	At   time.Time
+}
+
+type geo struct {
+	Lat, Lng float64
+}`
+	tests := [...]struct {
+		depth int
+		want  string
+	}{
+		{
+			depth: 1,
+			want: `user{
+	Name:  "",
+	Addr:  &address{},
+	Addrs: []address{},
+}`,
+		},
+		{
+			depth: 2,
+			want: `user{
+	Name: "",
+	Addr: &address{
+		City: "",
+		Geo:  geo{},
+		At:   time.Time{},
+	},
+	Addrs: []address{},
+}`,
+		},
+		{
+			depth: 0,
+			want: `user{
+	Name: "",
+	Addr: &address{
+		City: "",
+		Geo: geo{
+			Lat: 0.0,
+			Lng: 0.0,
+		},
+		At: time.Time{},
+	},
+	Addrs: []address{},
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		pkg, importNames, lit, typ := parseStruct(t, "depth", src)
+		name := pkg.Scope().Lookup("user").Type().(*types.Named)
+		newlit, lines := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name}, options{depth: test.depth})
+
+		out := printNode(t, "depth", newlit, lines)
+		if test.want != out {
+			t.Errorf("depth %d: got %v, want %v\n", test.depth, out, test.want)
+		}
+	}
+}
+
+func TestFillRequiredOnly(t *testing.T) {
+	src := "package p\n\nimport \"time\"\n\nvar u = user{}\n\n" +
+		"type user struct {\n" +
+		"\tName    string   `validate:\"required\"`\n" +
+		"\tEmail   string   `binding:\"required,email\"`\n" +
+		"\tBio     string   `validate:\"max=200\"`\n" +
+		"\tTags    []string `validate:\"dive,required\"`\n" +
+		"\tAddr    address\n" +
+		"\tBilling *address\n" +
+		"\tAt      time.Time\n" +
+		"}\n\n" +
+		"type address struct {\n" +
+		"\tCity string `validate:\"required\"`\n" +
+		"\tZIP  int\n" +
+		"}\n"
+	pkg, importNames, lit, typ := parseStruct(t, "required", src)
+	name := pkg.Scope().Lookup("user").Type().(*types.Named)
+	newlit, lines := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name}, options{requiredOnly: true})
+
+	want := `user{
+	Name:  "",
+	Email: "",
+	Addr: address{
+		City: "",
+	},
+}`
+	if out := printNode(t, "required", newlit, lines); out != want {
+		t.Errorf("got %v, want %v", out, want)
+	}
+}
+
+func TestFillRecursion(t *testing.T) {
+	src := `package p
+
+import "time"
+
+var n = node{}
+
+type node struct {
+	Val  int
+	Next *node
+}
+
+var _ time.Time
+`
+	tests := [...]struct {
+		recursion string
+		want      string
+	}{
+		{
+			recursion: recursionEmpty,
+			want: `node{
+	Val:  0,
+	Next: &node{},
+}`,
+		},
+		{
+			recursion: recursionNil,
+			want: `node{
+	Val:  0,
+	Next: nil,
+}`,
+		},
+		{
+			recursion: recursionOnce,
+			want: `node{
+	Val: 0,
+	Next: &node{
+		Val:  0,
+		Next: &node{},
+	},
+}`,
+		},
+	}
+	for _, test := range tests {
+		pkg, importNames, lit, typ := parseStruct(t, test.recursion, src)
+		name := pkg.Scope().Lookup("node").Type().(*types.Named)
+		newlit, lines := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name}, options{recursion: test.recursion})
+		if out := printNode(t, test.recursion, newlit, lines); out != test.want {
+			t.Errorf("%s: got %v, want %v", test.recursion, out, test.want)
+		}
+	}
+}
+
+// mapImporter imports the packages type-checked by the tests.
+type mapImporter map[string]*types.Package
+
+func (m mapImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := m[path]; ok {
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("package %s not found", path)
+}
+
+func TestProtoMessages(t *testing.T) {
+	stubs := map[string]string{
+		protoimplPath:   "package protoimpl\n\ntype MessageState struct{}\ntype SizeCache = int32\ntype UnknownFields = []byte\n",
+		wrapperspbPath:  "package wrapperspb\n\ntype StringValue struct{ Value string }\ntype Int64Value struct{ Value int64 }\n",
+		timestamppbPath: "package timestamppb\n\ntype Timestamp struct{ Seconds int64; Nanos int32 }\n",
+		durationpbPath:  "package durationpb\n\ntype Duration struct{ Seconds int64; Nanos int32 }\n",
+	}
+	imp := make(mapImporter)
+	fset := token.NewFileSet()
+	for path, src := range stubs {
+		f, err := parser.ParseFile(fset, path, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imp[path], err = (&types.Config{}).Check(path, fset, []*ast.File{f}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := `package pb
+
+import (
+	"google.golang.org/protobuf/runtime/protoimpl"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var u = User{}
+
+type User struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name    string
+	Nick    *wrapperspb.StringValue
+	Age     *wrapperspb.Int64Value
+	Created *timestamppb.Timestamp
+	TTL     *durationpb.Duration
+	Addr    *Address
+}
+
+type Address struct {
+	City             string
+	XXX_unrecognized []byte
+	internal         int
+}
+`
+	f, err := parser.ParseFile(fset, "user.pb.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	pkg, err := (&types.Config{Importer: imp}).Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit := f.Decls[1].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.CompositeLit)
+	name := pkg.Scope().Lookup("User").Type().(*types.Named)
+	newlit, lines := zeroValue(pkg, buildImportNameMap(f), lit, litInfo{typ: name.Underlying(), name: name}, options{})
+
+	want := `User{
+	Name:    "",
+	Nick:    wrapperspb.String(""),
+	Age:     wrapperspb.Int64(0),
+	Created: &timestamppb.Timestamp{},
+	TTL:     durationpb.New(0),
+	Addr: &Address{
+		City: "",
+	},
+}`
+	if out := printNode(t, "proto", newlit, lines); out != want {
+		t.Errorf("got %v, want %v", out, want)
+	}
+}
+
+func TestEmitJSON(t *testing.T) {
+	src := "package p\n\nimport \"time\"\n\nvar i = item{}\n\n" +
+		"type base struct {\n" +
+		"\tID      int64     `json:\"id\"`\n" +
+		"\tCreated time.Time `json:\"created\"`\n" +
+		"}\n\n" +
+		"type item struct {\n" +
+		"\tbase\n" +
+		"\tSKU    string         `json:\"sku\"`\n" +
+		"\tCount  int            `json:\"count,omitempty\"`\n" +
+		"\tPrice  float32        `json:\"price,string\"`\n" +
+		"\tNote   string         `json:\"-\"`\n" +
+		"\tTags   []string       `json:\"tags\"`\n" +
+		"\tRaw    []byte         `json:\"raw\"`\n" +
+		"\tAttrs  map[string]int `json:\"attrs\"`\n" +
+		"\tNext   *item          `json:\"next,omitempty\"`\n" +
+		"\tC      chan int\n" +
+		"\tOK     [2]bool\n" +
+		"\thidden int\n" +
+		"}\n"
+	pkg, importNames, lit, typ := parseStruct(t, "emitjson", src)
+	name := pkg.Scope().Lookup("item").Type().(*types.Named)
+	newlit, _ := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name}, options{})
+
+	got, err := jsonExample(newlit, name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"id":0,"created":"0001-01-01T00:00:00Z","sku":"","price":"0","tags":[],"raw":"",` +
+		`"attrs":{"":0},"next":{"id":0,"created":"0001-01-01T00:00:00Z","sku":"","price":"0",` +
+		`"tags":null,"raw":null,"attrs":null,"OK":[false,false]},"OK":[false,false]}`
+	if string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestEmitJSONConflicts(t *testing.T) {
+	src := "package p\n\ntype s struct{}\n\nvar o = outer{}\n\n" +
+		"type a struct {\n" +
+		"\tName  string\n" +
+		"\tID    int\n" +
+		"\tColor string `json:\"color\"`\n" +
+		"}\n\n" +
+		"type b struct {\n" +
+		"\tName  string\n" +
+		"\tID    int `json:\"ID\"`\n" +
+		"\tColor string `json:\"color\"`\n" +
+		"\tSize  int\n" +
+		"}\n\n" +
+		"type outer struct {\n" +
+		"\ta\n" +
+		"\tb\n" +
+		"\tSize int `json:\"Size,omitempty\"`\n" +
+		"}\n"
+	pkg, importNames, lit, typ := parseStruct(t, "emitjson", src)
+	name := pkg.Scope().Lookup("outer").Type().(*types.Named)
+	newlit, _ := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name}, options{})
+
+	got, err := jsonExample(newlit, name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Name and color conflict, the tagged ID of b wins and the omitted
+	// Size of outer hides the one of b.
+	want := `{"ID":0}`
+	if string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	// Color is left out since vet reports its repeated tag.
+	type a struct {
+		Name string
+		ID   int
+	}
+	type b struct {
+		Name string
+		ID   int `json:"ID"`
+		Size int
+	}
+	type outer struct {
+		a
+		b
+		Size int `json:"Size,omitempty"`
+	}
+	if std, _ := json.Marshal(outer{}); string(std) != want {
+		t.Errorf("encoding/json: got %s, want %s", std, want)
+	}
+}
+
+func TestFillOrder(t *testing.T) {
+	src := `package p
+
+import "strings"
+
+var u = user{Zone: "CET", Addr: &address{ZIP: 8000}}
+
+type user struct {
+	Name string
+	Zone string
+	Addr *address
+	Age  int
+	sb   strings.Builder
+}
+
+type address struct {
+	City string
+	ZIP  int
+}`
+	tests := [...]struct {
+		order string
+		want  string
+	}{
+		{
+			order: orderDecl,
+			want: `user{
+	Name: "",
+	Zone: "CET",
+	Addr: &address{
+		City: "",
+		ZIP:  8000,
+	},
+	Age: 0,
+	sb:  strings.Builder{},
+}`,
+		},
+		{
+			order: orderAlpha,
+			want: `user{
+	Addr: &address{
+		City: "",
+		ZIP:  8000,
+	},
+	Age:  0,
+	Name: "",
+	Zone: "CET",
+	sb:   strings.Builder{},
+}`,
+		},
+		{
+			order: orderKeep,
+			want: `user{
+	Zone: "CET",
+	Addr: &address{
+		ZIP:  8000,
+		City: "",
+	},
+	Name: "",
+	Age:  0,
+	sb:   strings.Builder{},
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		pkg, importNames, lit, typ := parseStruct(t, "order", src)
+		name := pkg.Scope().Lookup("user").Type().(*types.Named)
+		newlit, lines := zeroValue(pkg, importNames, lit, litInfo{typ: typ, name: name}, options{order: test.order})
+
+		out := printNode(t, "order", newlit, lines)
+		if test.want != out {
+			t.Errorf("order %s: got %v, want %v\n", test.order, out, test.want)
+		}
+	}
+}
+
+func TestListLiterals(t *testing.T) {
+	src := `package p
+
+import "sync"
+
+type user struct {
+	Name string
+	Addr *address
+}
+
+type address struct {
+	City, ZIP string
+}
+
+var (
+	u = user{Name: "frank", Addr: &address{City: "Zurich"}}
+	a = []address{{}, {"Bern", "3000"}}
+	m = sync.Mutex{}
+	s = []string{}
+)`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "list.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := types.Config{Importer: importer.Default()}
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		line    int
+		typ     string
+		missing int
+	}{
+		{line: 15, typ: "user", missing: 0},
+		{line: 15, typ: "address", missing: 1},
+		{line: 16, typ: "address", missing: 2},
+		{line: 17, typ: "sync.Mutex", missing: 0},
+	}
+	got := literals(fset, f, pkg, &info)
+	if len(got) != len(want) {
+		t.Fatalf("got %d literals, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Line != w.line || got[i].Type != w.typ || got[i].Missing != w.missing {
+			t.Errorf("literal %d: got %+v, want %+v", i, got[i], w)
+		}
+		if lit := src[got[i].Start:got[i].End]; !strings.HasSuffix(lit, "}") {
+			t.Errorf("literal %d: invalid range %q", i, lit)
+		}
+	}
+}
+
+func TestFillNamedCollections(t *testing.T) {
+	src := `package p
+
+type config struct {
+	Name string
+	Port int
+}
+
+type configs map[string]config
+
+type users []*config
+
+type pair [2]int
+
+var (
+	_ = configs{}
+	_ = users{}
+	_ = pair{}
+	_ = configs{"a": {Port: 80}, "b": config{}}
+)`
+	want := []string{
+		`configs{
+	"": {
+		Name: "",
+		Port: 0,
+	},
+}`,
+		`users{
+	{
+		Name: "",
+		Port: 0,
+	},
+}`,
+		`pair{
+	0,
+	0,
+}`,
+		`configs{
+	"a": {
+		Name: "",
+		Port: 80,
+	},
+	"b": {
+		Name: "",
+		Port: 0,
+	},
+}`,
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "named.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	pkg, err := (&types.Config{}).Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lits []*ast.CompositeLit
+	for _, spec := range f.Decls[len(f.Decls)-1].(*ast.GenDecl).Specs {
+		lits = append(lits, spec.(*ast.ValueSpec).Values[0].(*ast.CompositeLit))
+	}
+	for i, lit := range lits {
+		typ := info.Types[lit].Type
+		if !isFillable(typ) {
+			t.Fatalf("%d: %v is not fillable", i, typ)
+		}
+		name := typ.(*types.Named)
+		newlit, lines := zeroValue(pkg, buildImportNameMap(f), lit, litInfo{typ: name.Underlying(), name: name}, options{})
+
+		out := printNode(t, "named", newlit, lines)
+		if want[i] != out {
+			t.Errorf("%d: got %v, want %v\n", i, out, want[i])
+		}
+	}
+}
+
+func TestPurge(t *testing.T) {
+	src := `package p
+
+type kind int
+
+const none kind = 0
+
+type user struct {
+	ID     int
+	Name   string
+	Kind   kind
+	Admin  bool
+	Addr   *address
+	Home   address
+	LatLng [2]float64
+	Tags   []string
+	Attrs  map[string]address
+	Next   *user
+}
+
+type address struct {
+	City string
+	ZIP  int
+}
+
+var u = user{
+	ID:   0,
+	Name: "frank",
+	Kind: none,
+	Admin: false,
+	Addr: &address{
+		City: "",
+		ZIP:  8000,
+	},
+	Home: address{
+		City: "",
+		ZIP:  0,
+	},
+	LatLng: [2]float64{0.0, 0.0},
+	Tags:   []string{""},
+	Attrs: map[string]address{
+		"": {City: ""},
+	},
+	Next: &user{ID: 0},
+}`
+	want := `user{
+	Name: "frank",
+	Kind: none,
+	Addr: &address{
+		ZIP: 8000,
+	},
+	Tags: []string{
+		"",
+	},
+	Attrs: map[string]address{
+		"": {},
+	},
+	Next: &user{},
+}`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "purge.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	if _, err := (&types.Config{}).Check(f.Name.Name, fset, []*ast.File{f}, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	lit := f.Decls[len(f.Decls)-1].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.CompositeLit)
+	purge(&info, lit)
+	fl := filler{pos: 1}
+	fl.fixExprPos(lit)
+
+	if got := printNode(t, "purge", lit, fl.lines); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestKeyify(t *testing.T) {
+	src := `package p
+
+type user struct {
+	ID    int
+	Name  string
+	Addr  *address
+	Addrs []address
+}
+
+type address struct {
+	City string
+	ZIP  int
+}
+
+var u = user{1, "frank", &address{"Zurich", 8000}, []address{{"Bern", 3000}}}`
+	want := `user{
+	ID:   1,
+	Name: "frank",
+	Addr: &address{
+		City: "Zurich",
+		ZIP:  8000,
+	},
+	Addrs: []address{
+		{
+			City: "Bern",
+			ZIP:  3000,
+		},
+	},
+}`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "keyify.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	pkg, err := (&types.Config{}).Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lit := f.Decls[len(f.Decls)-1].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.CompositeLit)
+	if !isPositional(&info, lit) {
+		t.Fatal("expected a positional literal")
+	}
+	keyify(&info, lit)
+
+	name := info.Types[lit].Type.(*types.Named)
+	newlit, lines := zeroValue(pkg, nil, lit, litInfo{typ: name.Underlying(), name: name}, options{})
+	if got := printNode(t, "keyify", newlit, lines); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUnkeyify(t *testing.T) {
+	src := `package p
+
+type User struct {
+	ID    int
+	Name  string
+	Addr  *Address
+	Addrs []Address
+}
+
+type Address struct {
+	City string
+	ZIP  int
+}
+
+type secret struct {
+	ID    int
+	token string
+}
+
+var (
+	a = User{Name: "frank", ID: 1, Addr: &Address{ZIP: 8000, City: "Zurich"}, Addrs: []Address{{City: "Bern"}}}
+	b = User{ID: 1, Name: "frank"}
+	c = secret{ID: 1, token: "t"}
+	d = User{1, "frank", nil, nil}
+)`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "unkeyify.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	if _, err := (&types.Config{}).Check(f.Name.Name, fset, []*ast.File{f}, &info); err != nil {
+		t.Fatal(err)
+	}
+	lits := make(map[string]*ast.CompositeLit)
+	for _, spec := range f.Decls[len(f.Decls)-1].(*ast.GenDecl).Specs {
+		vs := spec.(*ast.ValueSpec)
+		lits[vs.Names[0].Name] = vs.Values[0].(*ast.CompositeLit)
+	}
+
+	if err := unkeyify(&info, lits["a"]); err != nil {
+		t.Fatal(err)
+	}
+	f2 := filler{pos: 1, flat: true}
+	f2.fixExprPos(lits["a"])
+	want := `User{1, "frank", &Address{"Zurich", 8000}, []Address{{City: "Bern"}}}`
+	if got := printNode(t, "unkeyify", lits["a"], f2.lines); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	for _, name := range []string{"b", "c", "d"} {
+		if err := unkeyify(&info, lits[name]); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestFillCall(t *testing.T) {
+	src := `package p
+
+type Config struct {
+	Addr string
+	Port int
+}
+
+func NewServer(cfg Config, c *Config, f func() error, opts ...string) {}
+
+func f() {
+	NewServer()
+	NewServer(Config{}, nil, nil)
+	g()
+}
+
+func g() {}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "call.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := types.Config{Error: func(error) {}}
+	pkg, _ := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	pos := func(s string) token.Pos {
+		return fset.File(f.Pos()).Pos(strings.Index(src, s) + len(s) - 1)
+	}
+
+	call, sig := findCall(f, &info, pos("NewServer()"))
+	if call == nil {
+		t.Fatal("expected a call")
+	}
+	p := &packages.Package{Fset: fset, Types: pkg, TypesInfo: &info}
+	out, err := fillCall(p, nil, call, sig, 0, 0, options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `NewServer(Config{
+	Addr: "",
+	Port: 0,
+}, &Config{
+	Addr: "",
+	Port: 0,
+}, func() error { panic("not implemented") })`
+	if out.Code != want {
+		t.Errorf("got\n%s\nwant\n%s", out.Code, want)
+	}
+
+	for _, s := range []string{"NewServer(Config{}, nil", "g("} {
+		if call, _ := findCall(f, &info, pos(s)); call != nil {
+			t.Errorf("%s: unexpected call %s", s, types.ExprString(call))
+		}
+	}
+}
+
+func TestShare(t *testing.T) {
+	src := `package p
+
+type Address struct {
+	City string
+}
+
+type Order struct {
+	Billing  Address
+	Shipping Address
+	Ptr      *Address
+}
+
+func f() {
+	address := 1
+	_ = address
+	o := Order{}
+	_ = o
+}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "share.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := strings.Index(src, "Order{}")
+	lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(offset+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{shareable: make(map[*ast.CompositeLit]bool)}
+	newlit, _ := zeroValue(pkg, nil, lit, linfo, opts)
+	out, ok, err := shareLit(fset, f, pkg, lit, newlit, opts.shareable, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected a shared literal")
+	}
+	want := "address2 := Address{\n\tCity: \"\",\n}\n"
+	if out.Code != want {
+		t.Errorf("got declaration\n%s\nwant\n%s", out.Code, want)
+	}
+	if stmt := strings.Index(src, "o := Order{}"); out.Start != stmt || out.End != stmt {
+		t.Errorf("got declaration at [%d, %d), want %d", out.Start, out.End, stmt)
+	}
+
+	f2 := filler{pos: 1}
+	f2.fixExprPos(newlit)
+	code, err := printExpr(newlit, f2.lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `Order{
+	Billing:  address2,
+	Shipping: address2,
+	Ptr: &Address{
+		City: "",
+	},
+}`
+	if code != want {
+		t.Errorf("got literal\n%s\nwant\n%s", code, want)
+	}
+}
+
+func TestShareStatement(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.go")
+	src := "package p\n\ntype A struct{ X int }\n\ntype B struct{ Y string }\n\n" +
+		"type P struct{ A1, A2 A }\n\ntype Q struct{ B1, B2 B }\n\n" +
+		"type address struct{ Z bool }\n\ntype R struct{ C1, C2 address }\n\n" +
+		"type S struct{ D1, D2 address }\n\n" +
+		"func f() {\n\t_, _, _, _ = P{}, Q{}, R{}, S{}\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module p\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &packages.Config{Mode: packages.LoadAllSyntax, Dir: dir, Fset: token.NewFileSet()}
+	pkgs, err := packages.Load(cfg, "file="+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var offsets intList
+	for _, lit := range []string{"P{}", "Q{}", "R{}", "S{}", "P{}"} {
+		offsets = append(offsets, strings.Index(src, lit))
+	}
+	louts, errs := fillEach(context.Background(), pkgs, path, offsets, nil, options{share: true})
+	var outs []output
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("offset %d: %v", offsets[i], err)
+		}
+		outs = append(outs, louts[i]...)
+	}
+	res, err := applyEdits([]byte(src), outermostEdits(outs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The names of the variables of different literals differ, the
+	// ones of the same literal filled twice do not.
+	for _, decl := range []string{"a := A{", "b := B{", "address2 := address{", "address3 := address{"} {
+		if n := strings.Count(string(res), decl); n != 1 {
+			t.Errorf("got %d declarations %q, want 1 in\n%s", n, decl, res)
+		}
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", res, 0); err != nil {
+		t.Errorf("invalid code %v:\n%s", err, res)
+	}
+}
+
+func TestShareOnlyZeroValues(t *testing.T) {
+	src := `package p
+
+type Address struct {
+	City string
+}
+
+type Tags struct {
+	Names []string
+}
+
+type Order struct {
+	Billing  Address
+	Shipping Address
+	From     Tags
+	To       Tags
+}
+
+func f() {
+	o := Order{Billing: Address{City: "Bern"}, Shipping: Address{City: "Bern"}}
+	_ = o
+}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "share.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := strings.Index(src, "Order{")
+	lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(offset+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := options{shareable: make(map[*ast.CompositeLit]bool)}
+	newlit, _ := zeroValue(pkg, nil, lit, linfo, opts)
+	if out, ok, err := shareLit(fset, f, pkg, lit, newlit, opts.shareable, nil); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Errorf("got declaration\n%s\nwant none: the addresses are existing literals and the tags contain slices", out.Code)
+	}
+}
+
+func TestPreserveComments(t *testing.T) {
+	src := `package p
+
+type Address struct {
+	City string
+	ZIP  int
+}
+
+type User struct {
+	ID   int
+	Name string
+	Addr Address
+}
+
+var u = User{
+	// ID is the primary key.
+	ID: 1, // positive
+	Addr: Address{
+		City: "Bern", /* capital */
+	},
+}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "comments.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, "User{")+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi := newFillInfo(fset, pkg, nil, lit, linfo)
+	fi.comments = elementComments(fset, f.Comments, lit)
+	newlit, lines := zeroValue(pkg, nil, lit, linfo, options{})
+	out, err := prepareOutput(newlit, lines, 0, 0, fi, options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `User{
+	// ID is the primary key.
+	ID:   1, // positive
+	Name: "",
+	Addr: Address{
+		City: "Bern", /* capital */
+		ZIP:  0,
+	},
+}`
+	if out.Code != want {
+		t.Errorf("got\n%s\nwant\n%s", out.Code, want)
+	}
+}
+
+func TestAlignComments(t *testing.T) {
+	code := `User{
+	ID:   0,
+	Kind: 0, // Kind
+	Addr: nil, // *Address
+	Handler: nil, // ` + shorten("func(context.Context, *http.Request) (*http.Response, error)", 40) + `
+}`
+	want := `User{
+	ID:      0,
+	Kind:    0,   // Kind
+	Addr:    nil, // *Address
+	Handler: nil, // func(context.Context, *http.Request) (*…
+}`
+	got, err := alignComments(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSkipDeprecated(t *testing.T) {
+	src := `package p
+
+type Config struct {
+	Addr string
+
+	// Timeout is the timeout in seconds.
+	//
+	// Deprecated: Use Deadline instead.
+	Timeout  int
+	Deadline int
+	Legacy   bool // Deprecated: unused.
+	// NotDeprecated: a field.
+	Retries int
+}
+
+var c = Config{Legacy: true}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "config.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, "Config{L")+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newlit, lines := zeroValue(pkg, nil, lit, linfo, options{deprecated: newDeprecations(fset, newSyntaxFiles(fset, []*packages.Package{{Syntax: []*ast.File{f}}}))})
+	code, err := printExpr(newlit, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Config{
+	Addr:     "",
+	Deadline: 0,
+	Legacy:   true,
+	Retries:  0,
+}`
+	if code != want {
+		t.Errorf("got\n%s\nwant\n%s", code, want)
+	}
+}
+
+func TestIgnoreDirective(t *testing.T) {
+	src := `package p
+
+type Cache struct {
+	mu      int //fillstruct:ignore
+	Entries map[string]int
+	//fillstruct:ignore not set by callers
+	Hits  int
+	Stats *Stats
+	Name  string
+}
+
+//fillstruct:ignore
+type Stats struct{ Total int }
+
+var c = Cache{Hits: 1}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "cache.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, "Cache{H")+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := newSyntaxFiles(fset, []*packages.Package{{Syntax: []*ast.File{f}}})
+	var ignored []string
+	newlit, lines := zeroValue(pkg, nil, lit, linfo, options{directives: newDirectives(fset, files), ignored: &ignored})
+	code, err := printExpr(newlit, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Cache{
+	Entries: map[string]int{
+		"": 0,
+	},
+	Hits: 1,
+	Name: "",
+}`
+	if code != want {
+		t.Errorf("got\n%s\nwant\n%s", code, want)
+	}
+	if want := []string{"mu", "Stats"}; !reflect.DeepEqual(ignored, want) {
+		t.Errorf("got ignored %v, want %v", ignored, want)
+	}
+}
+
+func TestFieldFilters(t *testing.T) {
+	src := `package p
+
+type Address struct {
+	City string
+	ZIP  int
+}
+
+type User struct {
+	ID   int
+	Name string
+	Addr *Address
+	Home Address
+}
+
+var u = User{ID: 1}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "filter.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := [...]struct {
+		only, ignore string
+		want         string
+	}{
+		{only: `^(Name|Addr\.ZIP)$`, want: "User{\n\tID:   1,\n\tName: \"\",\n\tAddr: &Address{\n\t\tZIP: 0,\n\t},\n}"},
+		{only: `^Home$`, want: "User{\n\tID: 1,\n\tHome: Address{\n\t\tCity: \"\",\n\t\tZIP:  0,\n\t},\n}"},
+		{only: `^Missing$`, want: "User{\n\tID: 1,\n}"},
+		{ignore: `^(Addr|City)$`, want: "User{\n\tID:   1,\n\tName: \"\",\n\tHome: Address{\n\t\tZIP: 0,\n\t},\n}"},
+		{only: `Addr|Home`, ignore: `ZIP`, want: "User{\n\tID: 1,\n\tAddr: &Address{\n\t\tCity: \"\",\n\t},\n\tHome: Address{\n\t\tCity: \"\",\n\t},\n}"},
+	}
+	for _, test := range tests {
+		lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, "User{ID")+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var opts options
+		if opts.only, err = compileFilter(test.only); err != nil {
+			t.Fatal(err)
+		}
+		if opts.ignore, err = compileFilter(test.ignore); err != nil {
+			t.Fatal(err)
+		}
+		newlit, lines := zeroValue(pkg, nil, lit, linfo, opts)
+		got, err := printExpr(newlit, lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("only %q, ignore %q: got\n%s\nwant\n%s", test.only, test.ignore, got, test.want)
+		}
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in := "# example.com/cmd\n" +
+		path + ":12:5: missing return\n" +
+		path + ":7:\tcfg := Config{}\n" +
+		path + ":9\n" +
+		"no position here\n"
+	got, err := parsePositions(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []position{{path: path, line: 12, col: 5}, {path: path, line: 7}, {path: path, line: 9}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestOutermostEdits(t *testing.T) {
+	outs := []output{
+		{Start: 50, End: 60, Code: "inner"},
+		{Start: 10, End: 20, Code: "first"},
+		{Start: 40, End: 70, Code: "outer"},
+		{Start: 10, End: 20, Code: "first"},
+		{Start: 30, End: 30, Code: "insertion;"},
+		{Start: 30, End: 30, Code: "other;"},
+		{Start: 30, End: 30, Code: "insertion;"},
+		{Start: 10, End: 10, Code: "before;"},
+	}
+	var got []string
+	for _, out := range outermostEdits(outs) {
+		got = append(got, out.Code)
+	}
+	if want := []string{"before;", "first", "insertion;other;", "outer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExportedOnly(t *testing.T) {
+	src := `package p
+
+type User struct {
+	ID    int
+	name  string
+	Inner Inner
+}
+
+type Inner struct {
+	secret, Public bool
+}
+
+var u = User{}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "exported.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, "User{}")+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newlit, lines := zeroValue(pkg, nil, lit, linfo, options{exportedOnly: true})
+	got, err := printExpr(newlit, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `User{
+	ID: 0,
+	Inner: Inner{
+		Public: false,
+	},
+}`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	src := `package p
+
+type Base struct {
+	ID int
+}
+
+type User struct {
+	Base
+	*Meta
+	Name string
+}
+
+type Meta struct {
+	Tags []string
+}
+
+var u = User{}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "embedded.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := [...]struct {
+		embedded string
+		want     string
+	}{
+		{embedded: embeddedFill, want: "User{\n\tBase: Base{\n\t\tID: 0,\n\t},\n\tMeta: &Meta{\n\t\tTags: []string{},\n\t},\n\tName: \"\",\n}"},
+		{embedded: embeddedSkip, want: "User{\n\tName: \"\",\n}"},
+	}
+	for _, test := range tests {
+		lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, "User{}")+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		newlit, lines := zeroValue(pkg, nil, lit, linfo, options{embedded: test.embedded})
+		got, err := printExpr(newlit, lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.embedded, got, test.want)
+		}
+	}
+}
+
+func TestCheckRefill(t *testing.T) {
+	// The source after applying the edits to:
+	// var a = A{}
+	// var b = A{}
+	src := `package p
+
+type A struct {
+	X int
+}
+
+var a = A{
+	X: 0,
+}
+
+var b = A{}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "refill.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	var conf types.Config
+	tpkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs := []*packages.Package{{Fset: fset, Syntax: []*ast.File{f}, Types: tpkg, TypesInfo: info}}
+
+	a := strings.Index(src, "A{\n")
+	b := strings.Index(src, "A{}") - len("A{\n\tX: 0,\n}") + len("A{}")
+	filledA := output{Start: a, End: a + len("A{}"), Code: "A{\n\tX: 0,\n}"}
+
+	if err := checkRefill(pkgs, "refill.go", []output{filledA}, options{}); err != nil {
+		t.Errorf("stable fill: got error %v", err)
+	}
+
+	// An edit which leaves b unfilled is not stable, since b is filled again.
+	unfilledB := output{Start: b, End: b + len("A{}"), Code: "A{}"}
+	err = checkRefill(pkgs, "refill.go", []output{unfilledB, filledA}, options{})
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("offset %d", strings.Index(src, "A{}"))) {
+		t.Errorf("unstable fill: got error %v", err)
+	}
+}
+
+func TestDropFoldMarkers(t *testing.T) {
+	comments := map[string]elementComment{
+		"Addr": {lead: []string{"// region Addr &Address", "// the address"}},
+		"Tags": {lead: []string{"// endregion"}},
+		"Name": {lead: []string{"// region"}, trail: "// display name"},
+		"ID":   {lead: []string{"// regional ID"}},
+	}
+	dropFoldMarkers(comments, "// region", " // endregion")
+	want := map[string]elementComment{
+		"Addr": {lead: []string{"// the address"}},
+		"Name": {trail: "// display name"},
+		"ID":   {lead: []string{"// regional ID"}},
+	}
+	if !reflect.DeepEqual(comments, want) {
+		t.Errorf("got %v, want %v", comments, want)
+	}
+}
+
+func TestShallowUnexported(t *testing.T) {
+	src := `package p
+
+type Cache struct {
+	hits int
+	Size int
+}
+
+type Options struct {
+	Name string
+}
+
+type Server struct {
+	Opts  Options
+	Cache *Cache
+	local Cache
+}
+
+var s = Server{}
+
+var c = Cache{}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "shallow.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := [...]struct {
+		lit  string
+		want string
+	}{
+		{lit: "Server{}", want: "Server{\n\tOpts: Options{\n\t\tName: \"\",\n\t},\n\tCache: &Cache{},\n\tlocal: Cache{},\n}"},
+		// The literal being filled is always expanded.
+		{lit: "Cache{}", want: "Cache{\n\thits: 0,\n\tSize: 0,\n}"},
+	}
+	for _, test := range tests {
+		lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, test.lit)+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		newlit, lines := zeroValue(pkg, nil, lit, linfo, options{shallow: true})
+		got, err := printExpr(newlit, lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.lit, got, test.want)
+		}
+	}
+}
+
+func TestFillStructSequence(t *testing.T) {
+	src := `package p
+
+type User struct {
+	ID   int
+	Name string
+}
+
+var us = []User{{}, {Name: "frank"}, u}
+
+var u User
+
+var ids = []int{1, 2}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "sequence.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, "[]User{")+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newlit, lines := zeroValue(pkg, nil, lit, linfo, options{})
+	got, err := printExpr(newlit, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[]User{\n\t{\n\t\tID:   0,\n\t\tName: \"\",\n\t},\n\t{\n\t\tID:   0,\n\t\tName: \"frank\",\n\t},\n\tu,\n}"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if _, _, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, "[]int{")+1)); !errors.Is(err, errNotFound) {
+		t.Errorf("slice of ints: got error %v, want %v", err, errNotFound)
+	}
+}
+
+func TestPickCompositeLit(t *testing.T) {
+	src := `package p
+
+type Inner struct{ A int }
+
+type Outer struct {
+	Inner Inner
+	Tags  []string
+}
+
+var o = Outer{Inner: Inner{}, Tags: []string{}}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "pick.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	pkg, err := (&types.Config{}).Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := fset.File(f.Pos()).Pos(strings.Index(src, "Inner{}") + 1)
+
+	for pick, want := range []string{"Inner", "Outer"} {
+		lit, _, err := pickCompositeLit(fset, f, &info, pos, pick)
+		if err != nil {
+			t.Fatalf("pick %d: %v", pick, err)
+		}
+		if got := lit.Type.(*ast.Ident).Name; got != want {
+			t.Errorf("pick %d: got %s, want %s", pick, got, want)
+		}
+	}
+	if _, _, err := pickCompositeLit(fset, f, &info, pos, 2); err == nil || errors.Is(err, errNotFound) {
+		t.Errorf("pick 2: got error %v, want an error about the number of literals", err)
+	}
+
+	cands := candidates(fset, f, &info, pkg, nil, pos)
+	want := []candidate{
+		{Pick: 0, Type: "Inner", Start: strings.Index(src, "Inner{}"), End: strings.Index(src, "Inner{}") + 7, Line: 10},
+		{Pick: 1, Type: "Outer", Start: strings.Index(src, "Outer{"), End: len(src), Line: 10},
+	}
+	if !reflect.DeepEqual(cands, want) {
+		t.Errorf("got candidates %+v, want %+v", cands, want)
+	}
+
+	// The slice of strings cannot be filled, but its enclosing literal.
+	pos = fset.File(f.Pos()).Pos(strings.Index(src, "[]string{}") + 3)
+	if cands := candidates(fset, f, &info, pkg, nil, pos); len(cands) != 1 || cands[0].Pick != 1 {
+		t.Errorf("got candidates %+v, want Outer only", cands)
+	}
+}
+
+func TestFillStructMap(t *testing.T) {
+	src := `package p
+
+type Point struct {
+	X, Y int
+}
+
+type User struct {
+	ID   int
+	Name string
+}
+
+var users = map[string]User{"a": {}, "b": u}
+
+var u User
+
+var labels = map[Point]string{{X: 1}: "x"}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "map.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := [...]struct {
+		lit  string
+		want string
+	}{
+		{lit: "map[string]User{", want: "map[string]User{\n\t\"a\": {\n\t\tID:   0,\n\t\tName: \"\",\n\t},\n\t\"b\": u,\n}"},
+		{lit: "map[Point]string{", want: "map[Point]string{\n\t{\n\t\tX: 1,\n\t\tY: 0,\n\t}: \"x\",\n}"},
+	}
+	for _, test := range tests {
+		lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, test.lit)+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		newlit, lines := zeroValue(pkg, nil, lit, linfo, options{})
+		got, err := printExpr(newlit, lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.lit, got, test.want)
+		}
+	}
+}
+
+func TestMissingSums(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "internal", "db")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := "missing go.sum entry for module providing package example.com/db (imported by example.com/app)"
+	dep := &packages.Package{PkgPath: "example.com/db", Errors: []packages.Error{{Msg: msg}}}
+	pkg := &packages.Package{
+		PkgPath: "example.com/app",
+		Errors:  []packages.Error{{Msg: "could not import example.com/db"}},
+		Imports: map[string]*packages.Package{"example.com/db": dep},
+	}
+	other := &packages.Package{PkgPath: "example.com/app_test", Imports: map[string]*packages.Package{"example.com/db": dep}}
+
+	serr := missingSums(sub, []*packages.Package{pkg, other}, nil)
+	if serr == nil {
+		t.Fatal("got no error, want a missing go.sum entry")
+	}
+	if serr.dir != dir {
+		t.Errorf("got module directory %q, want %q", serr.dir, dir)
+	}
+	if want := []string{msg}; !reflect.DeepEqual(serr.msgs, want) {
+		t.Errorf("got messages %q, want %q", serr.msgs, want)
+	}
+
+	if serr := missingSums(sub, []*packages.Package{other}, nil); serr == nil {
+		t.Error("dependency: got no error, want a missing go.sum entry")
+	}
+	if serr := missingSums(sub, []*packages.Package{{PkgPath: "p"}}, nil); serr != nil {
+		t.Errorf("got error %v, want none", serr)
+	}
+}
+
+func TestFillArrays(t *testing.T) {
+	src := `package p
+
+type Point struct {
+	X, Y int
+}
+
+type Points [2]Point
+
+var a = [2]Point{}
+
+var b = [...]Point{{X: 1}}
+
+var c = Points{{}, {Y: 2}}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "arrays.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := [...]struct {
+		name string
+		lit  string
+		want string
+	}{
+		{name: "empty array", lit: "[2]Point{", want: "[2]Point{\n\t{\n\t\tX: 0,\n\t\tY: 0,\n\t},\n\t{\n\t\tX: 0,\n\t\tY: 0,\n\t},\n}"},
+		{name: "elided length", lit: "[...]Point{", want: "[...]Point{\n\t{\n\t\tX: 1,\n\t\tY: 0,\n\t},\n}"},
+		{name: "element of named array", lit: "{Y: 2}", want: "{\n\tX: 0,\n\tY: 2,\n}"},
+	}
+	for _, test := range tests {
+		lit, linfo, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(strings.Index(src, test.lit)+1))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		newlit, lines := zeroValue(pkg, nil, lit, linfo, options{})
+		got, err := printExpr(newlit, lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestIntList(t *testing.T) {
+	var l intList
+	for _, s := range []string{"120", "356, 980"} {
+		if err := l.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := (intList{120, 356, 980}); !reflect.DeepEqual(l, want) {
+		t.Errorf("got %v, want %v", l, want)
+	}
+	if got, want := l.String(), "120,356,980"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := l.at(3); got != 0 {
+		t.Errorf("got %d for a missing element, want 0", got)
+	}
+	if err := l.Set("1,x"); err == nil {
+		t.Error("got no error for an invalid list")
+	}
+}
+
+func TestSetRegions(t *testing.T) {
+	src := []byte("package p\n\nvar u = User{\n\tID: 1,\n}\n")
+	start := bytes.Index(src, []byte("User{"))
+	end := bytes.LastIndexByte(src, '}') + 1
+	outs := []output{
+		{Start: start, End: end},
+		{Start: len(src), End: len(src)},
+	}
+	setRegions(src, outs)
+
+	if got := outs[0]; got.StartLine != 3 || got.StartCol != 9 || got.EndLine != 5 || got.EndCol != 2 {
+		t.Errorf("got region %d:%d-%d:%d, want 3:9-5:2", got.StartLine, got.StartCol, got.EndLine, got.EndCol)
+	}
+	if got, want := outs[0].Hash, regionHash([]byte("User{\n\tID: 1,\n}")); got != want {
+		t.Errorf("got hash %s, want %s", got, want)
+	}
+	if got := outs[1]; got.StartLine != 6 || got.StartCol != 1 {
+		t.Errorf("got start %d:%d at the end of the file, want 6:1", got.StartLine, got.StartCol)
+	}
+	if got, want := outs[1].Hash, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"; got != want {
+		t.Errorf("got hash %s of the empty region, want %s", got, want)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	src := []byte("package p\n\nvar s, u = \"h\u00e9\U0001F600\", User{}\n")
+	start := bytes.Index(src, []byte("User{"))
+
+	tests := [...]struct {
+		encoding string
+		off      int // of User{} in the units of encoding
+		col      int
+	}{
+		{encoding: encodingUTF8, off: start, col: start - 10},
+		{encoding: encodingUTF16, off: start - 1 - 2, col: start - 10 - 1 - 2},
+		{encoding: encodingUTF32, off: start - 1 - 3, col: start - 10 - 1 - 3},
+	}
+	for _, test := range tests {
+		if got := encodedOffset(src, start, test.encoding); got != test.off {
+			t.Errorf("%s: got offset %d, want %d", test.encoding, got, test.off)
+		}
+		if got := encodedCol(src, start, test.encoding); got != test.col {
+			t.Errorf("%s: got column %d, want %d", test.encoding, got, test.col)
+		}
+		if got, err := byteOffset(src, test.off, test.encoding); err != nil || got != start {
+			t.Errorf("%s: got byte offset %d, %v, want %d", test.encoding, got, err, start)
+		}
+
+		outs := []output{{Start: start, End: start + len("User{}"), Candidates: []candidate{{Start: start}}}}
+		setRegions(src, outs)
+		got := encodePositions(src, outs, test.encoding)[0]
+		if got.Start != test.off || got.End != test.off+len("User{}") || got.StartCol != test.col || got.Candidates[0].Start != test.off {
+			t.Errorf("%s: got edit %+v, want start %d and column %d", test.encoding, got, test.off, test.col)
+		}
+		if outs[0].Start != start {
+			t.Errorf("%s: the edits are modified", test.encoding)
+		}
+	}
+
+	// An offset between the code units of the emoji is the start of it.
+	emoji := bytes.Index(src, []byte("\U0001F600"))
+	if got, err := byteOffset(src, emoji-1+1, encodingUTF16); err != nil || got != emoji {
+		t.Errorf("got byte offset %d, %v inside of the emoji, want %d", got, err, emoji)
+	}
+	if _, err := byteOffset(src, len(src), encodingUTF16); err == nil {
+		t.Error("got no error for an offset beyond the end of the file")
+	}
+}
+
+func TestWindowsFiles(t *testing.T) {
+	src := []byte(bom + "package p\r\n\r\nvar u = User{}\r\n")
+	start := bytes.Index(src, []byte("User{}"))
+	end := start + len("User{}")
+
+	// Offsets do not count the byte order mark, like editors.
+	if got, err := byteOffset(src, start-len(bom), encodingUTF8); err != nil || got != start {
+		t.Errorf("got byte offset %d, %v, want %d", got, err, start)
+	}
+	if got := encodedOffset(src, start, encodingUTF8); got != start-len(bom) {
+		t.Errorf("got offset %d, want %d", got, start-len(bom))
+	}
+	if got := encodedCol(src, len(bom)+len("pack"), encodingUTF16); got != 5 {
+		t.Errorf("got column %d on the first line, want 5", got)
+	}
+
+	outs := []output{{Start: start, End: end, Code: "User{\n\tID: 0,\n}"}}
+	setIndents(src, outs, true)
+	setLineEndings(src, outs)
+	if want := "User{\r\n\tID: 0,\r\n}"; outs[0].Code != want {
+		t.Errorf("got code %q, want %q", outs[0].Code, want)
+	}
+	setRegions(src, outs)
+	if got := encodePositions(src, outs, encodingUTF8)[0]; got.Start != start-len(bom) || got.StartLine != 3 || got.StartCol != 9 {
+		t.Errorf("got start %d at %d:%d, want %d at 3:9", got.Start, got.StartLine, got.StartCol, start-len(bom))
+	}
+
+	res, err := applyEdits(src, outs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bom + "package p\r\n\r\nvar u = User{\r\n\tID: 0,\r\n}\r\n"
+	if string(res) != want {
+		t.Errorf("got %q, want %q", res, want)
+	}
+
+	// Formatting, e.g. by goimports, drops both.
+	formatted := []byte("package p\n\nvar u = User{\n\tID: 0,\n}\n")
+	if got := restoreFile(src, formatted); string(got) != want {
+		t.Errorf("got restored file %q, want %q", got, want)
+	}
+	if got := restoreFile(formatted, formatted); !bytes.Equal(got, formatted) {
+		t.Errorf("got restored file %q, want it unchanged", got)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	src := []byte("package p\n\nfunc f() {\n\t_ = User{\n\t\tID: 1,\n\t}\n}\n")
+	start := bytes.Index(src, []byte("User{"))
+	end := bytes.LastIndex(src, []byte("}\n}")) + 1
+
+	tests := [...]struct {
+		code string
+		want string
+	}{
+		{code: "User{\n\tID: 1,\n}", want: statusUnchanged},
+		{code: "User{\n\t\tID: 1,\n\t}", want: statusUnchanged}, // with -indent
+		{code: "User{\n\tID:   1,\n\tName: \"\",\n}", want: statusChanged},
+	}
+	for _, test := range tests {
+		outs := []output{{Start: start, End: end, Code: test.code}}
+		setRegions(src, outs)
+		if outs[0].Status != test.want {
+			t.Errorf("%q: got status %q, want %q", test.code, outs[0].Status, test.want)
+		}
+		if got := unchanged(outs); got != (test.want == statusUnchanged) {
+			t.Errorf("%q: got unchanged %v", test.code, got)
+		}
+	}
+	if unchanged(nil) {
+		t.Error("got unchanged without edits")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	src := `package p
+
+type User struct {
+	ID   int
+	Name string
+}
+
+var ids = []int{1, 2}
+
+var names = map[string]int{"a": 1}
+
+var us = []User{u}
+
+var u User`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "notfound.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	var conf types.Config
+	if _, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lit    string
+		typ    string
+		reason string
+	}{
+		{lit: "[]int{", typ: "[]int", reason: "slice of int, only structs and named map, slice and array types are filled"},
+		{lit: "map[string]int{", typ: "map[string]int", reason: "map of int, only structs and named map, slice and array types are filled"},
+		{lit: "[]User{", typ: "[]p.User", reason: "slice literal with elements but no element literals to fill"},
+	}
+	for _, test := range tests {
+		offset := strings.Index(src, test.lit)
+		_, _, err := findCompositeLit(fset, f, &info, fset.File(f.Pos()).Pos(offset+1))
+		if !errors.Is(err, errNotFound) {
+			t.Fatalf("%s: got error %v, want %v", test.lit, err, errNotFound)
+		}
+		var buf bytes.Buffer
+		if err := writeNotFound(&buf, err); err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf(`{"error":"no struct literal found at selection","literal":{"start":%d,"end":%d,"line":%d,"type":%q,"reason":%q}}`+"\n",
+			offset, offset+strings.Index(src[offset:], "}")+1, strings.Count(src[:offset], "\n")+1, test.typ, test.reason)
+		if got := buf.String(); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.lit, got, want)
+		}
+	}
+}
+
+func TestCheckCgo(t *testing.T) {
+	const path = "/cgo/buf.go"
+	src := `package cgo
+
+// #include <stdlib.h>
+import "C"
+
+type Buf struct {
+	N    C.int
+	Data []byte
+}
+
+var b = Buf{}`
+	cfg := &packages.Config{
+		Fset:    token.NewFileSet(),
+		Overlay: map[string][]byte{path: []byte(src)},
+	}
+	pkg := &packages.Package{
+		PkgPath:         "cgo",
+		Name:            "cgo",
+		GoFiles:         []string{path},
+		CompiledGoFiles: []string{"/cache/buf.cgo1.go", "/cache/_cgo_gotypes.go"},
+		Types:           types.NewPackage("cgo", "cgo"),
+	}
+	checkCgo(cfg, []*packages.Package{pkg})
+	if len(pkg.Syntax) != 1 {
+		t.Fatalf("got %d files, want 1", len(pkg.Syntax))
+	}
+
+	f := pkg.Syntax[0]
+	lit, linfo, err := findCompositeLit(cfg.Fset, f, pkg.TypesInfo, cfg.Fset.File(f.Pos()).Pos(strings.Index(src, "Buf{}")+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newlit, lines := zeroValue(pkg.Types, nil, lit, linfo, options{})
+	got, err := printExpr(newlit, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Buf{\n\tData: []byte{},\n}"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWorkspaceEnv(t *testing.T) {
+	env := []string{"HOME=/home/me", "GOFLAGS=-mod=mod -tags=integration", "GOPROXY=off"}
+	got := workspaceEnv(env)
+	want := []string{"HOME=/home/me", "GOFLAGS=-tags=integration", "GOPROXY=off"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if env[1] != "GOFLAGS=-mod=mod -tags=integration" {
+		t.Errorf("env changed: %q", env[1])
+	}
+
+	for _, flags := range []string{"GOFLAGS=-mod=readonly", "GOFLAGS=-mod=vendor", "GOFLAGS="} {
+		if got := workspaceEnv([]string{flags}); got[0] != flags {
+			t.Errorf("got %q, want %q", got[0], flags)
+		}
+	}
+}
+
+func TestIsAdHoc(t *testing.T) {
+	t.Setenv("GO111MODULE", "on")
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+	dir := t.TempDir()
+	if !isAdHoc(dir) {
+		t.Errorf("%s without go.mod: got false, want true", dir)
+	}
+	// The environment of the go command is read once per directory.
+	dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if isAdHoc(dir) {
+		t.Errorf("%s with go.mod: got true, want false", dir)
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage p\n", want: true},
+		{src: "// Copyright 2026.\n\n// Code generated by stringer; DO NOT EDIT.\n\n// Package p is generated.\npackage p\n", want: true},
+		{src: "// Code generated by hand, but edit it.\npackage p\n", want: false},
+		{src: "package p\n\n// Code generated by protoc-gen-go. DO NOT EDIT.\n", want: false},
+		{src: "/* Code generated by yacc. DO NOT EDIT. */\npackage p\n", want: false},
+		{src: "// Code generated by yacc. DO NOT EDIT\npackage p\n", want: false},
+	}
+	for _, test := range tests {
+		if got := isGenerated([]byte(test.src)); got != test.want {
+			t.Errorf("isGenerated(%q) = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestLineDirectives(t *testing.T) {
+	src := `package ld
+
+//line types.y:10
+type P struct {
+	X int // X is the abscissa.
+}
+
+//line parser.y:100
+func f() {
+	_ = P{}
+}`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gen.go")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	var conf types.Config
+	tpkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &packages.Package{Fset: fset, Syntax: []*ast.File{f}, Types: tpkg, TypesInfo: &info}
+
+	pkgs := []*packages.Package{pkg}
+	outs, err := byLine(pkgs, path, 10, withSyntax(fset, pkgs, options{fieldDocs: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outs) != 1 {
+		t.Fatalf("got %d edits, want 1", len(outs))
+	}
+	out := outs[0]
+	if start := strings.Index(src, "P{}"); out.Start != start || out.End != start+len("P{}") {
+		t.Errorf("got edit [%d, %d), want [%d, %d)", out.Start, out.End, start, start+len("P{}"))
+	}
+	want := []fieldDoc{{Path: "X", Line: 2, Decl: filepath.Join(dir, "types.y") + ":11", Doc: "X is the abscissa."}}
+	if !reflect.DeepEqual(out.Fields, want) {
+		t.Errorf("got fields %+v, want %+v", out.Fields, want)
+	}
+}
+
+func TestSyntacticPackage(t *testing.T) {
+	srcs := []string{
+		"package p\n\nvar P = 1 // stale, P is being turned into a type\n",
+		`package p
+
+type P struct {
+	X, Y int
+	Q    []Q
+}
+
+type Q struct{ S string }
+
+func f(n int) {
+	x := P{X: n}
+	y := []P{{}}
+	z := Q{}
+	_, _, _ = x, y, z
+}`,
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for i, src := range srcs {
+		f, err := parser.ParseFile(fset, fmt.Sprintf("/p/%d.go", i), src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	info := types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Error: func(error) {}}
+	tpkg, _ := conf.Check("p", fset, files, &info)
+	pkg := &packages.Package{PkgPath: "p", Fset: fset, Syntax: files, Types: tpkg, TypesInfo: &info}
+	pkgs := []*packages.Package{pkg}
+
+	tests := []struct {
+		line int
+		want string
+	}{
+		{line: 11, want: "P{\n\tX: n,\n\tY: 0,\n\tQ: []Q{},\n}"},
+		{line: 12, want: "{\n\tX: 0,\n\tY: 0,\n\tQ: []Q{},\n}"},
+	}
+	for _, test := range tests {
+		outs, err := byLine(pkgs, "/p/1.go", test.line, options{})
+		if err != nil {
+			t.Fatalf("line %d: %v", test.line, err)
+		}
+		if len(outs) != 1 || outs[0].Code != test.want {
+			t.Errorf("line %d: got %+v, want code\n%s", test.line, outs, test.want)
+		}
+	}
+
+	offset := strings.Index(srcs[1], "P{X")
+	outs, err := byOffset(pkgs, "/p/1.go", offset, options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outs) != 1 || outs[0].Code != tests[0].want {
+		t.Errorf("offset %d: got %+v, want code\n%s", offset, outs, tests[0].want)
+	}
+
+	// Literals whose types are known are not checked again.
+	pos := fset.File(files[1].Pos()).Pos(strings.Index(srcs[1], "Q{}"))
+	if spkg := syntacticPackage(pkg, files[1], pos); spkg != nil {
+		t.Errorf("got a package for a literal of a known type")
+	}
+}
+
+func TestLoadTests(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		tests *bool
+		paths []string
+		want  bool
+	}{
+		{tests: nil, paths: []string{"/p/a.go"}, want: false},
+		{tests: nil, paths: []string{"/p/a_test.go"}, want: true},
+		{tests: nil, paths: []string{"/p/a.go", "/p/b_test.go"}, want: true},
+		{tests: &yes, paths: []string{"/p/a.go"}, want: true},
+		{tests: &no, paths: []string{"/p/a_test.go"}, want: false},
+	}
+	for _, test := range tests {
+		if got := loadTests(test.tests, test.paths...); got != test.want {
+			t.Errorf("loadTests(%v, %q) = %v, want %v", test.tests, test.paths, got, test.want)
+		}
+	}
+}
+
+func TestPackagesDriver(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	tests := []struct {
+		env  []string
+		want string
+	}{
+		{env: []string{"HOME=/home/me"}, want: ""},
+		{env: []string{"GOPACKAGESDRIVER=/usr/bin/bazel-driver"}, want: "/usr/bin/bazel-driver"},
+		{env: []string{"GOPACKAGESDRIVER=/usr/bin/bazel-driver", "GOPACKAGESDRIVER=off"}, want: ""},
+		{env: []string{"GOPACKAGESDRIVER="}, want: ""},
+	}
+	for _, test := range tests {
+		if got := packagesDriver(test.env); got != test.want {
+			t.Errorf("packagesDriver(%q) = %q, want %q", test.env, got, test.want)
+		}
+	}
+
+	driver := filepath.Join(os.Getenv("PATH"), "gopackagesdriver")
+	if err := os.WriteFile(driver, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := packagesDriver(nil); got != driver {
+		t.Errorf("packagesDriver(nil) = %q, want %q", got, driver)
+	}
+	if got := packagesDriver([]string{"GOPACKAGESDRIVER=off"}); got != "" {
+		t.Errorf("packagesDriver with GOPACKAGESDRIVER=off = %q, want \"\"", got)
+	}
+}
+
+func TestStamps(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{"a.go": "package p\n", "b.go": "package p\n", "notes.txt": "", "a_test.go": "package p\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.go"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	got := stamps(dir)
+	var names []string
+	for path := range got {
+		names = append(names, filepath.Base(path))
+	}
+	sort.Strings(names)
+	if want := []string{"a.go", "a_test.go", "b.go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got files %q, want %q", names, want)
+	}
+
+	path := filepath.Join(dir, "a.go")
+	before := stamps(path)
+	if len(before) != 1 {
+		t.Fatalf("got %d stamps of a file, want 1", len(before))
+	}
+	if err := os.WriteFile(path, []byte("package p\n\nvar x int\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if after := stamps(path); reflect.DeepEqual(before, after) {
+		t.Errorf("stamps did not change: %v", after)
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p.go")
+	write := func(src string) {
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package p\n\ntype P struct{ X int }\n")
+	cfg := &packages.Config{Fset: token.NewFileSet()}
+	pkg := &packages.Package{PkgPath: "p", Name: "p", GoFiles: []string{path}}
+	if !checkFiles(cfg, pkg) {
+		t.Fatal("checkFiles failed")
+	}
+	fields := func() int {
+		return pkg.Types.Scope().Lookup("P").Type().Underlying().(*types.Struct).NumFields()
+	}
+	if got := fields(); got != 1 {
+		t.Errorf("got %d fields, want 1", got)
+	}
+
+	// Errors while editing are fine.
+	write("package p\n\ntype P struct{ X, Y int }\n\nfunc f() { x := }\n")
+	if !checkFiles(cfg, pkg) {
+		t.Fatal("checkFiles failed for a file with errors")
+	}
+	if got := fields(); got != 2 {
+		t.Errorf("got %d fields, want 2", got)
+	}
+	files := 0
+	cfg.Fset.Iterate(func(*token.File) bool {
+		files++
+		return true
+	})
+	if files != 1 {
+		t.Errorf("got %d files in the file set, want only the last one", files)
+	}
+
+	// New imports need the packages to be loaded again.
+	write("package p\n\nimport \"time\"\n\ntype P struct{ X, Y int; T time.Time }\n")
+	tpkg := pkg.Types
+	if checkFiles(cfg, pkg) {
+		t.Error("checkFiles succeeded for a new import")
+	}
+	if pkg.Types != tpkg {
+		t.Error("checkFiles changed the package for a new import")
+	}
+}
+
+func TestParallel(t *testing.T) {
+	for _, workers := range []int{0, 1, 4, 100} {
+		calls := make([]int, 10)
+		parallel(len(calls), workers, func(i int) { calls[i]++ })
+		for i, n := range calls {
+			if n != 1 {
+				t.Errorf("%d workers: got %d calls for %d, want 1", workers, n, i)
+			}
+		}
+	}
+	if n := fillWorkers(options{keyify: true}); n != 1 {
+		t.Errorf("got %d workers for -keyify, want 1", n)
+	}
+}
+
+func TestFillEach(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.go")
+	src := "package p\n\ntype P struct {\n\tX int\n\tY string\n}\n\nvar (\n"
+	for i := 0; i < 20; i++ {
+		src += "\t_ = P{}\n"
+	}
+	src += ")\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module p\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &packages.Config{Mode: packages.LoadAllSyntax, Dir: dir, Fset: token.NewFileSet()}
+	pkgs, err := packages.Load(cfg, "file="+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines intList
+	for i := 0; i < 20; i++ {
+		lines = append(lines, 9+i)
+	}
+	outs, errs := fillEach(context.Background(), pkgs, path, nil, lines, options{})
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("line %d: %v", lines[i], err)
+		}
+		want, err := fillAt(pkgs, path, 0, lines[i], options{})
+		if err != nil {
+			t.Fatalf("line %d: %v", lines[i], err)
+		}
+		if !reflect.DeepEqual(outs[i], want) {
+			t.Errorf("line %d: got %+v, want %+v", lines[i], outs[i], want)
+		}
+	}
+}
+
+func TestFillEachOverlapping(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.go")
+	src := "package p\n\ntype P struct {\n\tX int\n\tY string\n}\n\ntype Q struct {\n\tA P\n\tB []int\n\tC int\n}\n\nvar _ = Q{\n\t// lead\n\tA: P{X: 1}, // trail\n\tB: []int{1, 2},\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module p\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	load := func() []*packages.Package {
+		cfg := &packages.Config{Mode: packages.LoadAllSyntax, Dir: dir, Fset: token.NewFileSet()}
+		pkgs, err := packages.Load(cfg, "file="+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkgs
+	}
+
+	// The outer and the inner literal are filled many times over,
+	// such that the existing elements are filled concurrently.
+	var offsets intList
+	for i := 0; i < 20; i++ {
+		offsets = append(offsets, strings.Index(src, "Q{"), strings.Index(src, "P{X"))
+	}
+	want := make(map[int][]output)
+	pkgs := load()
+	for _, off := range offsets[:2] {
+		outs, err := fillAt(pkgs, path, off, 0, options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The syntax trees are not changed by filling.
+		again, err := fillAt(pkgs, path, off, 0, options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again, outs) {
+			t.Errorf("offset %d: got %+v when filled again, want %+v", off, again, outs)
+		}
+		want[off] = outs
+	}
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	outs, errs := fillEach(context.Background(), load(), path, offsets, nil, options{})
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("offset %d: %v", offsets[i], err)
+		}
+		if !reflect.DeepEqual(outs[i], want[offsets[i]]) {
+			t.Errorf("offset %d: got %+v, want %+v", offsets[i], outs[i], want[offsets[i]])
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.go")
+	if err := os.WriteFile(path, []byte("package p\n\ntype P struct{ X int }\n\nvar _ = P{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module p\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := withTimeout(time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	cfg := newConfig(ctx, token.NewFileSet(), dir, nil, nil, false)
+	_, _, err := loadPackages(cfg, []string{"file=" + path}, false)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	err = timedOut(ctx, 5*time.Second, err)
+	var buf bytes.Buffer
+	if err := writeTimeout(&buf, err); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"error":"timed out after 5s, rerun with a larger -timeout","timeout":"5s"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// Errors of a context which has not expired are kept.
+	if err := timedOut(context.Background(), time.Second, errNotFound); err != errNotFound {
+		t.Errorf("got %v, want %v", err, errNotFound)
+	}
+
+	cfg.Context = context.Background()
+	pkgs, _, err := loadPackages(cfg, []string{"file=" + path}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs := fillEach(ctx, pkgs, path, nil, intList{5}, options{})
+	if !errors.Is(errs[0], context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", errs[0], context.DeadlineExceeded)
+	}
+}
+
+func TestStartProfiles(t *testing.T) {
+	dir := t.TempDir()
+	cpu, mem, trc := filepath.Join(dir, "cpu.out"), filepath.Join(dir, "mem.out"), filepath.Join(dir, "trace.out")
+	stop, err := startProfiles(cpu, mem, trc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	stop() // no effect
+	for _, name := range []string{cpu, mem, trc} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() == 0 {
+			t.Errorf("%s is empty", filepath.Base(name))
+		}
+	}
+
+	if _, err := startProfiles(filepath.Join(dir, "missing", "cpu.out"), "", ""); err == nil {
+		t.Error("got no error for a CPU profile in a missing directory")
+	}
+}
+
+func TestScopeVars(t *testing.T) {
+	const src = `package p
+
+type addr struct{ City string }
+
+type server struct {
+	Name string
+	Home addr
+	Work addr
+	Port int
+	Peer *server
+}
+
+var global server
+
+func f(name string, home addr, n int) {
+	work := addr{}
+	peer := &server{}
+	{
+		name := "shadowed"
+		_ = server{}
+		_ = name
+	}
+	later := addr{}
+	_, _, _ = work, peer, later
+}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf types.Config
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := token.Pos(f.FileStart + token.Pos(strings.Index(src, "_ = server{}")))
+
+	vars := scopeVars(pkg, pos)
+	var names []string
+	for _, v := range vars {
+		names = append(names, v.Name())
+	}
+	sort.Strings(names)
+	if want := []string{"home", "n", "name", "peer", "work"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got variables %v, want %v", names, want)
+	}
+
+	s := pkg.Scope().Lookup("server").Type().Underlying().(*types.Struct)
+	for i, want := range []string{"name", "home", "work", "", "peer"} {
+		got := ""
+		if v := scopeVar(vars, s.Field(i)); v != nil {
+			got = v.Name()
+			if pos := fset.Position(v.Pos()); got == "name" && pos.Line != 19 {
+				t.Errorf("field %s: got the variable name of line %d, want the one shadowing the parameter", s.Field(i).Name(), pos.Line)
+			}
+		}
+		if got != want {
+			t.Errorf("field %s: got variable %q, want %q", s.Field(i).Name(), got, want)
+		}
+	}
+}
+
+func TestConstructorParams(t *testing.T) {
+	const src = `package p
+
+type peer struct{ Addr string }
+
+type server struct {
+	Addr string
+	Port int
+	Peer peer
+}
+
+func newServer(addr string, port string) (*server, error) {
+	return &server{}, nil
+}
+
+func configure(addr string) server {
+	if addr := ""; addr == "" {
+		return server{}
+	}
+	return server{}
+}
+
+func other(addr string) {
+	_ = server{}
+}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+	}
+	var conf types.Config
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lits []*ast.CompositeLit
+	ast.Inspect(f, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.CompositeLit); ok {
+			lits = append(lits, lit)
+		}
+		return true
+	})
+
+	// The parameter addr of configure is shadowed at its first literal.
+	for i, want := range [][]string{{"addr", "port"}, nil, {"addr"}, nil} {
+		var got []string
+		for _, p := range constructorParams(pkg, info, f, lits[i]) {
+			got = append(got, p.Name())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("literal %d: got parameters %v, want %v", i, got, want)
+		}
+	}
+
+	lit := lits[0]
+	name := info.TypeOf(lit).(*types.Named)
+	opts := options{params: constructorParams(pkg, info, f, lit)}
+	newlit, lines := zeroValue(pkg, nil, lit, litInfo{typ: name.Underlying(), name: name}, opts)
+	want := `server{
+	Addr: addr,
+	Port: 0,
+	Peer: peer{
+		Addr: "",
+	},
+}`
+	if got := printNode(t, "constructor", newlit, lines); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestIsStructCollectionIllTyped(t *testing.T) {
+	s := types.NewStruct(nil, nil)
+	m := types.NewMap(s, s)
+	// map[struct{}]struct{}{{}} lacks the key of its element.
+	lit := &ast.CompositeLit{Elts: []ast.Expr{&ast.CompositeLit{}}}
+	if !isStructCollection(m, lit) {
+		t.Error("got false, want true for a map of struct literals")
+	}
+	lit.Elts[0] = &ast.Ident{Name: "x"}
+	if isStructCollection(m, lit) {
+		t.Error("got true, want false without struct literals")
+	}
+}
+
+func TestPurgePositional(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.go")
+	src := "package p\n\ntype U struct{ ID, N int }\n\nvar u = U{1, 0}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module p\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &packages.Config{Mode: packages.LoadAllSyntax, Dir: dir, Fset: token.NewFileSet()}
+	pkgs, err := packages.Load(cfg, "file="+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offsets := intList{strings.Index(src, "U{1")}
+	if _, errs := fillEach(context.Background(), pkgs, path, offsets, nil, options{purge: true}); errs[0] == nil {
+		t.Error("expected an error for -purge on a positional literal")
+	}
+	outs, errs := fillEach(context.Background(), pkgs, path, offsets, nil, options{purge: true, keyify: true})
+	if errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	if want := "U{\n\tID: 1,\n}"; len(outs[0]) != 1 || outs[0][0].Code != want {
+		t.Errorf("got %+v, want code %q", outs[0], want)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// foldRegions wraps every multi-line element of the literal in code
+// in the given fold markers, if code is longer than threshold lines.
+// The opening marker is labeled with the field name and the type of
+// the element, so that folded regions remain recognizable.
+func foldRegions(code string, threshold int, open, close string) (string, error) {
+	lines := strings.Split(code, "\n")
+	if threshold <= 0 || len(lines) <= threshold {
+		return code, nil
+	}
+
+	fset, expr, _, err := parseCode(code)
+	if err != nil {
+		return "", err
+	}
+	if u, ok := expr.(*ast.UnaryExpr); ok {
+		expr = u.X
+	}
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return code, nil
+	}
+
+	open = strings.TrimSpace(open)
+	close = strings.TrimSpace(close)
+	before := make(map[int]string) // line -> opening marker
+	after := make(map[int]string)  // line -> closing marker
+	for i, e := range lit.Elts {
+		label := fmt.Sprintf("[%d]", i)
+		val := e
+		if kv, ok := e.(*ast.KeyValueExpr); ok {
+			label = exprString(fset, kv.Key)
+			val = kv.Value
+		}
+		start := fset.Position(e.Pos()).Line
+		end := fset.Position(e.End()).Line
+		if start == end {
+			continue
+		}
+		if t := litTypeString(fset, val); t != "" {
+			label += " " + t
+		}
+		indent := leadingSpace(lines[start-1])
+		before[start] = indent + open + " " + label
+		after[end] = indent + close
+	}
+	if len(before) == 0 {
+		return code, nil
+	}
+
+	var buf bytes.Buffer
+	for i, l := range lines {
+		if m, ok := before[i+1]; ok {
+			buf.WriteString(m)
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(l)
+		if i < len(lines)-1 {
+			buf.WriteByte('\n')
+		}
+		if m, ok := after[i+1]; ok {
+			buf.WriteString(m)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String(), nil
+}
+
+// dropFoldMarkers removes the fold markers from the leading comments of
+// the existing elements in comments, since foldRegions adds them again.
+func dropFoldMarkers(comments map[string]elementComment, open, close string) {
+	open = strings.TrimSpace(open)
+	close = strings.TrimSpace(close)
+	for path, c := range comments {
+		var lead []string
+		for _, com := range c.lead {
+			if com != open && !strings.HasPrefix(com, open+" ") && com != close {
+				lead = append(lead, com)
+			}
+		}
+		c.lead = lead
+		if len(c.lead) == 0 && c.trail == "" {
+			delete(comments, path)
+			continue
+		}
+		comments[path] = c
+	}
+}
+
+// parseCode parses the generated code of a literal. Literals with an
+// elided type, e.g. elements of a slice literal, are not valid
+// expressions on their own; they are parsed with a placeholder type.
+// The returned shift is the number of bytes prepended to code.
+func parseCode(code string) (fset *token.FileSet, expr ast.Expr, shift int, err error) {
+	src := code
+	if strings.HasPrefix(src, "{") {
+		src = "_" + src
+		shift = 1
+	}
+	fset = token.NewFileSet()
+	expr, err = parser.ParseExprFrom(fset, "", src, parser.ParseComments)
+	return fset, expr, shift, err
+}
+
+// litTypeString returns the type of the composite literal e as
+// written in the source, or "" if the type is elided.
+func litTypeString(fset *token.FileSet, e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.UnaryExpr:
+		if t := litTypeString(fset, e.X); t != "" {
+			return e.Op.String() + t
+		}
+	case *ast.CompositeLit:
+		if e.Type != nil {
+			return exprString(fset, e.Type)
+		}
+	}
+	return ""
+}
+
+func exprString(fset *token.FileSet, e ast.Expr) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, e); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
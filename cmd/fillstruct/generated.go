@@ -0,0 +1,56 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"regexp"
+)
+
+// generatedRE matches the comment marking a file as generated,
+// see https://go.dev/s/generatedcode.
+var generatedRE = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated reports whether src has the comment marking it as
+// generated before the package clause.
+func isGenerated(src []byte) bool {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return false
+	}
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if generatedRE.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// generatedError is the refusal to emit edits of a generated file.
+type generatedError struct {
+	File string `json:"file"`
+}
+
+func (e *generatedError) Error() string {
+	return fmt.Sprintf("refusing to edit generated file %s, rerun with -allow-generated to edit it anyway", e.File)
+}
+
+// writeGenerated writes err as a JSON warning to w.
+func writeGenerated(w io.Writer, err *generatedError) error {
+	return json.NewEncoder(w).Encode(struct {
+		Warning   string `json:"warning"`
+		File      string `json:"file"`
+		Generated bool   `json:"generated"`
+	}{err.Error(), err.File, true})
+}
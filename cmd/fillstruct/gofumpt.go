@@ -0,0 +1,32 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	gofumpt "mvdan.cc/gofumpt/format"
+)
+
+// gofumptCode formats the generated code of a literal or call
+// with the stricter rules of gofumpt instead of plain gofmt.
+func gofumptCode(code string) (string, error) {
+	prefix := []byte("package p\n\nvar _ = ")
+	if strings.HasPrefix(code, "{") {
+		// Literals with an elided type need a placeholder type, see parseCode.
+		prefix = append(prefix, '_')
+	}
+
+	src, err := gofumpt.Source(append(prefix, code+"\n"...), gofumpt.Options{})
+	if err != nil {
+		return "", err
+	}
+	if !bytes.HasPrefix(src, prefix) {
+		return "", errors.New("gofumpt changed the code around the literal")
+	}
+	return string(bytes.TrimSuffix(src[len(prefix):], []byte("\n"))), nil
+}
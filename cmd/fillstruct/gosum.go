@@ -0,0 +1,170 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// sumError is a failure to load packages because of missing go.sum
+// entries. The types of the literals in the packages are invalid then,
+// so that nothing can be filled.
+type sumError struct {
+	dir  string   // directory of the go.mod file, empty if it is unknown
+	work string   // path of the go.work file in effect, if any
+	msgs []string // messages of the go command
+}
+
+func (e *sumError) Error() string {
+	var buf strings.Builder
+	buf.WriteString("cannot load the types of the literals, go.sum entries are missing:")
+	for _, msg := range e.msgs {
+		buf.WriteString("\n\t" + msg)
+	}
+	dir := e.dir
+	if dir == "" {
+		dir = "the module"
+	}
+	if e.work != "" {
+		// The go command does not accept -modfile in workspace mode.
+		fmt.Fprintf(&buf, "\nrun go mod tidy in %s and go work sync in %s", dir, filepath.Dir(e.work))
+		return buf.String()
+	}
+	fmt.Fprintf(&buf, "\nrun go mod tidy in %s, or rerun with -fix to fill with a tidied copy of go.mod and go.sum", dir)
+	return buf.String()
+}
+
+// missingSums returns a sumError if the packages loaded from dir, or
+// the error err of loading them, report missing go.sum entries.
+func missingSums(dir string, pkgs []*packages.Package, err error) *sumError {
+	var msgs []string
+	seen := make(map[string]bool)
+	add := func(msg string) {
+		if strings.Contains(msg, "missing go.sum entry") && !seen[msg] {
+			seen[msg] = true
+			msgs = append(msgs, msg)
+		}
+	}
+	if err != nil {
+		add(err.Error())
+	}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, e := range pkg.Errors {
+			add(e.Msg)
+		}
+	})
+	if len(msgs) == 0 {
+		return nil
+	}
+	return &sumError{dir: moduleDir(dir), work: workFile(dir), msgs: msgs}
+}
+
+// moduleDir returns the directory of the go.mod file of the module
+// containing dir, or the empty string if there is none.
+func moduleDir(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// tidyModFile runs go mod tidy on copies of the go.mod and go.sum files
+// in dir, such that the files of the module are not changed. It returns
+// the path of the tidied go.mod file, to be passed with -modfile, and a
+// function removing the copies.
+func tidyModFile(ctx context.Context, dir string) (string, func(), error) {
+	tmp, err := os.MkdirTemp("", "fillstruct")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+
+	for _, name := range []string{"go.mod", "go.sum"} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) && name == "go.sum" {
+			continue
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(tmp, name), b, 0644)
+		}
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+
+	modFile := filepath.Join(tmp, "go.mod")
+	cmd := exec.CommandContext(ctx, "go", "mod", "tidy", "-modfile="+modFile)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("go mod tidy: %v\n%s", err, out)
+	}
+	return modFile, cleanup, nil
+}
+
+// loadPackages loads the packages matching patterns. If go.sum entries
+// are missing, it fails with a sumError, unless fix is set outside of
+// a workspace: then, the
+// packages are loaded again with a tidied copy of go.mod and go.sum, see
+// tidyModFile, and the returned function removes the copies. Loading
+// fails with the error of the context of cfg once it is canceled.
+func loadPackages(cfg *packages.Config, patterns []string, fix bool) ([]*packages.Package, func(), error) {
+	ctx := cfg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if ctx.Err() != nil {
+		// go/packages records files which were not
+		// parsed anymore as errors of the packages.
+		return nil, nil, ctx.Err()
+	}
+	checkCgo(cfg, pkgs)
+	dir := cfg.Dir
+	if dir == "" {
+		dir = "."
+	}
+	serr := missingSums(dir, pkgs, err)
+	if serr == nil {
+		return pkgs, func() {}, err
+	}
+	if !fix || serr.dir == "" || serr.work != "" {
+		return nil, nil, serr
+	}
+
+	modFile, cleanup, err := tidyModFile(ctx, serr.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.BuildFlags = append(cfg.BuildFlags, "-modfile="+modFile)
+	pkgs, err = packages.Load(cfg, patterns...)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	checkCgo(cfg, pkgs)
+	return pkgs, cleanup, nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// fillInfo describes a filled literal for the
+// post-processing of its generated code.
+type fillInfo struct {
+	fset        *token.FileSet // file set of pkg
+	pkg         *types.Package
+	importNames map[string]string         // import path -> import name
+	typ         types.Type                // type of the literal
+	existing    map[string]bool           // paths of the elements present before filling, see existingPaths
+	comments    map[string]elementComment // comments of the existing elements, see elementComments
+}
+
+// newFillInfo returns the fillInfo of the literal lit before it is filled.
+func newFillInfo(fset *token.FileSet, pkg *types.Package, importNames map[string]string, lit *ast.CompositeLit, info litInfo) fillInfo {
+	fi := fillInfo{
+		fset:        fset,
+		pkg:         pkg,
+		importNames: importNames,
+		typ:         info.typ,
+		existing:    existingPaths(lit),
+	}
+	if info.name != nil {
+		fi.typ = info.name
+	}
+	return fi
+}
+
+// typeHints appends the type of every newly filled field of the literal
+// in code as a comment to its line, if the value does not reveal the
+// type, e.g. for nil values or constants of named basic types. Types
+// longer than width are shortened, unless width is 0.
+func typeHints(code string, fi fillInfo, width int) (string, error) {
+	fset, expr, _, err := parseCode(code)
+	if err != nil {
+		return "", err
+	}
+
+	hints := make(map[int]string) // line -> type
+	var walk func(e ast.Expr, t types.Type, path string)
+	walk = func(e ast.Expr, t types.Type, path string) {
+		if u, ok := e.(*ast.UnaryExpr); ok {
+			e = u.X
+			if p, ok := t.Underlying().(*types.Pointer); ok {
+				t = p.Elem()
+			}
+		}
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok {
+			return
+		}
+		switch u := t.Underlying().(type) {
+		case *types.Struct:
+			for i, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				id, ok := kv.Key.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				field := lookupField(u, id.Name)
+				if field == nil {
+					continue
+				}
+				p, _ := elementPath(path, i, elt)
+				if !isOpaque(kv.Value, field.Type()) {
+					walk(kv.Value, field.Type(), p)
+				} else if fi.existing[p] {
+					continue
+				} else if s, ok := typeString(fi.pkg, fi.importNames, field.Type()); ok {
+					hints[fset.Position(kv.Pos()).Line] = shorten(s, width)
+				}
+			}
+		case *types.Map:
+			for i, elt := range lit.Elts {
+				p, v := elementPath(path, i, elt)
+				walk(v, u.Elem(), p)
+			}
+		case sequence:
+			for i, elt := range lit.Elts {
+				p, v := elementPath(path, i, elt)
+				walk(v, u.Elem(), p)
+			}
+		}
+	}
+	walk(expr, fi.typ, "")
+
+	lines := strings.Split(code, "\n")
+	for i := range lines {
+		if s, ok := hints[i+1]; ok {
+			lines[i] += " // " + s
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// shorten cuts s to width runes, the last of which is an ellipsis,
+// if it is longer. A width of 0 leaves s as it is.
+func shorten(s string, width int) string {
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// isOpaque reports whether the value e of type t does not reveal
+// its type: nil or a constant of a named basic type.
+func isOpaque(e ast.Expr, t types.Type) bool {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name == "nil"
+	case *ast.BasicLit:
+		_, basic := t.Underlying().(*types.Basic)
+		return isNamed(t) && basic
+	default:
+		return false
+	}
+}
+
+func lookupField(s *types.Struct, name string) *types.Var {
+	for i := 0; i < s.NumFields(); i++ {
+		if s.Field(i).Name() == name {
+			return s.Field(i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// setIndents sets the indentation of the line of every edit in outs,
+// taken from src. If reindent is set, the code and snippet of the edits
+// are indented accordingly, such that they can be inserted as they are.
+// Inserted lines, e.g. the declarations of -share, are always followed
+// by the indentation, since they are inserted after it.
+func setIndents(src []byte, outs []output, reindent bool) {
+	for i := range outs {
+		outs[i].Indent = lineIndent(src, outs[i].Start)
+		if reindent {
+			unit := indentUnit(src, outs[i].Indent)
+			outs[i].Code = indentCode(outs[i].Code, outs[i].Indent, unit)
+			if outs[i].Snippet != "" {
+				outs[i].Snippet = indentCode(outs[i].Snippet, outs[i].Indent, unit)
+			}
+		}
+		if outs[i].Start == outs[i].End && strings.HasSuffix(outs[i].Code, "\n") {
+			outs[i].Code += outs[i].Indent
+		}
+	}
+}
+
+// lineIndent returns the leading whitespace of the line in src
+// containing the given offset.
+func lineIndent(src []byte, offset int) string {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	start := bytes.LastIndexByte(src[:offset], '\n') + 1
+	end := start
+	for end < offset && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	return string(src[start:end])
+}
+
+// indentUnit returns the string of one indentation level: a tab, unless
+// the given indentation consists of spaces. In that case, it is the
+// smallest number of leading spaces of a line in src, or four spaces.
+func indentUnit(src []byte, indent string) string {
+	if indent == "" || strings.Contains(indent, "\t") {
+		return "\t"
+	}
+	width := 0
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		n := len(line) - len(bytes.TrimLeft(line, " "))
+		if n > 0 && n < len(line) && (width == 0 || n < width) {
+			width = n
+		}
+	}
+	if width == 0 {
+		width = 4
+	}
+	return strings.Repeat(" ", width)
+}
+
+// indentCode prefixes all non-empty lines of code but the first, which
+// starts at the literal, with indent. Leading tabs are replaced with unit.
+func indentCode(code, indent, unit string) string {
+	lines := strings.Split(code, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == "" {
+			continue
+		}
+		trimmed := strings.TrimLeft(lines[i], "\t")
+		tabs := len(lines[i]) - len(trimmed)
+		lines[i] = indent + strings.Repeat(unit, tabs) + trimmed
+	}
+	return strings.Join(lines, "\n")
+}
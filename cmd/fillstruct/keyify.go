@@ -0,0 +1,46 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// keyify rewrites the struct literals with positional fields in e,
+// including e itself, to keyed literals, in place. For example,
+// User{1, "frank", nil} becomes User{ID: 1, Name: "frank", Addr: nil}.
+func keyify(info *types.Info, e ast.Expr) {
+	ast.Inspect(e, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok || !isPositional(info, lit) {
+			return true
+		}
+		s := info.Types[lit].Type.Underlying().(*types.Struct)
+		for i, elt := range lit.Elts {
+			if i >= s.NumFields() {
+				break
+			}
+			lit.Elts[i] = &ast.KeyValueExpr{
+				Key:   ast.NewIdent(s.Field(i).Name()),
+				Value: elt,
+			}
+		}
+		return true
+	})
+}
+
+// isPositional reports whether lit is a struct literal with positional fields.
+func isPositional(info *types.Info, lit *ast.CompositeLit) bool {
+	tv, ok := info.Types[lit]
+	if !ok {
+		return false
+	}
+	if _, ok := tv.Type.Underlying().(*types.Struct); !ok || len(lit.Elts) == 0 {
+		return false
+	}
+	_, keyed := lit.Elts[0].(*ast.KeyValueExpr)
+	return !keyed
+}
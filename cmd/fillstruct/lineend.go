@@ -0,0 +1,60 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// bom is the UTF-8 byte order mark, which editors saving files on Windows
+// may put at the start of a file. Editors do not count it in offsets.
+const bom = "\xef\xbb\xbf"
+
+// bomLen returns the length of the byte order mark at the start of src,
+// 0 if there is none.
+func bomLen(src []byte) int {
+	if bytes.HasPrefix(src, []byte(bom)) {
+		return len(bom)
+	}
+	return 0
+}
+
+// isCRLF reports whether the lines of src end with CRLF, judging
+// by the first line. gofmt keeps CRLF line endings, but only
+// uses LF in the code it generates.
+func isCRLF(src []byte) bool {
+	i := bytes.IndexByte(src, '\n')
+	return i > 0 && src[i-1] == '\r'
+}
+
+// setLineEndings converts the line endings of the code of every edit in
+// outs to CRLF if the lines of src end with CRLF, such that the file has
+// consistent line endings once the edits are applied.
+func setLineEndings(src []byte, outs []output) {
+	if !isCRLF(src) {
+		return
+	}
+	for i := range outs {
+		outs[i].Code = toCRLF(outs[i].Code)
+	}
+}
+
+// toCRLF returns s with all line endings converted to CRLF.
+func toCRLF(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// restoreFile restores the byte order mark and the CRLF line endings of
+// the original src in res, the formatted file, e.g. by goimports.
+func restoreFile(src, res []byte) []byte {
+	if isCRLF(src) && !isCRLF(res) {
+		res = []byte(toCRLF(string(res)))
+	}
+	if n := bomLen(src); n > 0 && bomLen(res) == 0 {
+		res = append(src[:n:n], res...)
+	}
+	return res
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// literal describes a literal which can be filled.
+type literal struct {
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Line    int    `json:"line"`
+	Type    string `json:"type"`
+	Missing int    `json:"missing"` // number of fields or template entries which would be added
+}
+
+// listLiterals returns all literals in the file at path which can be filled.
+func listLiterals(lprog []*packages.Package, path string) ([]literal, error) {
+	for _, pkg := range lprog {
+		for _, f := range pkg.Syntax {
+			if file := pkg.Fset.File(f.Pos()); file.Name() == path {
+				return literals(pkg.Fset, f, pkg.Types, pkg.TypesInfo), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("could not find file %q", path)
+}
+
+// literals returns the literals in f which can be filled, see isFillable.
+// Struct literals with positional elements are skipped.
+func literals(fset *token.FileSet, f *ast.File, pkg *types.Package, info *types.Info) []literal {
+	importNames := buildImportNameMap(f)
+	lits := []literal{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		t := info.Types[lit].Type
+		if t == nil || !isFillable(t) {
+			return true
+		}
+		missing, ok := missingFields(pkg, lit, t)
+		if !ok {
+			return true
+		}
+		name, ok := typeString(pkg, importNames, t)
+		if !ok {
+			return true
+		}
+		lits = append(lits, literal{
+			Start:   fset.Position(lit.Pos()).Offset,
+			End:     fset.Position(lit.End()).Offset,
+			Line:    fset.PositionFor(lit.Pos(), false).Line,
+			Type:    name,
+			Missing: missing,
+		})
+		return true
+	})
+	return lits
+}
+
+// missingFields returns the number of fields of the literal lit of type t,
+// which would be added by filling it. Literals of named map, slice or array
+// types miss a template entry if they are empty. It returns false if lit is
+// a struct literal with positional elements.
+func missingFields(pkg *types.Package, lit *ast.CompositeLit, t types.Type) (int, bool) {
+	s, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		if len(lit.Elts) == 0 {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	present := make(map[string]bool)
+	for _, e := range lit.Elts {
+		kv, ok := e.(*ast.KeyValueExpr)
+		if !ok {
+			return 0, false
+		}
+		if id, ok := kv.Key.(*ast.Ident); ok {
+			present[id.Name] = true
+		}
+	}
+
+	imported := isImported(pkg, namedOf(t))
+	missing := 0
+	for i := 0; i < s.NumFields(); i++ {
+		field := s.Field(i)
+		if strings.HasPrefix(field.Name(), "XXX_") || present[field.Name()] || skipField(s, i) {
+			continue
+		}
+		if !imported || field.Exported() {
+			missing++
+		}
+	}
+	return missing, true
+}
@@ -45,23 +45,405 @@
 //
 // Flags:
 //
-// -file:     filename
+// -file:        filename
 //
-// -modified: read an archive of modified files from stdin
+// -modified:    read an archive of modified files from stdin
 //
-// -offset:   byte offset of the struct literal, optional if -line is present
+// -offset:      byte offset of the struct literal, optional if -line is present; repeated or comma-separated for several literals
 //
-// -line:     line number of the struct literal, optional if -offset is present
+// -line:        line number of the struct literal, optional if -offset is present; repeated or comma-separated for several literals
 //
+// -encoding:    unit of offsets and columns in the input and output: utf-8 (bytes), utf-16 (code units, like LSP clients) or utf-32 (code points)
+//
+// -pick:        fill the literal enclosing the innermost one at -offset, counting outwards: 0 is the innermost, 1 the one enclosing it, and so on
+//
+// -fold:        wrap multi-line fields in fold markers if the generated code is longer than the given number of lines
+//
+// -foldmarkers: comma-separated opening and closing fold markers
+//
+// -from:        fill the literal with the values of an example JSON or YAML document
+//
+// -mode:        fill mode: zero (zero values), fuzz (pseudo-random non-zero values) or placeholder (values describing the fields)
+//
+// -seed:        seed for the pseudo-random values of -mode=fuzz
+//
+// -order:       field order: decl (declaration order), alpha (alphabetical order) or keep (existing fields in place, missing fields appended)
+//
+// -snippet:     add a snippet with a tab stop for every filled value to the output
+//
+// -w:           write the result to the file instead of stdout
+//
+// -goimports:   fix the imports of the written file like goimports, requires -w
+//
+// -fix:         if go.sum entries are missing, fill with a copy of go.mod and go.sum tidied by go mod tidy; the files of the module are not changed
+//
+// -gofumpt:     format the generated code with the stricter rules of gofumpt
+//
+// -indent:      indent the generated code like the line of the literal, with tabs or spaces
+//
+// -todo:        append a TODO comment to every newly filled field
+//
+// -nolint:      append a //nolint comment for the given comma-separated linters to every newly filled field
+//
+// -typehints:   append the type of every field filled with nil or an opaque value as a comment
+//
+// -hintwidth:   maximum length of the types appended by -typehints, longer types are shortened; 0 means no limit
+//
+// -fielddocs:   add the declaration position and doc summary of every newly filled field to the output
+//
+// -emit-json:   add the JSON encoding of the filled value, using the json tags of the fields, to the output
+//
+// -maxwidth:    emit the filled literal on a single line if it fits within the given number of columns; 0 disables compact output
+//
+// -skipdeprecated: do not fill fields whose documentation marks them as deprecated
+//
+// -only:        only fill the fields whose name or path, e.g. Addr.ZIP, matches the regular expression, and the fields enclosing them
+//
+// -ignore:      do not fill the fields whose name or path, e.g. Addr.ZIP, matches the regular expression
+//
+// -required-only: only fill the fields marked as required by validate:"required" or binding:"required" tags, and the non-pointer struct fields enclosing them
+//
+// -exported-only: only fill exported fields, even of types declared in the same package
+//
+// -shallow-unexported: do not expand nested struct literals of types declared in the same package with unexported fields, e.g. Cache{}
+//
+// -embedded:    embedded fields: fill (nested literal) or skip (left out, zero value)
+//
+// -recursion:   recursive occurrences of self-referential types: empty (empty literal), nil (nil pointer) or once (expand one more level)
+//
+// -share:       expand nested struct literals which occur more than once into a variable declared before the literal
+//
+// -constructor: fill the fields of a literal returned by a constructor, e.g. NewServer(addr string) *Server, with the parameters of the same names and types
+//
+// -depth:       number of nested struct literals to expand, deeper struct literals are left empty; 0 means no limit
+//
+// -use-scope:   fill fields with the variables in scope at the literal, e.g. parameters, of the same type instead of zero values
+//
+// -purge:       remove all fields with zero values from the literal instead of filling it
+//
+// -keyify:      convert the positional fields of the literal and its nested literals to keyed fields
+//
+// -unkeyify:    convert the keyed literal and its nested literals to positional literals on a single line, all fields must be present and exported
+//
+// -list-literals: list the positions and types of all struct literals in the file which can be filled
+//
+// -positions:   fill the literals at the positions file:line[:col] read from the given file, - for stdin, e.g. the output of grep -n or of the compiler
+//
+// -verify:      fill the literal a second time after applying the edit, in memory, and print a warning if that changes it
+//
+// -undo:        revert the edit with the given ID at -offset, the original bytes of the edit are read from stdin
+//
+// -allow-generated: emit edits of files marked as generated by a // Code generated ... DO NOT EDIT. comment
+//
+// -tests:       load the test variants of the packages; by default, only when filling a _test.go file
+//
+// -watch:       fill the literals again each time a Go file of the given file or directory changes and print the edits as a JSON line
+//
+// -timeout:     give up loading and filling after the given duration, e.g. 5s, and print a JSON error; 0 means no limit
+//
+// -cpuprofile:  write a CPU profile to the given file
+//
+// -memprofile:  write a heap profile to the given file before exiting
+//
+// -trace:       write an execution trace to the given file
+//
+//
+// Files in GOROOT, in the module cache or without write permission are
+// read-only: their literals are filled as usual, but -w refuses to write
+// them. The edits are printed to stdout nevertheless.
+//
+// Generated files, i.e. files with a comment "// Code generated ... DO NOT
+// EDIT." before the package clause, are not edited at all: instead of the
+// edits, a warning such as {"warning": "refusing to edit generated file
+// ...", "file": "...", "generated": true} is printed on stdout and
+// fillstruct fails, unless -allow-generated is set. With -positions, the
+// warning is printed and the generated file is skipped.
+//
+// If -offset hits nested literals, e.g. Inner{} in Outer{Inner: Inner{}},
+// the innermost one is filled. With -pick=N, the N-th literal enclosing
+// it is filled instead, e.g. Outer{...} with -pick=1. If there is more
+// than one fillable literal at -offset, the output lists them as
+// "candidates" with their value of -pick, type and position, such that
+// editors can offer a choice.
+//
+// Fields of anonymous struct types, e.g. Server struct{ Host string },
+// are filled like fields of named struct types. The literal repeats the
+// type inline, laid out like gofmt does, with its field tags; pointers to
+// anonymous structs are filled with &struct{...}{...}.
+//
+// Types are written as in the declarations of the fields: a field of an
+// alias type, e.g. Total Money with type Money = decimal.Decimal, is
+// filled with Money{...}, not with decimal.Decimal{...}. Aliases of map,
+// slice and array types keep their names, too.
+//
+// Every edit contains the indentation of the line of the literal. With
+// -indent, the generated code is indented accordingly, using spaces if the
+// line is indented with spaces, so that it can be inserted as it is. The
+// code written with -w is always indented this way.
+//
+// Every edit has an ID, which only depends on its tokens, not on the
+// whitespace between them, e.g. of the indentation. An applied edit can be
+// reverted with -undo=<id> -offset=<start of the edit>, given the bytes it
+// replaced on stdin. This works for the filled literals and calls as well
+// as for the declarations of -share. The result is again an edit, whose ID
+// can be used to redo the original edit. -undo cannot be combined with
+// -modified.
+//
+// Every edit also contains the lines and columns of the start and end of
+// the replaced region and the SHA-256 hash of its text, such that editors
+// can detect that the buffer changed since and refuse to apply the edit.
+// Its status is "unchanged" if the literal is already complete and the
+// edit does not change the code, and "changed" otherwise. If nothing
+// changes at all, a message saying so is printed on stderr.
+//
+// If the selection is within a composite literal which cannot be filled,
+// e.g. a map literal or a slice of ints, fillstruct exits with an error
+// and prints the literal nearest to the selection, its type and why it
+// was rejected as JSON on stdout, e.g. {"error": "no struct literal found
+// at selection", "literal": {"start": 42, "end": 50, "line": 3, "type":
+// "[]int", "reason": "slice of int, ..."}}.
+//
+// With -maxwidth=N, a filled literal which fits within N columns on a
+// single line, e.g. Point{X: 0, Y: 0}, is emitted on that line instead of
+// on multiple lines. The indentation at the literal is not counted.
+// Literals to which -todo, -nolint or -typehints append comments stay on
+// multiple lines.
+//
+// Fields with the struct tag `fillstruct:"-"` are not filled, but kept
+// if they are present in the literal.
+//
+// The fields of a filled literal follow the order given by -order: the
+// order of the struct declaration (the default), the alphabetical order of
+// the field names, or, with -order=keep, the order of the existing fields,
+// followed by the missing fields in declaration order.
+//
+// Literals of named map, slice or array types are filled with a template
+// entry or element. With -line, they are only filled if they are empty.
+//
+// With -fielddocs, the output of an edit contains a list of the newly
+// filled fields with their path in the literal, e.g. "Addr.City", their
+// line in the generated code, the position of their declaration as
+// file:line and the first sentence of their documentation.
+//
+// With -emit-json, the output of an edit contains the JSON document which
+// encoding/json produces for the filled value, honoring the names and the
+// omitempty and string options of json tags, e.g. as a sample request
+// body for API docs. Values only known at run time, e.g. variables, are
+// encoded as zero values; values of types with MarshalJSON or MarshalText
+// methods, except for time.Time, as null. Fields which encoding/json
+// cannot encode, e.g. channels, are left out.
+//
+// With -unkeyify, the keyed literal, e.g. User{ID: 1, Name: "frank",
+// Addr: nil}, is rewritten to the positional literal User{1, "frank", nil}
+// on a single line. All fields of the literal must be present and exported.
+// Nested keyed literals are rewritten as well, if they satisfy the same
+// conditions. -unkeyify cannot be combined with -keyify or -purge.
+//
+// With -list-literals, neither -offset nor -line is needed. The output is
+// a list of all struct literals in the file which can be filled, with their
+// start and end offsets, line, type and number of missing fields.
+//
+// With -typehints, types longer than -hintwidth are shortened with an
+// ellipsis. The trailing comments of the generated code are aligned like
+// gofmt aligns them.
+//
+// Comments above the existing elements of a literal and at the end of
+// their lines are preserved. Literals with comments are not compacted.
+//
+// With -skipdeprecated, fields whose doc or line comment contains a
+// paragraph starting with "Deprecated:" are not filled, but kept if they
+// are present in the literal.
+//
+// Fields annotated with a //fillstruct:ignore directive in their doc or
+// line comment, and fields whose type, or the type their pointer type
+// points to, is annotated with it, are never filled, e.g. mutexes or
+// internal bookkeeping fields. They are kept if they are present in the
+// literal. The paths of the skipped fields, e.g. "Cache.mu", are listed
+// in the "ignored" list of the output.
+//
+// With -only and -ignore, only a subset of the missing fields is filled.
+// The regular expressions are matched against the name of a field and
+// its path in the literal, e.g. Addr.ZIP. With -only, the fields matching
+// the expression are filled completely, the fields enclosing them with
+// just the matching fields. Fields matching -ignore are not filled.
+//
+// With -required-only, only the fields which validators require are
+// filled: fields tagged with validate:"required" or binding:"required",
+// and fields of struct types, not pointers, with such fields, which
+// validators check as well, filled with just those. Other missing fields
+// are left out.
+//
+// Unexported fields are only filled if the type is declared in the same
+// package as the literal. With -exported-only, they are never filled, e.g.
+// for fixtures which are meant to be copied into another package.
+//
+// Messages generated by protoc-gen-go are detected by their internal
+// fields, e.g. state protoimpl.MessageState: their unexported fields and
+// XXX_ fields are never filled. Fields of well-known types are filled
+// with their constructors, e.g. wrapperspb.String("") for a field of type
+// *wrapperspb.StringValue, and durationpb.New(0). Timestamps are filled
+// with &timestamppb.Timestamp{}.
+//
+// With -shallow-unexported, nested struct literals of types declared in
+// the same package with unexported fields, e.g. a cache with a mutex and
+// a map, are emitted empty, e.g. Cache{}, instead of being expanded.
+// The literal at -offset itself is always filled.
+//
+// With -embedded=skip, missing embedded fields, e.g. Base in
+// struct{ Base; Name string }, are not filled but left at their zero
+// values. Embedded fields present in the literal are kept.
+//
+// Self-referential types, e.g. the nodes of a tree or a linked list, are
+// expanded once; their recursive occurrences are empty literals, e.g.
+// Next: &Node{}. With -recursion=nil, recursive pointers are filled with
+// nil instead, and with -recursion=once, they are expanded one more
+// level, whose recursive occurrences are empty literals again.
+//
+// With -share, a nested struct literal which occurs more than once in the
+// filled literal with the same fields, e.g. the Address of a billing and a
+// shipping address, is expanded once into a variable, e.g. address :=
+// Address{...}, which is declared before the statement enclosing the
+// literal, or as a package-level variable. The output then contains this
+// declaration as a second edit. Only zero values of types without
+// pointers, slices, maps, channels, functions or interfaces are shared,
+// and literals which are already present are not.
+//
+// With -constructor, if the literal is returned by a constructor, i.e. a
+// function returning a value of its type or a pointer to it, such as
+// NewServer(addr string) *Server, its fields are filled with the
+// parameters of the same names, ignoring case, and types, e.g. Addr:
+// addr. Only the fields of the literal itself are filled with
+// parameters, not those of nested literals.
+//
+// With -use-scope, a field is filled with a variable in scope at the
+// literal instead of a value, if the variable is of the same type and is
+// a parameter or a local variable declared before the literal: the one
+// with the same name as the field, ignoring case, or else the only one of
+// the type. Fields of basic types such as int and string are only filled
+// with variables of the same name. Values of -from take precedence.
+//
+// If there is a call without arguments at -offset, e.g. NewServer(), its
+// arguments are filled with the zero values of the parameters instead, e.g.
+// NewServer(Config{...}, nil). Variadic parameters are left out.
+//
+// If -offset points to a literal of an unnamed slice, array or map type of
+// structs, e.g. []User{{}, {}} or map[string]User{"a": {}}, but not into
+// one of its elements, all element literals are filled with a single edit
+// of the whole literal. Struct keys of maps are filled as well. An empty
+// array literal, e.g. [3]Address{}, gets an element for every index.
+//
+// With -positions, -file, -offset and -line are not needed. The literals
+// at the positions read from the given file, or from stdin for -, are
+// filled instead, e.g. with grep -n 'Config{' *.go | fillstruct -positions -.
+// Each line starts with file:line or file:line:col, the rest is ignored.
+// The edits of each file are printed as a separate JSON list with the
+// name of the file, or written to it with -w.
+//
+// With -verify, the edits are applied in memory and the literals are
+// filled a second time. If that changes a literal, e.g. because of a
+// formatting flag which is not stable, a warning with the changed code
+// is printed on stderr. The edits are printed nevertheless.
+//
+// If go.sum entries are missing, the types of the literals cannot be
+// loaded and fillstruct fails with the messages of the go command and a
+// hint to run go mod tidy. With -fix, go mod tidy is run on copies of
+// go.mod and go.sum instead, which are used to fill the literals and
+// removed afterwards. In a workspace, -fix is not supported.
+//
+// The packages are loaded with the go.work file in effect, if any, such
+// that literals of types of other modules of the workspace are filled.
+// -mod flags of GOFLAGS other than -mod=readonly and -mod=vendor, which
+// the go command rejects in workspace mode, are ignored then. With
+// -positions, every file is loaded from its own workspace or module.
+//
+// Packages are loaded with the driver of GOPACKAGESDRIVER or, if it is not
+// set, gopackagesdriver in PATH, if any, e.g. in repositories built with
+// Bazel. The package of the file is queried by the file then, which all
+// drivers answer. GOPACKAGESDRIVER=off uses the go command.
+//
+// A file which is neither in a module nor in GOPATH, e.g. a scratch file
+// in /tmp, is loaded on its own as an ad-hoc package, like go run file.go
+// does, such that only the types of the file and its imports of the
+// standard library are known.
+//
+// Literals in files importing "C" are filled as well. Fields whose types
+// are declared by cgo, such as C.int, are left out, as are literals of
+// such types.
+//
+// With -watch=<file or directory>, fillstruct keeps running after the
+// fill and fills the literals at -offset and -line again each time a Go
+// file of the given file or directory changes, e.g. the directory of the
+// package, printing the edits as one JSON line each time. Only the package
+// of the file is type-checked again; it is loaded again if files or
+// imports are added. The offsets and lines refer to the file as it is at
+// each change. Once interrupted or terminated, fillstruct removes the
+// go.mod tidied by -fix, if any, before it exits. -watch cannot be
+// combined with -w, -modified, -undo, -verify, -list-literals or
+// -positions.
+//
+// The test variants of the packages, which take about as long to load
+// as the packages themselves, are only loaded to fill the literals of a
+// _test.go file, or of any file with -tests. With -tests=false, they are
+// never loaded.
+//
+// With -timeout, e.g. -timeout=5s, fillstruct gives up once loading the
+// packages and filling the literals take longer than the given duration.
+// Instead of edits, it prints a JSON error on stdout and exits with a
+// non-zero status:
+//
+//	{"error":"timed out after 5s, rerun with a larger -timeout","timeout":"5s"}
+//
+// Nothing is written, also with -w or -positions. With -watch, each
+// reload and fill gets the duration anew and a timeout is logged.
+//
+// To see where the time of a slow fill goes, -cpuprofile, -memprofile
+// and -trace write profiles like those of go test, for go tool pprof and
+// go tool trace. They are written when fillstruct is done or interrupted,
+// e.g. to stop -watch, but not if it fails.
 //
 // If -offset as well as -line are present, then the tool first uses the
 // more specific offset information. If there was no struct literal found
 // at the given offset, then the line information is used.
 //
+// If the type of the literal is unknown because of errors elsewhere in the
+// package, e.g. a variable of the same name while turning it into a type,
+// the literal is filled on a best-effort basis: only the imports, constants
+// and types of the package are type-checked, and the literal is checked on
+// its own, at package level.
+//
+// Offsets and lines are those of the file as it is, i.e. //line
+// directives, e.g. of generated parsers, are ignored, such that they
+// match the positions of editors. Only the declarations of -fielddocs
+// are reported like by the compiler, honoring //line directives.
+//
+// Offsets and columns are counted in bytes. With -encoding=utf-16, they
+// are counted in UTF-16 code units instead, like the positions of LSP
+// clients, and with -encoding=utf-32 in code points: -offset and the
+// offsets and columns of the edits, of -list-literals and of literals
+// which cannot be filled. Lines and -positions are not affected.
+//
+// A byte order mark at the start of a file is not counted in offsets and
+// columns, like editors do. In files with CRLF line endings, the code of
+// the edits has CRLF line endings, too, and -w keeps both, also with
+// -goimports.
+//
+// Several literals of the file can be filled at once, e.g. for multiple
+// cursors, with repeated or comma-separated offsets and lines, e.g.
+// -offset=120,356. The i-th line is used if there is no literal at the
+// i-th offset. The edits are printed as one list; literals which cannot be
+// filled are reported on stderr, and literals containing one another are
+// filled once.
+//
+// The literals, like those of -positions, are filled concurrently on up
+// to GOMAXPROCS goroutines, sharing the loaded type information. With
+// -keyify, -unkeyify and -purge, which rewrite the literals in place,
+// they are filled one after another.
+//
 package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -70,9 +452,12 @@ import (
 	"go/format"
 	"go/token"
 	"go/types"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"golang.org/x/tools/go/ast/astutil"
@@ -87,25 +472,166 @@ func main() {
 	log.SetPrefix("fillstruct: ")
 
 	var (
-		filename = flag.String("file", "", "filename")
-		modified = flag.Bool("modified", false, "read an archive of modified files from stdin")
-		offset   = flag.Int("offset", 0, "byte offset of the struct literal, optional if -line is present")
-		line     = flag.Int("line", 0, "line number of the struct literal, optional if -offset is present")
-		btags    buildutil.TagsFlag
+		filename  = flag.String("file", "", "filename")
+		modified  = flag.Bool("modified", false, "read an archive of modified files from stdin")
+		fold      = flag.Int("fold", 0, "wrap multi-line fields in fold markers if the generated code is longer than the given number of lines")
+		markers   = flag.String("foldmarkers", "// region,// endregion", "comma-separated opening and closing fold markers")
+		from      = flag.String("from", "", "fill the literal with the values of an example JSON or YAML document")
+		mode      = flag.String("mode", modeZero, "fill mode: zero (zero values), fuzz (pseudo-random non-zero values) or placeholder (values describing the fields)")
+		seed      = flag.Int64("seed", 1, "seed for the pseudo-random values of -mode=fuzz")
+		order     = flag.String("order", orderDecl, "field order: decl (declaration order), alpha (alphabetical order) or keep (existing fields in place, missing fields appended)")
+		snip      = flag.Bool("snippet", false, "add a snippet with a tab stop for every filled value to the output")
+		write     = flag.Bool("w", false, "write the result to the file instead of stdout")
+		fiximp    = flag.Bool("goimports", false, "fix the imports of the written file like goimports, requires -w")
+		fix       = flag.Bool("fix", false, "if go.sum entries are missing, fill with a copy of go.mod and go.sum tidied by go mod tidy; the files of the module are not changed")
+		fumpt     = flag.Bool("gofumpt", false, "format the generated code with the stricter rules of gofumpt")
+		indent    = flag.Bool("indent", false, "indent the generated code like the line of the literal, with tabs or spaces")
+		todo      = flag.Bool("todo", false, "append a TODO comment to every newly filled field")
+		nolint    = flag.String("nolint", "", "append a //nolint comment for the given comma-separated linters to every newly filled field")
+		hints     = flag.Bool("typehints", false, "append the type of every field filled with nil or an opaque value as a comment")
+		hintW     = flag.Int("hintwidth", 40, "maximum length of the types appended by -typehints, longer types are shortened; 0 means no limit")
+		docs      = flag.Bool("fielddocs", false, "add the declaration position and doc summary of every newly filled field to the output")
+		emitJSON  = flag.Bool("emit-json", false, "add the JSON encoding of the filled value, using the json tags of the fields, to the output")
+		prune     = flag.Bool("purge", false, "remove all fields with zero values from the literal instead of filling it")
+		keyed     = flag.Bool("keyify", false, "convert the positional fields of the literal and its nested literals to keyed fields")
+		unkeyed   = flag.Bool("unkeyify", false, "convert the keyed literal and its nested literals to positional literals on a single line, all fields must be present and exported")
+		maxWidth  = flag.Int("maxwidth", 0, "emit the filled literal on a single line if it fits within the given number of columns; 0 disables compact output")
+		skipDepr  = flag.Bool("skipdeprecated", false, "do not fill fields whose documentation marks them as deprecated")
+		only      = flag.String("only", "", "only fill the fields whose name or path, e.g. Addr.ZIP, matches the regular expression, and the fields enclosing them")
+		ignore    = flag.String("ignore", "", "do not fill the fields whose name or path, e.g. Addr.ZIP, matches the regular expression")
+		required  = flag.Bool("required-only", false, "only fill the fields marked as required by validate:\"required\" or binding:\"required\" tags, and the non-pointer struct fields enclosing them")
+		exported  = flag.Bool("exported-only", false, "only fill exported fields, even of types declared in the same package")
+		shallow   = flag.Bool("shallow-unexported", false, "do not expand nested struct literals of types declared in the same package with unexported fields, e.g. Cache{}")
+		embedded  = flag.String("embedded", embeddedFill, "embedded fields: fill (nested literal) or skip (left out, zero value)")
+		recursion = flag.String("recursion", recursionEmpty, "recursive occurrences of self-referential types: empty (empty literal), nil (nil pointer) or once (expand one more level)")
+		share     = flag.Bool("share", false, "expand nested struct literals which occur more than once into a variable declared before the literal")
+		depth     = flag.Int("depth", 0, "number of nested struct literals to expand, deeper struct literals are left empty; 0 means no limit")
+		ctor      = flag.Bool("constructor", false, "fill the fields of a literal returned by a constructor, e.g. NewServer(addr string) *Server, with the parameters of the same names and types")
+		useScope  = flag.Bool("use-scope", false, "fill fields with the variables in scope at the literal, e.g. parameters, of the same type instead of zero values")
+		list      = flag.Bool("list-literals", false, "list the positions and types of all struct literals in the file which can be filled")
+		positions = flag.String("positions", "", "fill the literals at the positions file:line[:col] read from the given file, - for stdin, e.g. the output of grep -n or of the compiler")
+		verify    = flag.Bool("verify", false, "fill the literal a second time after applying the edit, in memory, and print a warning if that changes it")
+		undo      = flag.String("undo", "", "revert the edit with the given ID at -offset, the original bytes of the edit are read from stdin")
+		allowGen  = flag.Bool("allow-generated", false, "emit edits of files marked as generated by a // Code generated ... DO NOT EDIT. comment")
+		watch     = flag.String("watch", "", "fill the literals again each time a Go file of the given file or directory changes and print the edits as a JSON line")
+		tests     = flag.Bool("tests", false, "load the test variants of the packages; by default, only when filling a _test.go file")
+		timeout   = flag.Duration("timeout", 0, "give up loading and filling after the given duration, e.g. 5s, and print a JSON error; 0 means no limit")
+		cpuprof   = flag.String("cpuprofile", "", "write a CPU profile to the given file")
+		memprof   = flag.String("memprofile", "", "write a heap profile to the given file before exiting")
+		trc       = flag.String("trace", "", "write an execution trace to the given file")
+		enc       = flag.String("encoding", encodingUTF8, "unit of offsets and columns in the input and output: utf-8 (bytes), utf-16 (code units, like LSP clients) or utf-32 (code points)")
+		pick      = flag.Int("pick", 0, "fill the literal enclosing the innermost one at -offset, counting outwards: 0 is the innermost, 1 the one enclosing it, and so on")
+		offsets   intList
+		lines     intList
+		btags     buildutil.TagsFlag
 	)
+	flag.Var(&offsets, "offset", "byte offset of the struct literal, optional if -line is present; repeated or comma-separated for several literals")
+	flag.Var(&lines, "line", "line number of the struct literal, optional if -offset is present; repeated or comma-separated for several literals")
 	flag.Var(&btags, "tags", buildutil.TagsFlagDoc)
 	flag.Parse()
 
-	if (*offset == 0 && *line == 0) || *filename == "" {
+	// Without -tests, whether the test variants are
+	// loaded depends on the files to fill.
+	var withTests *bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "tests" {
+			withTests = tests
+		}
+	})
+
+	if ((len(offsets) == 0 && len(lines) == 0 && !*list) || *filename == "") && *positions == "" {
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
+	stop, err := startProfiles(*cpuprof, *memprof, *trc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stop()
+
+	if !validMode(*mode) {
+		log.Fatalf("invalid mode %q", *mode)
+	}
+	if !validOrder(*order) {
+		log.Fatalf("invalid order %q", *order)
+	}
+	if !validEmbedded(*embedded) {
+		log.Fatalf("invalid handling of embedded fields %q", *embedded)
+	}
+	if !validRecursion(*recursion) {
+		log.Fatalf("invalid handling of recursive types %q", *recursion)
+	}
+	if !validEncoding(*enc) {
+		log.Fatalf("invalid encoding %q", *enc)
+	}
+	if strings.ContainsAny(*nolint, " \t\n/") {
+		log.Fatalf("invalid linters %q", *nolint)
+	}
+	if *unkeyed && (*keyed || *prune) {
+		log.Fatal("-unkeyify cannot be combined with -keyify or -purge")
+	}
+	if *fiximp && !*write {
+		log.Fatal("-goimports requires -w")
+	}
+	onlyRE, err := compileFilter(*only)
+	if err != nil {
+		log.Fatalf("invalid -only: %v", err)
+	}
+	ignoreRE, err := compileFilter(*ignore)
+	if err != nil {
+		log.Fatalf("invalid -ignore: %v", err)
+	}
+
+	opts := options{fold: *fold, mode: *mode, order: *order, seed: *seed, snippet: *snip, todo: *todo, nolint: *nolint, typeHints: *hints, hintWidth: *hintW, fieldDocs: *docs, emitJSON: *emitJSON, maxWidth: *maxWidth, gofumpt: *fumpt, share: *share, exportedOnly: *exported, shallow: *shallow, embedded: *embedded, recursion: *recursion, only: onlyRE, ignore: ignoreRE, depth: *depth, purge: *prune, keyify: *keyed, unkeyify: *unkeyed, useScope: *useScope, constructor: *ctor, requiredOnly: *required, pick: *pick, encoding: *enc}
+	m := strings.SplitN(*markers, ",", 2)
+	if len(m) != 2 {
+		log.Fatalf("invalid fold markers %q", *markers)
+	}
+	opts.foldOpen, opts.foldClose = m[0], m[1]
+	if *from != "" {
+		example, err := readExample(*from)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.example = example
+	}
+
+	fset := token.NewFileSet()
+	opts.noDeprecated = *skipDepr
+
+	ctx, cancel := withTimeout(*timeout)
+	defer cancel()
+
+	if *positions != "" {
+		if *undo != "" || *list || *verify || *watch != "" {
+			log.Fatal("-positions cannot be combined with -undo, -list-literals, -verify or -watch")
+		}
+		if err := fillPositions(ctx, fset, *positions, *modified, btags, withTests, opts, *indent, *allowGen, *write, *fiximp, *fix); err != nil {
+			fatal(timedOut(ctx, *timeout, err))
+		}
+		return
+	}
+
 	path, err := absPath(*filename)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if *watch != "" && (*undo != "" || *list || *verify || *modified || *write) {
+		log.Fatal("-watch cannot be combined with -undo, -list-literals, -verify, -modified or -w")
+	}
+
+	if *undo != "" {
+		if *modified || len(offsets) != 1 {
+			log.Fatal("-undo requires a single -offset and cannot be combined with -modified")
+		}
+		if err := undoFile(path, offsets[0], *undo, *enc, *allowGen, *write, *fiximp); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	var overlay map[string][]byte
 	if *modified {
 		overlay, err = buildutil.ParseOverlayArchive(os.Stdin)
@@ -114,44 +640,228 @@ func main() {
 		}
 	}
 
-	cfg := &packages.Config{
-		Overlay:    overlay,
-		Mode:       packages.LoadAllSyntax,
-		Tests:      true,
-		Dir:        filepath.Dir(path),
-		Fset:       token.NewFileSet(),
-		BuildFlags: []string{"-tags", strings.Join([]string(btags), ",")},
-		Env:        os.Environ(),
+	// The offsets are converted to bytes, the offsets
+	// and columns of the output back to the encoding.
+	// -watch converts the given ones at each change.
+	given := append(intList(nil), offsets...)
+	var encSrc []byte
+	if src, err := readSource(path, overlay); err == nil && needsEncoding(src, *enc) {
+		encSrc = src
+		for i := range offsets {
+			if offsets[i], err = byteOffset(encSrc, offsets[i], *enc); err != nil {
+				log.Fatal(err)
+			}
+		}
 	}
 
-	pkgs, err := packages.Load(cfg)
+	cfg := newConfig(ctx, fset, filepath.Dir(path), overlay, btags, loadTests(withTests, path))
+
+	var patterns []string
+	if packagesDriver(cfg.Env) != "" {
+		// Drivers, e.g. of Bazel, answer file queries,
+		// but not necessarily queries of directories.
+		patterns = []string{"file=" + path}
+	} else if isAdHoc(cfg.Dir) {
+		// Scratch files outside of modules and GOPATH are
+		// loaded on their own, like with go run file.go.
+		patterns = []string{path}
+	}
+
+	pkgs, cleanup, err := loadPackages(cfg, patterns, *fix)
 	if err != nil {
-		log.Fatal(err)
+		fatal(timedOut(ctx, *timeout, err))
 	}
+	defer cleanup()
+	opts = withSyntax(fset, pkgs, opts)
 
-	if *offset > 0 {
-		err = byOffset(pkgs, path, *offset)
-		switch err {
-		case nil:
-			return
-		case errNotFound:
-			// try to use line information
-		default:
+	if *list {
+		lits, err := listLiterals(pkgs, path)
+		if err != nil {
 			log.Fatal(err)
 		}
+		if encSrc != nil {
+			for i := range lits {
+				lits[i].Start = encodedOffset(encSrc, lits[i].Start, *enc)
+				lits[i].End = encodedOffset(encSrc, lits[i].End, *enc)
+			}
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(lits); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	if *line > 0 {
-		err = byLine(pkgs, path, *line)
-		switch err {
-		case nil:
-			return
-		default:
+	if *watch != "" {
+		target, err := absPath(*watch)
+		if err != nil {
 			log.Fatal(err)
 		}
+		w := &watcher{
+			cfg:            cfg,
+			patterns:       patterns,
+			target:         target,
+			path:           path,
+			offsets:        given,
+			lines:          lines,
+			opts:           opts,
+			indent:         *indent,
+			allowGenerated: *allowGen,
+			timeout:        *timeout,
+			stop: func() {
+				cleanup()
+				stop()
+			},
+		}
+		w.run(pkgs)
+	}
+
+	// Errors of one of several literals are only reported.
+	louts, errs := fillEach(ctx, pkgs, path, offsets, lines, opts)
+	if err := ctx.Err(); err != nil {
+		fatal(timedOut(ctx, *timeout, err))
+	}
+	n := len(errs)
+	var outs []output
+	for i, err := range errs {
+		if err != nil && n == 1 {
+			if e, ok := err.(*notFoundError); ok && encSrc != nil {
+				e.Literal.Start = encodedOffset(encSrc, e.Literal.Start, *enc)
+				e.Literal.End = encodedOffset(encSrc, e.Literal.End, *enc)
+			}
+			if err := writeNotFound(os.Stdout, err); err != nil {
+				log.Print(err)
+			}
+			log.Fatal(err)
+		}
+		if err != nil {
+			log.Printf("offset %d, line %d: %v", offsets.at(i), lines.at(i), err)
+			continue
+		}
+		outs = append(outs, louts[i]...)
+	}
+	if n > 1 {
+		outs = outermostEdits(outs)
+	}
+
+	if outs == nil {
+		log.Fatal(errNotFound)
+	}
+
+	src, err := readSource(path, overlay)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *verify {
+		if err := verifyFill(cfg, patterns, path, src, outs, opts); ctx.Err() != nil {
+			fatal(timedOut(ctx, *timeout, ctx.Err()))
+		} else if err != nil {
+			log.Printf("warning: the fill is not idempotent: %v", err)
+		}
+	}
+	setIndents(src, outs, *indent || *write) // written code is always indented
+	setLineEndings(src, outs)
+	if err := setIDs(src, outs); err != nil {
+		log.Fatal(err)
+	}
+	setRegions(src, outs)
+	if unchanged(outs) {
+		log.Print("nothing to fill, the literal is already complete")
+	}
+	if err := emit(path, overlay, outs, *allowGen, *write, *fiximp, *enc); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newConfig returns the configuration to load the packages in dir,
+// with the files in overlay replacing the ones on disk and, if tests
+// is set, their test variants. If dir is in a workspace, the packages
+// of its modules are loaded as well. Loading is canceled with ctx.
+func newConfig(ctx context.Context, fset *token.FileSet, dir string, overlay map[string][]byte, tags []string, tests bool) *packages.Config {
+	env := os.Environ()
+	if workFile(dir) != "" {
+		env = workspaceEnv(env)
+	}
+	return &packages.Config{
+		Context:    ctx,
+		Overlay:    overlay,
+		Mode:       packages.LoadAllSyntax,
+		Tests:      tests,
+		Dir:        dir,
+		Fset:       fset,
+		BuildFlags: []string{"-tags", strings.Join(tags, ",")},
+		Env:        env,
+	}
+}
+
+// loadTests reports whether the test variants of the packages are
+// loaded to fill the literals in the files at paths: the value of
+// tests or, if it is nil, whether one of the files is a test file.
+func loadTests(tests *bool, paths ...string) bool {
+	if tests != nil {
+		return *tests
+	}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			return true
+		}
 	}
+	return false
+}
+
+// emit writes the edits in outs to the file at path if write is set.
+// Otherwise, it prints them as JSON, with the offsets and columns in the
+// units of encoding. The edits of a read-only file, see isReadOnly, are
+// printed instead of written and an error is returned. Unless allowGenerated
+// is set, the edits of a generated file are neither written nor printed:
+// a JSON warning is printed instead and a generatedError is returned.
+func emit(path string, overlay map[string][]byte, outs []output, allowGenerated, write, fixImports bool, encoding string) error {
+	src, err := readSource(path, overlay)
+	if err != nil {
+		return err
+	}
+	if !allowGenerated && isGenerated(src) {
+		gerr := &generatedError{File: path}
+		if err := writeGenerated(os.Stdout, gerr); err != nil {
+			return err
+		}
+		return gerr
+	}
+
+	readOnly := write && isReadOnly(path, readOnlyRoots(filepath.Dir(path)))
+	if write && !readOnly {
+		return writeFile(path, overlay, outs, fixImports)
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(encodePositions(src, outs, encoding)); err != nil {
+		return err
+	}
+	if readOnly {
+		return fmt.Errorf("refusing to write read-only file %s", path)
+	}
+	return nil
+}
 
-	log.Fatal(errNotFound)
+// undoFile reverts the edit with the given ID at offset
+// in the file at path. The original bytes of the edit
+// are read from stdin.
+func undoFile(path string, offset int, id, encoding string, allowGenerated, write, fixImports bool) error {
+	orig, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	src, err := readSource(path, nil)
+	if err != nil {
+		return err
+	}
+	if offset, err = byteOffset(src, offset, encoding); err != nil {
+		return err
+	}
+	out, err := undoEdit(src, offset, id, orig)
+	if err != nil {
+		return err
+	}
+	outs := []output{out}
+	setRegions(src, outs)
+	return emit(path, nil, outs, allowGenerated, write, fixImports, encoding)
 }
 
 func absPath(filename string) (string, error) {
@@ -162,27 +872,128 @@ func absPath(filename string) (string, error) {
 	return filepath.Abs(eval)
 }
 
-func byOffset(lprog []*packages.Package, path string, offset int) error {
+// compileFilter compiles the regular expression of -only or -ignore.
+// It returns nil for an empty expression.
+func compileFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+func byOffset(lprog []*packages.Package, path string, offset int, opts options) ([]output, error) {
 	f, pkg, pos, err := findPos(lprog, path, offset)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	lit, litInfo, err := findCompositeLit(f, pkg.TypesInfo, pos)
+	importNames := buildImportNameMap(f)
+	lit, litInfo, err := pickCompositeLit(pkg.Fset, f, pkg.TypesInfo, pos, opts.pick)
+	if errors.Is(err, errNotFound) {
+		if spkg := syntacticPackage(pkg, f, pos); spkg != nil {
+			pkg = spkg
+			lit, litInfo, err = pickCompositeLit(pkg.Fset, f, pkg.TypesInfo, pos, opts.pick)
+		}
+	}
+
+	// Fill the arguments of a call without arguments
+	// unless the call encloses the literal.
+	if call, sig := findCall(f, pkg.TypesInfo, pos); call != nil && (err != nil || lit.Pos() <= call.Pos()) {
+		start := lprog[0].Fset.Position(call.Pos()).Offset
+		end := lprog[0].Fset.Position(call.End()).Offset
+		out, err := fillCall(pkg, importNames, call, sig, start, end, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []output{out}, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	start := lprog[0].Fset.Position(lit.Pos()).Offset
 	end := lprog[0].Fset.Position(lit.End()).Offset
 
-	importNames := buildImportNameMap(f)
-	newlit, lines := zeroValue(pkg.Types, importNames, lit, litInfo)
-	out, err := prepareOutput(newlit, lines, start, end)
+	litInfo.value = opts.example
+	outs, err := fillLit(pkg, f, importNames, lit, litInfo, start, end, opts)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if cands := candidates(lprog[0].Fset, f, pkg.TypesInfo, pkg.Types, importNames, pos); len(cands) > 1 {
+		outs[0].Candidates = cands
+	}
+	return outs, nil
+}
+
+// fillEach fills the literals at the i-th offsets and lines of the file at
+// path, see fillAt, concurrently, see fillWorkers. The i-th offset falls
+// back to the i-th line, as for a single literal. The edits and the
+// errors are indexed by i. Once ctx is canceled, the remaining literals
+// fail with its error.
+func fillEach(ctx context.Context, pkgs []*packages.Package, path string, offsets, lines intList, opts options) ([][]output, []error) {
+	n := len(offsets)
+	if len(lines) > n {
+		n = len(lines)
 	}
-	return json.NewEncoder(os.Stdout).Encode([]output{out})
+	outs := make([][]output, n)
+	errs := make([]error, n)
+	if opts.share {
+		opts.sharedNames = make(sharedNames)
+	}
+	parallel(n, fillWorkers(opts), func(i int) {
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			return
+		}
+		outs[i], errs[i] = fillAt(pkgs, path, offsets.at(i), lines.at(i), opts)
+	})
+	return outs, errs
+}
+
+// fillAt fills the literal at offset or, if there is none, the literals
+// on line. An offset or line of 0 is not used.
+func fillAt(pkgs []*packages.Package, path string, offset, line int, opts options) ([]output, error) {
+	err := errNotFound
+	if offset > 0 {
+		var outs []output
+		outs, err = byOffset(pkgs, path, offset, opts)
+		if !errors.Is(err, errNotFound) {
+			return outs, err
+		}
+	}
+	if line > 0 {
+		return byLine(pkgs, path, line, opts)
+	}
+	return nil, err
+}
+
+// intList is a flag of integers, which is repeated or comma-separated.
+type intList []int
+
+func (l *intList) String() string {
+	s := make([]string, len(*l))
+	for i, n := range *l {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *intList) Set(s string) error {
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return err
+		}
+		*l = append(*l, n)
+	}
+	return nil
+}
+
+// at returns the i-th integer of l, or 0 if there is none.
+func (l intList) at(i int) int {
+	if i < len(l) {
+		return l[i]
+	}
+	return 0
 }
 
 func findPos(lprog []*packages.Package, path string, off int) (*ast.File, *packages.Package, token.Pos, error) {
@@ -202,26 +1013,44 @@ func findPos(lprog []*packages.Package, path string, off int) (*ast.File, *packa
 	return nil, nil, 0, fmt.Errorf("could not find file %q", path)
 }
 
-func findCompositeLit(f *ast.File, info *types.Info, pos token.Pos) (*ast.CompositeLit, litInfo, error) {
+func findCompositeLit(fset *token.FileSet, f *ast.File, info *types.Info, pos token.Pos) (*ast.CompositeLit, litInfo, error) {
+	return pickCompositeLit(fset, f, info, pos, 0)
+}
+
+// pickCompositeLit returns the pick-th composite literal enclosing pos,
+// counting outwards from the innermost one, see -pick.
+func pickCompositeLit(fset *token.FileSet, f *ast.File, info *types.Info, pos token.Pos, pick int) (*ast.CompositeLit, litInfo, error) {
 	var linfo litInfo
 	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
-	for i, n := range path {
-		if lit, ok := n.(*ast.CompositeLit); ok {
-			linfo.name, _ = info.Types[lit].Type.(*types.Named)
-			linfo.typ, ok = info.Types[lit].Type.Underlying().(*types.Struct)
-			if !ok {
-				return nil, linfo, errNotFound
-			}
-			if expr, ok := path[i+1].(ast.Expr); ok {
-				linfo.hideType = hideType(info.Types[expr].Type)
-			}
-			return lit, linfo, nil
+	n := 0
+	for i, node := range path {
+		lit, ok := node.(*ast.CompositeLit)
+		if !ok {
+			continue
+		}
+		if n++; n <= pick {
+			continue
+		}
+		t := info.Types[lit].Type
+		if t == nil || !isFillable(t) && !isStructCollection(t, lit) {
+			return nil, linfo, notFound(fset, lit, t)
 		}
+		linfo.setType(t)
+		if expr, ok := path[i+1].(ast.Expr); ok {
+			linfo.hideType = hideType(info.Types[expr].Type)
+		}
+		// Elided types, e.g. of the elements of
+		// a named array type, stay elided.
+		linfo.hideType = linfo.hideType || lit.Type == nil
+		return lit, linfo, nil
+	}
+	if n > 0 {
+		return nil, linfo, fmt.Errorf("cannot pick literal %d, there are only %d literals at the selection", pick, n)
 	}
 	return nil, linfo, errNotFound
 }
 
-func byLine(lprog []*packages.Package, path string, line int) (err error) {
+func byLine(lprog []*packages.Package, path string, line int, opts options) (outs []output, err error) {
 	var f *ast.File
 	var pkg *packages.Package
 	for _, p := range lprog {
@@ -233,59 +1062,147 @@ func byLine(lprog []*packages.Package, path string, line int) (err error) {
 		}
 	}
 	if f == nil || pkg == nil {
-		return fmt.Errorf("could not find file %q", path)
+		return nil, fmt.Errorf("could not find file %q", path)
 	}
 	importNames := buildImportNameMap(f)
 
-	var outs []output
 	var prev types.Type
 	ast.Inspect(f, func(n ast.Node) bool {
 		lit, ok := n.(*ast.CompositeLit)
 		if !ok {
 			return true
 		}
-		startLine := pkg.Fset.Position(lit.Pos()).Line
-		endLine := pkg.Fset.Position(lit.End()).Line
+		startLine := pkg.Fset.PositionFor(lit.Pos(), false).Line
+		endLine := pkg.Fset.PositionFor(lit.End(), false).Line
 
 		if !(startLine <= line && line <= endLine) {
 			return true
 		}
 
-		var info litInfo
-		info.name, _ = pkg.TypesInfo.Types[lit].Type.(*types.Named)
-		info.typ, ok = pkg.TypesInfo.Types[lit].Type.Underlying().(*types.Struct)
-		if !ok {
-			prev = pkg.TypesInfo.Types[lit].Type.Underlying()
-			err = errNotFound
+		// Literals of named map, slice and array types are only filled
+		// if they are empty, otherwise their elements are preferred.
+		lpkg := pkg
+		t := pkg.TypesInfo.Types[lit].Type
+		if !isValid(t) {
+			if spkg := syntacticPackage(pkg, f, lit.Pos()); spkg != nil {
+				lpkg = spkg
+				t = spkg.TypesInfo.Types[lit].Type
+			}
+		}
+		if t == nil {
+			err = notFound(pkg.Fset, lit, t)
+			return true
+		}
+		_, isStruct := t.Underlying().(*types.Struct)
+		fillable := isFillable(t) || len(lit.Elts) == 0 && isStructCollection(t, lit)
+		if !fillable || !isStruct && len(lit.Elts) > 0 {
+			prev = t.Underlying()
+			err = notFound(pkg.Fset, lit, t)
 			return true
 		}
+		var info litInfo
+		info.setType(t)
 		info.hideType = hideType(prev)
+		info.value = opts.example
 
 		startOff := pkg.Fset.Position(lit.Pos()).Offset
 		endOff := pkg.Fset.Position(lit.End()).Offset
-		newlit, lines := zeroValue(pkg.Types, importNames, lit, info)
 
-		var out output
-		out, err = prepareOutput(newlit, lines, startOff, endOff)
+		var louts []output
+		louts, err = fillLit(lpkg, f, importNames, lit, info, startOff, endOff, opts)
 		if err != nil {
 			return false
 		}
-		outs = append(outs, out)
+		outs = append(outs, louts...)
 		return false
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(outs) == 0 {
-		return errNotFound
+		return nil, errNotFound
 	}
 
 	for i := len(outs)/2 - 1; i >= 0; i-- {
 		opp := len(outs) - 1 - i
 		outs[i], outs[opp] = outs[opp], outs[i]
 	}
+	return outs, nil
+}
+
+// isFillable reports whether a literal of type t can be filled:
+// struct literals and literals of named map, slice or array types.
+func isFillable(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Struct:
+		return true
+	case *types.Map, *types.Slice, *types.Array:
+		return isNamed(t)
+	default:
+		return false
+	}
+}
+
+// isStructCollection reports whether lit is a literal of an unnamed slice,
+// array or map type of structs, e.g. []User{{}, {}}, with element or value
+// literals; they are all filled at once. Empty array literals, e.g.
+// [3]User{}, are filled with an element literal for every index.
+func isStructCollection(t types.Type, lit *ast.CompositeLit) bool {
+	var elem types.Type
+	switch t := t.(type) {
+	case *types.Slice:
+		elem = t.Elem()
+	case *types.Array:
+		elem = t.Elem()
+	case *types.Map:
+		if isStruct(t.Key()) {
+			for _, e := range lit.Elts {
+				// Ill-typed map literals may have elements without keys.
+				kv, ok := e.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if _, ok := kv.Key.(*ast.CompositeLit); ok {
+					return true
+				}
+			}
+		}
+		elem = t.Elem()
+	default:
+		return false
+	}
+	if !isStruct(elem) {
+		return false
+	}
+	if _, isArray := t.(*types.Array); isArray && len(lit.Elts) == 0 {
+		return true
+	}
+	return hasElementLits(lit)
+}
 
-	return json.NewEncoder(os.Stdout).Encode(outs)
+// hasElementLits reports whether an element or value of lit is a literal.
+func hasElementLits(lit *ast.CompositeLit) bool {
+	for _, e := range lit.Elts {
+		if kv, ok := e.(*ast.KeyValueExpr); ok {
+			e = kv.Value
+		}
+		if u, ok := e.(*ast.UnaryExpr); ok {
+			e = u.X
+		}
+		if _, ok := e.(*ast.CompositeLit); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isStruct reports whether t is a struct or a pointer to a struct.
+func isStruct(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
 }
 
 func hideType(t types.Type) bool {
@@ -312,13 +1229,202 @@ func buildImportNameMap(f *ast.File) map[string]string {
 	return imports
 }
 
+// options contains the settings given on the command line.
+type options struct {
+	fold         int            // number of lines above which fields are folded, 0 disables folding
+	foldOpen     string         // opening fold marker
+	foldClose    string         // closing fold marker
+	example      interface{}    // example document to fill the literals with, see readExample
+	mode         string         // fill mode, see validMode
+	order        string         // field order, see validOrder
+	seed         int64          // seed for the pseudo-random values of modeFuzz
+	snippet      bool           // add a snippet to the output
+	todo         bool           // append TODO comments to newly filled fields
+	nolint       string         // comma-separated linters to append //nolint comments for to newly filled fields
+	typeHints    bool           // append the types of nil and opaque values as comments
+	hintWidth    int            // maximum length of the types of typeHints, 0 means no limit
+	fieldDocs    bool           // add the declarations and docs of newly filled fields to the output
+	emitJSON     bool           // add the JSON encoding of the filled value to the output
+	maxWidth     int            // number of columns up to which literals are emitted on a single line, 0 disables it
+	gofumpt      bool           // format the generated code with gofumpt
+	depth        int            // number of nested struct literals to expand, 0 means no limit
+	purge        bool           // remove fields with zero values instead of filling
+	keyify       bool           // convert positional fields to keyed fields before filling
+	unkeyify     bool           // convert keyed fields to positional fields instead of filling
+	share        bool           // expand repeated nested literals once into variables
+	noDeprecated bool           // do not fill deprecated fields, see deprecations
+	deprecated   *deprecations  // deprecated fields to skip, nil if they are filled; set by withSyntax
+	directives   *directives    // fields and types annotated with //fillstruct:ignore, nil if there are none; set by withSyntax
+	ignored      *[]string      // collects the paths of the fields skipped by directives, set by fillLit
+	only         *regexp.Regexp // fields to fill, see matchField; nil if all are filled
+	ignore       *regexp.Regexp // fields not to fill, see matchField; nil if all are filled
+	exportedOnly bool           // fill only exported fields, even of types of the same package
+	embedded     string         // handling of embedded fields, see validEmbedded
+	recursion    string         // handling of recursive types, see validRecursion
+	shallow      bool           // leave nested structs of the same package with unexported fields empty
+	useScope     bool           // fill fields with variables in scope at the literal
+	vars         []*types.Var   // variables in scope at the literal, see scopeVars
+	constructor  bool           // fill fields with the parameters of constructors of the literal
+	params       []*types.Var   // parameters of the constructor of the literal, see constructorParams
+	requiredOnly bool           // fill only the fields needed by validators, see isNeeded
+	pick         int            // index of the literal enclosing the offset to fill, see pickCompositeLit
+	encoding     string         // unit of the offsets and columns of the output, see validEncoding
+
+	shareable   map[*ast.CompositeLit]bool // collects the literals which can be shared, set by fillLit
+	sharedNames sharedNames                // names of the variables of -share, set by fillEach and fillPositions
+	files       syntaxFiles                // the syntax trees of the loaded packages, set by withSyntax
+}
+
+// withSyntax returns opts with the lookups of the declarations of fields
+// and types, i.e. of -skipdeprecated, -fielddocs and //fillstruct:ignore
+// directives, in the syntax trees of pkgs, which were loaded with fset.
+func withSyntax(fset *token.FileSet, pkgs []*packages.Package, opts options) options {
+	opts.files = newSyntaxFiles(fset, pkgs)
+	if opts.noDeprecated {
+		opts.deprecated = newDeprecations(fset, opts.files)
+	}
+	opts.directives = newDirectives(fset, opts.files)
+	return opts
+}
+
 type output struct {
-	Start int    `json:"start"`
-	End   int    `json:"end"`
-	Code  string `json:"code"`
+	File       string          `json:"file,omitempty"`
+	Start      int             `json:"start"`
+	End        int             `json:"end"`
+	StartLine  int             `json:"startline"`
+	StartCol   int             `json:"startcol"`
+	EndLine    int             `json:"endline"`
+	EndCol     int             `json:"endcol"`
+	Hash       string          `json:"hash"`
+	Status     string          `json:"status"`
+	Message    string          `json:"message,omitempty"`
+	Code       string          `json:"code"`
+	Snippet    string          `json:"snippet,omitempty"`
+	Fields     []fieldDoc      `json:"fields,omitempty"`
+	JSON       json.RawMessage `json:"json,omitempty"`
+	Ignored    []string        `json:"ignored,omitempty"`
+	Candidates []candidate     `json:"candidates,omitempty"`
+	Indent     string          `json:"indent,omitempty"`
+	ID         string          `json:"id"`
 }
 
-func prepareOutput(n ast.Node, lines, start, end int) (output, error) {
+// fillLit returns the outputs for the literal lit in file, which is either
+// the filled literal, with -purge the literal without zero values or,
+// with -unkeyify, the positional literal. With -keyify, positional
+// fields are converted to keyed fields first. With -share, the filled
+// literal is followed by the declaration of the shared literals.
+func fillLit(pkg *packages.Package, file *ast.File, importNames map[string]string, lit *ast.CompositeLit, info litInfo, start, end int, opts options) ([]output, error) {
+	if opts.unkeyify {
+		if err := unkeyify(pkg.TypesInfo, lit); err != nil {
+			return nil, err
+		}
+		f := filler{pos: 1, flat: true}
+		f.fixExprPos(lit)
+		out, err := prepareOutput(lit, f.lines, start, end, newFillInfo(pkg.Fset, pkg.Types, importNames, lit, info), opts)
+		if err != nil {
+			return nil, err
+		}
+		return []output{out}, nil
+	}
+
+	if opts.keyify {
+		keyify(pkg.TypesInfo, lit)
+	} else if isPositional(pkg.TypesInfo, lit) && opts.purge {
+		return nil, errors.New("cannot purge a literal with positional fields, use -keyify")
+	} else if isPositional(pkg.TypesInfo, lit) {
+		return nil, errors.New("cannot fill a literal with positional fields, use -keyify")
+	}
+
+	fi := newFillInfo(pkg.Fset, pkg.Types, importNames, lit, info)
+	fi.comments = elementComments(pkg.Fset, file.Comments, lit)
+	if opts.fold > 0 {
+		dropFoldMarkers(fi.comments, opts.foldOpen, opts.foldClose)
+	}
+	if opts.purge {
+		purge(pkg.TypesInfo, lit)
+		f := filler{pos: 1}
+		f.fixExprPos(lit)
+		out, err := prepareOutput(lit, f.lines, start, end, fi, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []output{out}, nil
+	}
+
+	if opts.useScope {
+		opts.vars = scopeVars(pkg.Types, lit.Pos())
+	}
+	if opts.constructor {
+		opts.params = constructorParams(pkg.Types, pkg.TypesInfo, file, lit)
+	}
+	var ignored []string
+	opts.ignored = &ignored
+	if opts.share {
+		opts.shareable = make(map[*ast.CompositeLit]bool)
+	}
+	newlit, lines := zeroValue(pkg.Types, importNames, lit, info, opts)
+	var example json.RawMessage
+	if opts.emitJSON {
+		t := info.typ
+		if info.name != nil {
+			t = info.name
+		}
+		var err error
+		if example, err = jsonExample(newlit, t); err != nil {
+			return nil, err
+		}
+	}
+	var decl output
+	shared := false
+	if opts.share {
+		var err error
+		if decl, shared, err = shareLit(pkg.Fset, file, pkg.Types, lit, newlit, opts.shareable, opts.sharedNames); err != nil {
+			return nil, err
+		}
+		if shared {
+			// Renumber the lines of the remaining literal.
+			f := filler{pos: 1}
+			f.fixExprPos(newlit)
+			lines = f.lines
+		}
+	}
+	out, err := prepareOutput(newlit, lines, start, end, fi, opts)
+	if err != nil {
+		return nil, err
+	}
+	out.JSON = example
+	out.Ignored = ignored
+	if !shared {
+		return []output{out}, nil
+	}
+	return []output{out, decl}, nil
+}
+
+// lineComments returns code with the comments of -nolint, -typehints and
+// -todo appended to the lines of the newly filled fields.
+func lineComments(code string, fi fillInfo, opts options) (string, error) {
+	var err error
+	// The nolint directive comes first, since linters only
+	// recognize it at the beginning of a comment.
+	if opts.nolint != "" {
+		if code, err = commentFields(code, fi.existing, "//nolint:"+opts.nolint); err != nil {
+			return "", err
+		}
+	}
+	if opts.typeHints {
+		if code, err = typeHints(code, fi, opts.hintWidth); err != nil {
+			return "", err
+		}
+	}
+	if opts.todo {
+		if code, err = todoComments(code, fi.existing); err != nil {
+			return "", err
+		}
+	}
+	return code, nil
+}
+
+func prepareOutput(n ast.Node, lines, start, end int, fi fillInfo, opts options) (output, error) {
 	fset := token.NewFileSet()
 	file := fset.AddFile("", -1, lines)
 	for i := 1; i <= lines; i++ {
@@ -329,9 +1435,55 @@ func prepareOutput(n ast.Node, lines, start, end int) (output, error) {
 	if err := format.Node(&buf, fset, n); err != nil {
 		return output{}, err
 	}
-	return output{
+	code := buf.String()
+	// Literals with comments are not compacted: the comments would be lost,
+	// and line comments, e.g. of -todo, would comment out the rest of the line.
+	if opts.maxWidth > 0 && len(fi.comments) == 0 {
+		if commented, err := lineComments(code, fi, opts); err == nil && commented == code {
+			if line, ok := compact(n, fset, opts.maxWidth); ok {
+				code = line
+			}
+		}
+	}
+	if opts.gofumpt {
+		var err error
+		if code, err = gofumptCode(code); err != nil {
+			return output{}, err
+		}
+	}
+	if len(fi.comments) > 0 {
+		var err error
+		if code, err = restoreComments(code, fi.comments); err != nil {
+			return output{}, err
+		}
+	}
+	code, err := lineComments(code, fi, opts)
+	if err != nil {
+		return output{}, err
+	}
+	if opts.nolint != "" || opts.typeHints || opts.todo || len(fi.comments) > 0 {
+		if code, err = alignComments(code); err != nil {
+			return output{}, err
+		}
+	}
+	code, err = foldRegions(code, opts.fold, opts.foldOpen, opts.foldClose)
+	if err != nil {
+		return output{}, err
+	}
+	out := output{
 		Start: start,
 		End:   end,
-		Code:  buf.String(),
-	}, nil
+		Code:  code,
+	}
+	if opts.snippet {
+		if out.Snippet, err = snippet(code); err != nil {
+			return output{}, err
+		}
+	}
+	if opts.fieldDocs {
+		if out.Fields, err = fieldDocs(code, fi, opts.files); err != nil {
+			return output{}, err
+		}
+	}
+	return out, nil
 }
@@ -0,0 +1,133 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// Fill modes, selected with the -mode flag.
+const (
+	modeZero        = "zero"        // fill with zero values
+	modeFuzz        = "fuzz"        // fill with pseudo-random non-zero values
+	modePlaceholder = "placeholder" // fill with values describing the field
+)
+
+func validMode(mode string) bool {
+	switch mode {
+	case "", modeZero, modeFuzz, modePlaceholder:
+		return true
+	default:
+		return false
+	}
+}
+
+// basicValue returns the literal for a value of the basic type t
+// according to the fill mode, or false if the zero value is used.
+func (f *filler) basicValue(t *types.Basic) (string, bool) {
+	switch f.mode {
+	case modeFuzz:
+		return f.fuzzValue(t)
+	case modePlaceholder:
+		return f.placeholderValue(t)
+	default:
+		return "", false
+	}
+}
+
+// fuzzValue returns a pseudo-random non-zero value of the basic type t.
+func (f *filler) fuzzValue(t *types.Basic) (string, bool) {
+	switch {
+	case t.Info()&types.IsBoolean != 0:
+		return "true", true
+	case t.Info()&types.IsString != 0:
+		return strconv.Quote(f.word()), true
+	case t.Kind() == types.Uintptr || t.Kind() == types.UnsafePointer:
+		return "", false
+	case t.Info()&types.IsInteger != 0:
+		return strconv.Itoa(1 + f.rand.Intn(99)), true
+	case t.Info()&types.IsFloat != 0:
+		return fmt.Sprintf("%d.%d", 1+f.rand.Intn(99), 1+f.rand.Intn(9)), true
+	case t.Info()&types.IsComplex != 0:
+		return fmt.Sprintf("(%d + %di)", 1+f.rand.Intn(9), 1+f.rand.Intn(9)), true
+	default:
+		return "", false
+	}
+}
+
+// placeholderValue returns a value of the basic type t which identifies
+// the filled field: strings contain the path of the field, numbers are
+// unique, unless integers wrap around in their type, and booleans
+// alternate.
+func (f *filler) placeholderValue(t *types.Basic) (string, bool) {
+	switch {
+	case t.Info()&types.IsBoolean != 0:
+		f.flip = !f.flip
+		return strconv.FormatBool(f.flip), true
+	case t.Info()&types.IsString != 0:
+		return strconv.Quote(f.fieldPath()), true
+	case t.Kind() == types.Uintptr || t.Kind() == types.UnsafePointer:
+		return "", false
+	case t.Info()&types.IsInteger != 0:
+		f.counter++
+		return strconv.Itoa(wrapInt(f.counter, t)), true
+	case t.Info()&types.IsFloat != 0:
+		f.counter++
+		return strconv.Itoa(f.counter) + ".0", true
+	case t.Info()&types.IsComplex != 0:
+		f.counter++
+		return fmt.Sprintf("(%d + 0i)", f.counter), true
+	default:
+		return "", false
+	}
+}
+
+// wrapInt returns the positive number n wrapped around into the positive
+// values of the integer type t, e.g. 1 for 128 and int8.
+func wrapInt(n int, t *types.Basic) int {
+	bits := bitSize(t)
+	if t.Info()&types.IsUnsigned == 0 {
+		bits-- // the sign bit
+	}
+	if bits >= strconv.IntSize-1 {
+		return n
+	}
+	max := 1<<bits - 1
+	return (n-1)%max + 1
+}
+
+// sequenceLen returns the number of elements of a filled slice literal.
+func (f *filler) sequenceLen() int64 {
+	if f.mode == modeFuzz || f.mode == modePlaceholder {
+		return 1
+	}
+	return 0
+}
+
+// fieldPath returns the path of the field currently
+// being filled, e.g. "user.addrs[0].city".
+func (f *filler) fieldPath() string {
+	var b strings.Builder
+	for i, p := range f.path {
+		if i > 0 && !strings.HasPrefix(p, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// word returns a pseudo-random lower-case word.
+func (f *filler) word() string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, 4+f.rand.Intn(5))
+	for i := range b {
+		b[i] = letters[f.rand.Intn(len(letters))]
+	}
+	return string(b)
+}
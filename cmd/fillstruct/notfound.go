@@ -0,0 +1,94 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+)
+
+// notFoundError is an errNotFound which explains why the composite
+// literal nearest to the selection cannot be filled.
+type notFoundError struct {
+	Literal *rejectedLit `json:"literal"`
+}
+
+// rejectedLit is a composite literal which cannot be filled.
+type rejectedLit struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Line   int    `json:"line"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// notFound returns an errNotFound explaining why the literal lit of
+// type t cannot be filled.
+func notFound(fset *token.FileSet, lit *ast.CompositeLit, t types.Type) error {
+	typ := "invalid type"
+	if t != nil {
+		typ = t.String()
+	}
+	return &notFoundError{Literal: &rejectedLit{
+		Start:  fset.Position(lit.Pos()).Offset,
+		End:    fset.Position(lit.End()).Offset,
+		Line:   fset.PositionFor(lit.Pos(), false).Line,
+		Type:   typ,
+		Reason: rejection(t, lit),
+	}}
+}
+
+func (e *notFoundError) Error() string {
+	l := e.Literal
+	return fmt.Sprintf("%v: the literal of type %s at line %d cannot be filled: %s", errNotFound, l.Type, l.Line, l.Reason)
+}
+
+func (e *notFoundError) Is(target error) bool { return target == errNotFound }
+
+// rejection returns why a literal lit of type t cannot be filled.
+func rejection(t types.Type, lit *ast.CompositeLit) string {
+	if t == nil || t == types.Typ[types.Invalid] {
+		return "its type is unknown, e.g. because the package or one of its imports does not load"
+	}
+	var kind string
+	var elem types.Type
+	switch u := t.Underlying().(type) {
+	case *types.Map:
+		kind, elem = "map", u.Elem()
+	case *types.Slice:
+		kind, elem = "slice", u.Elem()
+	case *types.Array:
+		kind, elem = "array", u.Elem()
+	default:
+		return fmt.Sprintf("literal of %s", t)
+	}
+	named := isNamed(t)
+	switch {
+	case hasElementLits(lit):
+		return fmt.Sprintf("%s literal whose element literals are filled instead", kind)
+	case !named && !isStruct(elem):
+		return fmt.Sprintf("%s of %s, only structs and named map, slice and array types are filled", kind, elem)
+	case len(lit.Elts) > 0:
+		return fmt.Sprintf("%s literal with elements but no element literals to fill", kind)
+	default:
+		return fmt.Sprintf("empty %s literal of an unnamed type, only element literals of it are filled", kind)
+	}
+}
+
+// writeNotFound writes the explanation of err, if it has one, as JSON to w.
+func writeNotFound(w io.Writer, err error) error {
+	e, ok := err.(*notFoundError)
+	if !ok {
+		return nil
+	}
+	return json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		*notFoundError
+	}{errNotFound.Error(), e})
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+)
+
+// Field orders, selected with the -order flag.
+const (
+	orderDecl  = "decl"  // order of the struct declaration
+	orderAlpha = "alpha" // alphabetical order of the field names
+	orderKeep  = "keep"  // existing fields in place, missing fields appended
+)
+
+func validOrder(order string) bool {
+	switch order {
+	case "", orderDecl, orderAlpha, orderKeep:
+		return true
+	default:
+		return false
+	}
+}
+
+// fieldOrder returns the indices of the fields of s in the order
+// in which they are emitted into the literal of s. lit is the
+// existing keyed literal, if any.
+func fieldOrder(s *types.Struct, lit *ast.CompositeLit, order string) []int {
+	indices := make([]int, 0, s.NumFields())
+	switch order {
+	case orderAlpha:
+		for i := 0; i < s.NumFields(); i++ {
+			indices = append(indices, i)
+		}
+		sort.SliceStable(indices, func(i, j int) bool {
+			return s.Field(indices[i]).Name() < s.Field(indices[j]).Name()
+		})
+	case orderKeep:
+		added := make(map[int]bool)
+		if lit != nil {
+			for _, e := range lit.Elts {
+				kv, ok := e.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				id, ok := kv.Key.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				for i := 0; i < s.NumFields(); i++ {
+					if s.Field(i).Name() == id.Name && !added[i] {
+						indices = append(indices, i)
+						added[i] = true
+					}
+				}
+			}
+		}
+		for i := 0; i < s.NumFields(); i++ {
+			if !added[i] {
+				indices = append(indices, i)
+			}
+		}
+	default:
+		for i := 0; i < s.NumFields(); i++ {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
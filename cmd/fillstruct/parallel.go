@@ -0,0 +1,55 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallel calls f for 0 <= i < n on up to workers goroutines and
+// returns once all calls have returned.
+func parallel(n, workers int, f func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// fillWorkers returns the number of goroutines filling literals with
+// opts concurrently. The type information and the syntax trees are only
+// read: the existing elements of a literal are copied before they are
+// moved to the lines of the filled literal, see copyExpr. -keyify,
+// -unkeyify and -purge rewrite the literals in place, and -share reserves
+// the names of its variables in the order of the literals, such that they
+// are filled one after another then.
+func fillWorkers(opts options) int {
+	if opts.keyify || opts.unkeyify || opts.purge || opts.share {
+		return 1
+	}
+	return runtime.GOMAXPROCS(0)
+}
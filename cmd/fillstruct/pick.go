@@ -0,0 +1,61 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// candidate is a literal enclosing -offset, which is filled with -pick.
+type candidate struct {
+	Pick  int    `json:"pick"`  // value of -pick to fill the literal, 0 is the innermost
+	Type  string `json:"type"`  // type of the literal
+	Start int    `json:"start"` // offset of the start of the literal
+	End   int    `json:"end"`   // offset of the end of the literal
+	Line  int    `json:"line"`  // line of the start of the literal
+}
+
+// enclosingLits returns the composite literals enclosing pos,
+// innermost first.
+func enclosingLits(f *ast.File, pos token.Pos) []*ast.CompositeLit {
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	var lits []*ast.CompositeLit
+	for _, n := range path {
+		if lit, ok := n.(*ast.CompositeLit); ok {
+			lits = append(lits, lit)
+		}
+	}
+	return lits
+}
+
+// candidates returns the fillable literals enclosing pos, which editors
+// can offer to choose from if there is more than one, e.g. the inner and
+// the outer literal of Outer{Inner: Inner{}}.
+func candidates(fset *token.FileSet, f *ast.File, info *types.Info, pkg *types.Package, importNames map[string]string, pos token.Pos) []candidate {
+	var cands []candidate
+	for i, lit := range enclosingLits(f, pos) {
+		t := info.Types[lit].Type
+		if t == nil || !isFillable(t) && !isStructCollection(t, lit) {
+			continue
+		}
+		typeName, ok := typeString(pkg, importNames, t)
+		if !ok {
+			continue
+		}
+		start := fset.PositionFor(lit.Pos(), false)
+		cands = append(cands, candidate{
+			Pick:  i,
+			Type:  typeName,
+			Start: start.Offset,
+			End:   fset.PositionFor(lit.End(), false).Offset,
+			Line:  start.Line,
+		})
+	}
+	return cands
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// position is a position of -positions.
+type position struct {
+	path string // absolute path of the file
+	line int
+	col  int // byte column starting at 1, 0 if unknown
+}
+
+// positionRE matches the position at the beginning of a line of grep -n
+// or compiler output, e.g. "main.go:12:" or "main.go:12:5: error".
+var positionRE = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?(?::|$)`)
+
+// parsePositions reads the positions at the beginning of the lines of r.
+// Lines without a position, e.g. "# pkg" of the compiler, are skipped.
+func parsePositions(r io.Reader) ([]position, error) {
+	var positions []position
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		m := positionRE.FindStringSubmatch(s.Text())
+		if m == nil {
+			continue
+		}
+		path, err := absPath(m[1])
+		if err != nil {
+			return nil, err
+		}
+		pos := position{path: path}
+		pos.line, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			pos.col, _ = strconv.Atoi(m[3])
+		}
+		positions = append(positions, pos)
+	}
+	return positions, s.Err()
+}
+
+// fillPositions fills the literals at the positions read from the file
+// name, or from stdin for "-", and emits the edits file by file. With fixSums,
+// missing go.sum entries are fixed like with loadPackages. Generated files
+// are skipped with a warning unless allowGenerated is set. The test
+// variants of the packages are loaded as described by loadTests. Once
+// ctx is canceled, loading and filling fail with its error and nothing
+// is emitted.
+func fillPositions(ctx context.Context, fset *token.FileSet, name string, modified bool, tags []string, tests *bool, opts options, indent, allowGenerated, write, fixImports, fixSums bool) error {
+	r := os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	} else if modified {
+		return errors.New("-positions - cannot be combined with -modified, both are read from stdin")
+	}
+	positions, err := parsePositions(r)
+	if err != nil {
+		return err
+	}
+
+	var overlay map[string][]byte
+	if modified {
+		if overlay, err = buildutil.ParseOverlayArchive(os.Stdin); err != nil {
+			return fmt.Errorf("invalid archive: %v", err)
+		}
+	}
+
+	// The files are loaded from the workspace or module they are in,
+	// which is not necessarily the one in the current directory.
+	seen := make(map[string]bool)
+	files := make(map[string][]string) // root -> paths of the files
+	var roots []string
+	for _, pos := range positions {
+		if seen[pos.path] {
+			continue
+		}
+		seen[pos.path] = true
+		root := loadRoot(filepath.Dir(pos.path))
+		if files[root] == nil {
+			roots = append(roots, root)
+		}
+		files[root] = append(files[root], pos.path)
+	}
+	if len(roots) == 0 {
+		return errors.New("no positions found")
+	}
+	var pkgs []*packages.Package
+	for _, root := range roots {
+		var patterns []string
+		for _, path := range files[root] {
+			patterns = append(patterns, "file="+path)
+		}
+		cfg := newConfig(ctx, fset, root, overlay, tags, loadTests(tests, files[root]...))
+		rpkgs, cleanup, err := loadPackages(cfg, patterns, fixSums)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		pkgs = append(pkgs, rpkgs...)
+	}
+	opts = withSyntax(fset, pkgs, opts)
+
+	louts := make([][]output, len(positions))
+	errs := make([]error, len(positions))
+	if opts.share {
+		opts.sharedNames = make(sharedNames)
+	}
+	parallel(len(positions), fillWorkers(opts), func(i int) {
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			return
+		}
+		louts[i], errs[i] = fillPosition(pkgs, positions[i], opts)
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	edits := make(map[string][]output) // path -> edits
+	for i, pos := range positions {
+		if errs[i] != nil {
+			log.Printf("%s:%d: %v", pos.path, pos.line, errs[i])
+			continue
+		}
+		edits[pos.path] = append(edits[pos.path], louts[i]...)
+	}
+
+	paths := make([]string, 0, len(edits))
+	for path := range edits {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		outs := outermostEdits(edits[path])
+		src, err := readSource(path, overlay)
+		if err != nil {
+			return err
+		}
+		setIndents(src, outs, indent || write)
+		setLineEndings(src, outs)
+		if err := setIDs(src, outs); err != nil {
+			return err
+		}
+		setRegions(src, outs)
+		for i := range outs {
+			outs[i].File = path
+		}
+		err = emit(path, overlay, outs, allowGenerated, write, fixImports, opts.encoding)
+		var gerr *generatedError
+		if errors.As(err, &gerr) {
+			log.Print(err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillPosition fills the literal at pos. Like -offset and -line, it
+// falls back to the line if there is no literal at the column.
+func fillPosition(pkgs []*packages.Package, pos position, opts options) ([]output, error) {
+	if pos.col > 0 {
+		offset, err := lineOffset(pkgs, pos)
+		if err != nil {
+			return nil, err
+		}
+		outs, err := byOffset(pkgs, pos.path, offset, opts)
+		if !errors.Is(err, errNotFound) {
+			return outs, err
+		}
+	}
+	return byLine(pkgs, pos.path, pos.line, opts)
+}
+
+// lineOffset returns the byte offset of the line and column of pos.
+func lineOffset(pkgs []*packages.Package, pos position) (int, error) {
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Syntax {
+			file := pkg.Fset.File(f.Pos())
+			if file.Name() != pos.path {
+				continue
+			}
+			if pos.line > file.LineCount() {
+				return 0, fmt.Errorf("file has only %d lines", file.LineCount())
+			}
+			offset := file.Offset(file.LineStart(pos.line)) + pos.col - 1
+			if offset > file.Size() {
+				return 0, fmt.Errorf("column %d is beyond the end of the file", pos.col)
+			}
+			return offset, nil
+		}
+	}
+	return 0, fmt.Errorf("could not find file %q", pos.path)
+}
+
+// outermostEdits returns the edits of outs which are not contained
+// in another edit, such as the edit of a literal in the edit of the
+// enclosing literal or the same edit for two positions. Different
+// insertions at the same offset, e.g. the declarations of -share for
+// two literals of a statement, are merged into one in their order.
+func outermostEdits(outs []output) []output {
+	sort.SliceStable(outs, func(i, j int) bool {
+		if outs[i].Start != outs[j].Start {
+			return outs[i].Start < outs[j].Start
+		}
+		// Insertions go before an edit starting at the same offset.
+		if insi, insj := outs[i].Start == outs[i].End, outs[j].Start == outs[j].End; insi != insj {
+			return insi
+		}
+		return outs[i].End > outs[j].End
+	})
+	var res []output
+	var merged []string // the code of the insertions merged into the last edit
+	end := -1
+	for _, out := range outs {
+		if len(res) > 0 {
+			last := &res[len(res)-1]
+			if out.Start == last.Start && out.End == last.End {
+				if out.Start == out.End && !contains(merged, out.Code) {
+					last.Code += out.Code
+					merged = append(merged, out.Code)
+				}
+				continue
+			}
+			if out.Start < end && out.End <= end {
+				continue
+			}
+		}
+		res = append(res, out)
+		merged = []string{out.Code}
+		if out.End > end {
+			end = out.End
+		}
+	}
+	return res
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+)
+
+// startProfiles starts writing a CPU profile and an execution trace to
+// the files cpu and trc, unless they are empty. The returned function
+// stops them and writes a heap profile to the file mem, unless it is
+// empty; it is also called if fillstruct is interrupted, e.g. to stop
+// -watch, and only has an effect once.
+func startProfiles(cpu, mem, trc string) (func(), error) {
+	var stops []func() error
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			if err := stops[i](); err != nil {
+				log.Print(err)
+			}
+		}
+	}
+
+	if cpu != "" {
+		f, err := os.Create(cpu)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		stops = append(stops, func() error {
+			pprof.StopCPUProfile()
+			return f.Close()
+		})
+	}
+	if trc != "" {
+		f, err := os.Create(trc)
+		if err == nil {
+			if err = trace.Start(f); err != nil {
+				f.Close()
+			}
+		}
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		stops = append(stops, func() error {
+			trace.Stop()
+			return f.Close()
+		})
+	}
+	if mem != "" {
+		stops = append(stops, func() error {
+			f, err := os.Create(mem)
+			if err != nil {
+				return err
+			}
+			runtime.GC() // up-to-date statistics
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		})
+	}
+
+	var once sync.Once
+	stopOnce := func() { once.Do(stop) }
+	if len(stops) > 0 {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		go func() {
+			<-sig
+			stopOnce()
+			os.Exit(1)
+		}()
+	}
+	return stopOnce, nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+const (
+	protoimplPath   = "google.golang.org/protobuf/runtime/protoimpl"
+	wrapperspbPath  = "google.golang.org/protobuf/types/known/wrapperspb"
+	timestamppbPath = "google.golang.org/protobuf/types/known/timestamppb"
+	durationpbPath  = "google.golang.org/protobuf/types/known/durationpb"
+)
+
+// isMessage reports whether s is the struct of a message generated by
+// protoc-gen-go: it has the internal fields of the protoimpl package,
+// e.g. state protoimpl.MessageState, or, of older versions, XXX_ fields.
+// Only the exported fields of messages are filled, even in their own
+// package.
+func isMessage(s *types.Struct) bool {
+	for i := 0; i < s.NumFields(); i++ {
+		field := s.Field(i)
+		if strings.HasPrefix(field.Name(), "XXX_") {
+			return true
+		}
+		if n := namedOf(field.Type()); n != nil && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == protoimplPath {
+			return true
+		}
+	}
+	return false
+}
+
+// wellKnown returns the call of the constructor of the well-known
+// protobuf type t, which fields of type *t are filled with, e.g.
+// wrapperspb.String("") for *wrapperspb.StringValue, or nil. Timestamps
+// are filled with an empty literal, &timestamppb.Timestamp{}, since the
+// constructor timestamppb.Now would make the filled value differ on every
+// run.
+func (f *filler) wellKnown(t types.Type) ast.Expr {
+	n := namedOf(t)
+	if n == nil || n.Obj().Pkg() == nil {
+		return nil
+	}
+	obj := n.Obj()
+	var (
+		fun  string
+		args []ast.Expr
+	)
+	switch obj.Pkg().Path() {
+	case wrapperspbPath:
+		// The constructors are named after the types,
+		// e.g. wrapperspb.Int64 for Int64Value.
+		s, ok := n.Underlying().(*types.Struct)
+		if !ok || !strings.HasSuffix(obj.Name(), "Value") {
+			return nil
+		}
+		var value *types.Var
+		for i := 0; i < s.NumFields(); i++ {
+			if s.Field(i).Name() == "Value" {
+				value = s.Field(i)
+			}
+		}
+		if value == nil {
+			return nil
+		}
+		arg := f.zero(litInfo{typ: value.Type()}, nil)
+		if arg == nil {
+			return nil
+		}
+		fun, args = strings.TrimSuffix(obj.Name(), "Value"), []ast.Expr{arg}
+	case timestamppbPath:
+		if obj.Name() != "Timestamp" {
+			return nil
+		}
+	case durationpbPath:
+		if obj.Name() != "Duration" {
+			return nil
+		}
+		fun, args = "New", []ast.Expr{&ast.BasicLit{Value: "0", ValuePos: f.pos}}
+	default:
+		return nil
+	}
+
+	typeName, ok := typeString(f.pkg, f.importNames, n)
+	if !ok {
+		return nil
+	}
+	if fun == "" {
+		// The type name of a pointer literal is prefixed with "&", see zero.
+		return &ast.CompositeLit{Type: &ast.Ident{Name: "&" + typeName, NamePos: f.pos}, Lbrace: f.pos, Rbrace: f.pos}
+	}
+	if i := strings.LastIndexByte(typeName, '.'); i >= 0 {
+		fun = typeName[:i+1] + fun
+	}
+	return &ast.CallExpr{
+		Fun:    &ast.Ident{Name: fun, NamePos: f.pos},
+		Lparen: f.pos,
+		Args:   args,
+		Rparen: f.pos,
+	}
+}
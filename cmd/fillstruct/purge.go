@@ -0,0 +1,122 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// purge removes all fields with zero values from the literal lit
+// and its nested literals, in place. Struct and array literals
+// which only contain zero values become empty literals, which
+// makes them zero values themselves.
+func purge(info *types.Info, lit *ast.CompositeLit) {
+	tv, ok := info.Types[lit]
+	if !ok {
+		return
+	}
+	switch tv.Type.Underlying().(type) {
+	case *types.Struct:
+		var elts []ast.Expr
+		for _, e := range lit.Elts {
+			kv, ok := e.(*ast.KeyValueExpr)
+			if !ok {
+				// Positional fields cannot be removed.
+				return
+			}
+			purgeExpr(info, kv.Value)
+			if !isZero(info, kv.Value) {
+				elts = append(elts, kv)
+			}
+		}
+		lit.Elts = elts
+	case *types.Array:
+		allZero := true
+		for _, e := range lit.Elts {
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				e = kv.Value
+			}
+			purgeExpr(info, e)
+			allZero = allZero && isZero(info, e)
+		}
+		if allZero {
+			lit.Elts = nil
+		}
+	case *types.Slice, *types.Map:
+		for _, e := range lit.Elts {
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				e = kv.Value
+			}
+			purgeExpr(info, e)
+		}
+	}
+}
+
+// purgeExpr purges the literal e, if it is one.
+func purgeExpr(info *types.Info, e ast.Expr) {
+	switch e := e.(type) {
+	case *ast.CompositeLit:
+		purge(info, e)
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			purgeExpr(info, e.X)
+		}
+	case *ast.ParenExpr:
+		purgeExpr(info, e.X)
+	}
+}
+
+// isZero reports whether e is the zero value of its type, written as a
+// literal. Named constants are not considered zero values, since they
+// carry the intent of the author.
+func isZero(info *types.Info, e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return isZero(info, e.X)
+	case *ast.CompositeLit:
+		switch info.Types[e].Type.Underlying().(type) {
+		case *types.Struct, *types.Array:
+			return len(e.Elts) == 0
+		default:
+			return false
+		}
+	}
+
+	tv := info.Types[e]
+	if tv.IsNil() {
+		return true
+	}
+	if tv.Value == nil || usesConst(info, e) {
+		return false
+	}
+	switch tv.Value.Kind() {
+	case constant.Bool:
+		return !constant.BoolVal(tv.Value)
+	case constant.String:
+		return constant.StringVal(tv.Value) == ""
+	case constant.Int, constant.Float, constant.Complex:
+		return constant.Sign(tv.Value) == 0
+	default:
+		return false
+	}
+}
+
+// usesConst reports whether e refers to a named constant
+// other than the predeclared constants true and false.
+func usesConst(info *types.Info, e ast.Expr) bool {
+	found := false
+	ast.Inspect(e, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if c, ok := info.Uses[id].(*types.Const); ok && c.Parent() != types.Universe {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readOnlyRoots returns the directories whose files must not be
+// modified: GOROOT and the module cache, as reported by the go
+// command in dir, see goEnv.
+func readOnlyRoots(dir string) []string {
+	var roots []string
+	for _, key := range []string{"GOROOT", "GOMODCACHE"} {
+		root := goEnv(dir, key)
+		if root == "" {
+			continue
+		}
+		if eval, err := filepath.EvalSymlinks(root); err == nil {
+			root = eval
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// isReadOnly reports whether the file at path must not be written by
+// -w, i.e. it is located in one of the given roots or it is not writable.
+func isReadOnly(path string, roots []string) bool {
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().Perm()&0222 == 0
+}
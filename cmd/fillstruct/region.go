@@ -0,0 +1,76 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Statuses of an edit.
+const (
+	statusChanged   = "changed"   // the edit changes the code
+	statusUnchanged = "unchanged" // the literal is already complete
+)
+
+// setRegions sets the lines and columns of the start and end of every
+// edit in outs, and the hash of the text of src it replaces, such that
+// editors can refuse to apply an edit to a buffer changed since. It also
+// sets the status of every edit, see sameCode. The edits must be valid,
+// see setIDs.
+func setRegions(src []byte, outs []output) {
+	for i, out := range outs {
+		outs[i].StartLine, outs[i].StartCol = lineCol(src, out.Start)
+		outs[i].EndLine, outs[i].EndCol = lineCol(src, out.End)
+		outs[i].Hash = regionHash(src[out.Start:out.End])
+		outs[i].Status = statusChanged
+		if sameCode(string(src[out.Start:out.End]), out.Code) {
+			outs[i].Status = statusUnchanged
+			outs[i].Message = "the literal is already complete"
+		}
+	}
+}
+
+// unchanged reports whether there are edits and none of them changes the code.
+func unchanged(outs []output) bool {
+	for _, out := range outs {
+		if out.Status != statusUnchanged {
+			return false
+		}
+	}
+	return len(outs) > 0
+}
+
+// sameCode reports whether the replaced text orig and the code of an
+// edit only differ in the indentation of their lines, which depends on
+// -indent.
+func sameCode(orig, code string) bool {
+	a, b := strings.Split(orig, "\n"), strings.Split(code, "\n")
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// lineCol returns the 1-based line and column of offset in src.
+// Columns are counted in bytes, like in go/token.
+func lineCol(src []byte, offset int) (line, col int) {
+	line = bytes.Count(src[:offset], []byte("\n")) + 1
+	col = offset - bytes.LastIndexByte(src[:offset], '\n')
+	return line, col
+}
+
+// regionHash returns the hex-encoded SHA-256 hash of the replaced text b.
+func regionHash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
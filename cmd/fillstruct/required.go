@@ -0,0 +1,50 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+)
+
+// isRequired reports whether the struct tag marks its field as required
+// by a validator: validate:"required" of go-playground/validator or
+// binding:"required" of gin. Rules after dive apply to the elements of
+// the field instead.
+func isRequired(tag string) bool {
+	st := reflect.StructTag(tag)
+	for _, key := range []string{"validate", "binding"} {
+		for _, rule := range strings.Split(st.Get(key), ",") {
+			if rule == "dive" {
+				break
+			}
+			if rule == "required" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isNeeded reports whether the i-th field of s is filled with
+// -required-only: if it is required, see isRequired, or if it is a
+// struct, not a pointer to one, with needed fields, since validators
+// check the fields of nested structs.
+func isNeeded(s *types.Struct, i int) bool {
+	if isRequired(s.Tag(i)) {
+		return true
+	}
+	nested, ok := s.Field(i).Type().Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	for j := 0; j < nested.NumFields(); j++ {
+		if isNeeded(nested, j) {
+			return true
+		}
+	}
+	return false
+}
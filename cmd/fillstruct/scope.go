@@ -0,0 +1,124 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// scopeVars returns the variables of pkg in scope at pos which are
+// declared in the enclosing functions before pos, i.e. their parameters
+// and locals, innermost first. Shadowed variables are left out.
+func scopeVars(pkg *types.Package, pos token.Pos) []*types.Var {
+	inner := pkg.Scope().Innermost(pos)
+	var vars []*types.Var
+	// The scopes of functions are nested in file scopes,
+	// which are nested in the package scope.
+	for s := inner; s != nil && s != pkg.Scope() && s.Parent() != pkg.Scope(); s = s.Parent() {
+		for _, name := range s.Names() {
+			v, ok := s.Lookup(name).(*types.Var)
+			if !ok || name == "_" {
+				continue
+			}
+			if _, obj := inner.LookupParent(name, pos); obj == v {
+				vars = append(vars, v)
+			}
+		}
+	}
+	return vars
+}
+
+// scopeVar returns the variable of vars to fill the field with, see
+// -use-scope: the one named like the field, see namedVar, or else the
+// only one of the type of the field, unless it is a basic type such as
+// int, or nil.
+func scopeVar(vars []*types.Var, field *types.Var) *types.Var {
+	if v := namedVar(vars, field); v != nil {
+		return v
+	}
+	var match *types.Var
+	n := 0
+	for _, v := range vars {
+		if types.Identical(v.Type(), field.Type()) {
+			match = v
+			n++
+		}
+	}
+	if _, basic := field.Type().(*types.Basic); basic || n != 1 {
+		return nil
+	}
+	return match
+}
+
+// namedVar returns the variable of vars with the type and the name of
+// the field, ignoring case, or nil.
+func namedVar(vars []*types.Var, field *types.Var) *types.Var {
+	for _, v := range vars {
+		if types.Identical(v.Type(), field.Type()) && strings.EqualFold(v.Name(), field.Name()) {
+			return v
+		}
+	}
+	return nil
+}
+
+// constructorParams returns the parameters of the function enclosing lit
+// in file if it is a constructor of the type of lit, i.e. if it returns
+// a value of the type or a pointer to it, e.g. func NewServer(addr string)
+// *Server. Parameters shadowed at lit are left out.
+func constructorParams(pkg *types.Package, info *types.Info, file *ast.File, lit *ast.CompositeLit) []*types.Var {
+	t := info.TypeOf(lit)
+	if t == nil {
+		return nil
+	}
+	var sig *types.Signature
+	path, _ := astutil.PathEnclosingInterval(file, lit.Pos(), lit.End())
+	for _, n := range path {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			if obj := info.Defs[decl.Name]; obj != nil {
+				sig, _ = obj.Type().(*types.Signature)
+			}
+			break
+		}
+		if fn, ok := n.(*ast.FuncLit); ok {
+			sig, _ = info.TypeOf(fn).(*types.Signature)
+			break
+		}
+	}
+	if sig == nil || !returns(sig, t) {
+		return nil
+	}
+
+	visible := make(map[*types.Var]bool)
+	for _, v := range scopeVars(pkg, lit.Pos()) {
+		visible[v] = true
+	}
+	var params []*types.Var
+	for i := 0; i < sig.Params().Len(); i++ {
+		if p := sig.Params().At(i); visible[p] {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// returns reports whether one of the results of sig is of type t or a
+// pointer to t.
+func returns(sig *types.Signature, t types.Type) bool {
+	for i := 0; i < sig.Results().Len(); i++ {
+		r := sig.Results().At(i).Type()
+		if p, ok := r.(*types.Pointer); ok {
+			r = p.Elem()
+		}
+		if types.Identical(r, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// sharedLit is a nested literal which is expanded
+// once into a variable and referenced by its name.
+type sharedLit struct {
+	name string
+	lit  *ast.CompositeLit
+}
+
+// share replaces the nested struct literals in root which occur more
+// than once with the same elements by a variable, largest literals
+// first. Only the shareable literals are shared: the generated zero
+// values of named struct types without reference-typed fields, since
+// copies of other values would alias them, and expressions written by
+// the user are never merged. free reports whether a variable name can
+// be declared.
+func share(root ast.Expr, shareable map[*ast.CompositeLit]bool, free func(name string) bool) []sharedLit {
+	var shared []sharedLit
+	used := make(map[string]bool)
+	for {
+		groups := make(map[string][]*ast.Expr) // code -> slots of the literals
+		var walk func(slot *ast.Expr, nested bool)
+		walk = func(slot *ast.Expr, nested bool) {
+			switch e := (*slot).(type) {
+			case *ast.UnaryExpr:
+				walk(&e.X, nested)
+			case *ast.CompositeLit:
+				if nested && isShareable(e, shareable) {
+					code := exprString(token.NewFileSet(), e)
+					groups[code] = append(groups[code], slot)
+				}
+				for i, elt := range e.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						walk(&kv.Value, true)
+					} else {
+						walk(&e.Elts[i], true)
+					}
+				}
+			}
+		}
+		walk(&root, false)
+
+		var code string
+		for c, slots := range groups {
+			if len(slots) > 1 && (len(c) > len(code) || len(c) == len(code) && c < code) {
+				code = c
+			}
+		}
+		if code == "" {
+			return shared
+		}
+
+		slots := groups[code]
+		lit := (*slots[0]).(*ast.CompositeLit)
+		name := varName(lit.Type.(*ast.Ident).Name, func(name string) bool { return !used[name] && free(name) })
+		used[name] = true
+		for _, slot := range slots {
+			*slot = ast.NewIdent(name)
+		}
+		shared = append(shared, sharedLit{name: name, lit: lit})
+	}
+}
+
+// sharedNames reserves the names of the variables declared by shareLit
+// before a statement or declaration for the literal they were picked for,
+// such that the literals of the same statement, which are filled one
+// after another, see fillWorkers, get different names. The literal of a
+// name is identified by its offset, such that it gets the same names
+// again in the test variant of its package.
+type sharedNames map[declSite]map[string]int
+
+// declSite is the file and offset of the declarations of shareLit.
+type declSite struct {
+	path   string
+	offset int
+}
+
+// isShareable reports whether lit is a non-empty shareable value literal
+// of a named struct type. The type of a pointer literal is prefixed with
+// "&", see zero.
+func isShareable(lit *ast.CompositeLit, shareable map[*ast.CompositeLit]bool) bool {
+	id, ok := lit.Type.(*ast.Ident)
+	return ok && shareable[lit] && len(lit.Elts) > 0 && !strings.HasPrefix(id.Name, "&")
+}
+
+// hasReferences reports whether a value of type t refers to memory which
+// its copies share, i.e. whether it contains pointers, slices, maps,
+// channels, functions or interfaces. Type parameters may be any of them.
+func hasReferences(t types.Type) bool {
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		return t.Kind() == types.UnsafePointer
+	case *types.Array:
+		return hasReferences(t.Elem())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasReferences(t.Field(i).Type()) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// varName returns a free variable name for a literal of the given
+// type, e.g. "config" for "pkg.Config[T]", "config2" if it is taken.
+func varName(typeName string, free func(name string) bool) string {
+	if i := strings.IndexByte(typeName, '['); i >= 0 {
+		typeName = typeName[:i]
+	}
+	if i := strings.LastIndexByte(typeName, '.'); i >= 0 {
+		typeName = typeName[i+1:]
+	}
+	r, size := utf8.DecodeRuneInString(typeName)
+	base := string(unicode.ToLower(r)) + typeName[size:]
+
+	name := base
+	for i := 2; token.IsKeyword(name) || !free(name); i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+// shareLit replaces the repeated nested literals of newlit, the filled
+// literal lit, which are shareable, see share, by variables. It returns the edit declaring the variables
+// before the statement or declaration in file enclosing lit, or false if
+// nothing is shared. The declarations end with a newline; the indentation
+// of the statement is added by setIndents. The names of the variables are
+// reserved in names, if not nil.
+func shareLit(fset *token.FileSet, file *ast.File, pkg *types.Package, lit *ast.CompositeLit, newlit ast.Expr, shareable map[*ast.CompositeLit]bool, names sharedNames) (output, bool, error) {
+	path, _ := astutil.PathEnclosingInterval(file, lit.Pos(), lit.End())
+	var at ast.Node
+	local := false
+	for i := 0; i+1 < len(path) && at == nil; i++ {
+		switch path[i+1].(type) {
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			if _, ok := path[i].(ast.Stmt); ok {
+				at, local = path[i], true
+			}
+		case *ast.File:
+			at = path[i]
+		}
+	}
+	if at == nil {
+		return output{}, false, errors.New("cannot find the statement or declaration enclosing the literal")
+	}
+	start := fset.Position(at.Pos()).Offset
+	if decl, ok := at.(*ast.GenDecl); ok && decl.Doc != nil {
+		start = fset.Position(decl.Doc.Pos()).Offset
+	}
+
+	// The names must neither be declared in the scopes
+	// enclosing the literal nor later in the same block,
+	// nor for another literal of the statement.
+	scope := pkg.Scope().Innermost(lit.Pos())
+	if scope == nil {
+		scope = pkg.Scope()
+	}
+	site := declSite{path: fset.Position(at.Pos()).Filename, offset: start}
+	litOffset := fset.Position(lit.Pos()).Offset
+	free := func(name string) bool {
+		if offset, ok := names[site][name]; ok && offset != litOffset {
+			return false
+		}
+		_, obj := scope.LookupParent(name, token.NoPos)
+		return obj == nil
+	}
+	shared := share(newlit, shareable, free)
+	if len(shared) == 0 {
+		return output{}, false, nil
+	}
+	if names != nil {
+		if names[site] == nil {
+			names[site] = make(map[string]int)
+		}
+		for _, s := range shared {
+			names[site][s.name] = litOffset
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, s := range shared {
+		f := filler{pos: 1}
+		f.fixExprPos(s.lit)
+		code, err := printExpr(s.lit, f.lines)
+		if err != nil {
+			return output{}, false, err
+		}
+		if local {
+			fmt.Fprintf(&buf, "%s := %s\n", s.name, code)
+		} else {
+			fmt.Fprintf(&buf, "var %s = %s\n\n", s.name, code)
+		}
+	}
+	return output{Start: start, End: start, Code: buf.String()}, true, nil
+}
+
+// printExpr prints the expression e, whose positions
+// are lines numbered by a filler, see fixExprPos.
+func printExpr(e ast.Expr, lines int) (string, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, lines)
+	for i := 1; i <= lines; i++ {
+		file.AddLine(i)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, e); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// snippet returns code as an LSP/TextMate snippet in which
+// every filled value is a numbered tab stop, for example:
+//
+//	User{
+//		Name: ${1:""},
+//		ZIP:  ${2:0},
+//	}
+func snippet(code string) (string, error) {
+	fset, expr, shift, err := parseCode(code)
+	if err != nil {
+		return "", err
+	}
+
+	var leaves []ast.Expr
+	collectLeaves(expr, &leaves)
+
+	var b strings.Builder
+	prev := 0
+	for i, e := range leaves {
+		start := fset.Position(e.Pos()).Offset - shift
+		end := fset.Position(e.End()).Offset - shift
+		b.WriteString(snippetEscape(code[prev:start], false))
+		fmt.Fprintf(&b, "${%d:%s}", i+1, snippetEscape(code[start:end], true))
+		prev = end
+	}
+	b.WriteString(snippetEscape(code[prev:], false))
+	return b.String(), nil
+}
+
+// collectLeaves appends the values of the literal e, which
+// are not non-empty literals themselves, to leaves.
+func collectLeaves(e ast.Expr, leaves *[]ast.Expr) {
+	switch e := e.(type) {
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.CompositeLit); ok && len(lit.Elts) > 0 {
+			collectLeaves(e.X, leaves)
+			return
+		}
+	case *ast.CompositeLit:
+		if len(e.Elts) == 0 {
+			break
+		}
+		for _, elt := range e.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if !isFieldName(kv.Key) {
+					collectLeaves(kv.Key, leaves)
+				}
+				collectLeaves(kv.Value, leaves)
+			} else {
+				collectLeaves(elt, leaves)
+			}
+		}
+		return
+	}
+	*leaves = append(*leaves, e)
+}
+
+// isFieldName reports whether the key of a key-value
+// expression is the name of a field of a struct literal.
+func isFieldName(key ast.Expr) bool {
+	id, ok := key.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	switch id.Name {
+	case "nil", "true", "false":
+		return false
+	default:
+		return true
+	}
+}
+
+// snippetEscape escapes the snippet syntax in s.
+// Closing braces only need to be escaped in placeholders.
+func snippetEscape(s string, placeholder bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `$`, `\$`, -1)
+	if placeholder {
+		s = strings.Replace(s, `}`, `\}`, -1)
+	}
+	return s
+}
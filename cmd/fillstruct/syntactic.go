@@ -0,0 +1,85 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// syntacticPackage returns a copy of pkg with the types of the outermost
+// composite literal enclosing pos in the file f, if the type of the
+// innermost one is unknown, e.g. because of errors while editing. Only
+// the imports, constants and types of the files of pkg are type-checked,
+// such that errors elsewhere do not matter, and the literal is checked
+// as the value of a blank variable of f, i.e. without the variables of
+// its function. It returns nil if the literal has a type or if its type
+// cannot be resolved.
+func syntacticPackage(pkg *packages.Package, f *ast.File, pos token.Pos) *packages.Package {
+	var inner, outer *ast.CompositeLit
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	for _, n := range path {
+		if lit, ok := n.(*ast.CompositeLit); ok {
+			if inner == nil {
+				inner = lit
+			}
+			outer = lit
+		}
+	}
+	if inner == nil || outer.Type == nil || pkg.TypesInfo != nil && isValid(pkg.TypesInfo.Types[inner].Type) {
+		return nil
+	}
+
+	var files []*ast.File
+	for _, file := range pkg.Syntax {
+		decls := &ast.File{
+			Name:      file.Name,
+			Package:   file.Package,
+			Imports:   file.Imports,
+			FileStart: file.FileStart,
+			FileEnd:   file.FileEnd,
+		}
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok != token.VAR {
+				decls.Decls = append(decls.Decls, gen)
+			}
+		}
+		if file == f {
+			decls.Decls = append(decls.Decls, &ast.GenDecl{
+				Tok: token.VAR,
+				Specs: []ast.Spec{&ast.ValueSpec{
+					Names:  []*ast.Ident{ast.NewIdent("_")},
+					Values: []ast.Expr{outer},
+				}},
+			})
+		}
+		files = append(files, decls)
+	}
+
+	info := newTypesInfo()
+	conf := types.Config{
+		Importer:    importerOf(pkg),
+		FakeImportC: true,
+		Error:       func(error) {}, // e.g. undefined local variables
+		Sizes:       pkg.TypesSizes,
+	}
+	tpkg := types.NewPackage(pkg.PkgPath, f.Name.Name)
+	types.NewChecker(&conf, pkg.Fset, tpkg, info).Files(files)
+	if !isValid(info.Types[inner].Type) {
+		return nil
+	}
+	spkg := *pkg
+	spkg.Types, spkg.TypesInfo = tpkg, info
+	return &spkg
+}
+
+// isValid reports whether t is a known type.
+func isValid(t types.Type) bool {
+	return t != nil && t != types.Typ[types.Invalid]
+}
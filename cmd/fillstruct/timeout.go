@@ -0,0 +1,65 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// withTimeout returns a context which is canceled after timeout or, if
+// timeout is not positive, only by the returned function.
+func withTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// timeoutError is the failure to load the packages and fill the
+// literals within the duration of -timeout.
+type timeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %v, rerun with a larger -timeout", e.Timeout)
+}
+
+// timedOut returns a timeoutError if err is caused by the expiry of ctx,
+// which was created by withTimeout(timeout), or err otherwise.
+func timedOut(ctx context.Context, timeout time.Duration, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &timeoutError{Timeout: timeout}
+	}
+	return err
+}
+
+// writeTimeout writes err, if it is a timeoutError, as JSON to w.
+func writeTimeout(w io.Writer, err error) error {
+	var e *timeoutError
+	if !errors.As(err, &e) {
+		return nil
+	}
+	return json.NewEncoder(w).Encode(struct {
+		Error   string `json:"error"`
+		Timeout string `json:"timeout"`
+	}{e.Error(), e.Timeout.String()})
+}
+
+// fatal is log.Fatal, printing err as JSON first if it is a timeoutError,
+// such that editors can tell a timeout from a failure.
+func fatal(err error) {
+	if werr := writeTimeout(os.Stdout, err); werr != nil {
+		log.Print(werr)
+	}
+	log.Fatal(err)
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"strings"
+)
+
+const todoComment = "// TODO: set value"
+
+// todoComments appends a TODO comment to the line of every field of
+// the literal in code which was newly filled, see commentFields.
+func todoComments(code string, existing map[string]bool) (string, error) {
+	return commentFields(code, existing, todoComment)
+}
+
+// commentFields appends comment to the line of every field of the
+// literal in code which was newly filled. Fields whose values are
+// struct literals themselves are not commented; their fields are.
+// The fields whose paths are in existing are left untouched.
+func commentFields(code string, existing map[string]bool, comment string) (string, error) {
+	fset, expr, _, err := parseCode(code)
+	if err != nil {
+		return "", err
+	}
+
+	marked := make(map[int]bool) // lines to comment
+	var walk func(e ast.Expr, path string)
+	walk = func(e ast.Expr, path string) {
+		if u, ok := e.(*ast.UnaryExpr); ok {
+			e = u.X
+		}
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok {
+			return
+		}
+		for i, elt := range lit.Elts {
+			p, v := elementPath(path, i, elt)
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok || !isFieldName(kv.Key) || hasFields(v) {
+				walk(v, p)
+			} else if !existing[p] {
+				marked[fset.Position(kv.Pos()).Line] = true
+			}
+		}
+	}
+	walk(expr, "")
+
+	lines := strings.Split(code, "\n")
+	for i := range lines {
+		if marked[i+1] {
+			lines[i] += " " + comment
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// alignComments aligns the trailing comments of the literal in code
+// in columns like gofmt does, such that the code is stable under gofmt
+// after comments have been appended to its lines.
+func alignComments(code string) (string, error) {
+	prefix := "package p\n\nvar _ = "
+	if strings.HasPrefix(code, "{") {
+		// Literals with an elided type need a placeholder type, see parseCode.
+		prefix += "_"
+	}
+	src, err := format.Source([]byte(prefix + code + "\n"))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(string(src), prefix) {
+		return "", errors.New("gofmt changed the code around the literal")
+	}
+	return strings.TrimSuffix(string(src[len(prefix):]), "\n"), nil
+}
+
+// hasFields reports whether e contains a struct literal with fields.
+func hasFields(e ast.Expr) bool {
+	found := false
+	ast.Inspect(e, func(n ast.Node) bool {
+		if kv, ok := n.(*ast.KeyValueExpr); ok && isFieldName(kv.Key) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// existingPaths returns the paths of all elements
+// of the literal lit, see elementPath.
+func existingPaths(lit *ast.CompositeLit) map[string]bool {
+	paths := make(map[string]bool)
+	var walk func(e ast.Expr, path string)
+	walk = func(e ast.Expr, path string) {
+		if u, ok := e.(*ast.UnaryExpr); ok {
+			e = u.X
+		}
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok {
+			return
+		}
+		for i, elt := range lit.Elts {
+			p, v := elementPath(path, i, elt)
+			paths[p] = true
+			walk(v, p)
+		}
+	}
+	walk(lit, "")
+	return paths
+}
+
+// elementPath returns the path and the value of the i-th element elt
+// of the literal at path, e.g. "addr.city", "addrs[0]" or `tags["a"]`.
+func elementPath(path string, i int, elt ast.Expr) (string, ast.Expr) {
+	kv, ok := elt.(*ast.KeyValueExpr)
+	switch {
+	case !ok:
+		return fmt.Sprintf("%s[%d]", path, i), elt
+	case isFieldName(kv.Key) && path == "":
+		return kv.Key.(*ast.Ident).Name, kv.Value
+	case isFieldName(kv.Key):
+		return path + "." + kv.Key.(*ast.Ident).Name, kv.Value
+	default:
+		return fmt.Sprintf("%s[%s]", path, types.ExprString(kv.Key)), kv.Value
+	}
+}
@@ -40,6 +40,7 @@ import (
 	"bytes"
 	"fmt"
 	"go/types"
+	"strconv"
 )
 
 type typeWriter struct {
@@ -106,7 +107,8 @@ func (w *typeWriter) writeType(typ types.Type, visited []types.Type) {
 			}
 			w.writeType(f.Type(), visited)
 			if tag := t.Tag(i); tag != "" {
-				fmt.Fprintf(w.buf, " %q", tag)
+				w.buf.WriteByte(' ')
+				w.buf.WriteString(quoteTag(tag))
 			}
 		}
 		w.buf.WriteByte('}')
@@ -185,20 +187,11 @@ func (w *typeWriter) writeType(typ types.Type, visited []types.Type) {
 		}
 
 	case *types.Named:
-		if isImported(w.pkg, t) && t.Obj().Pkg() != nil {
-			pkg := t.Obj().Pkg()
-			if name, ok := w.importNames[pkg.Path()]; ok {
-				if name == "." {
-					w.buf.WriteString(t.Obj().Name())
-				} else {
-					w.buf.WriteString(fmt.Sprintf("%s.%s", name, t.Obj().Name()))
-				}
-			} else {
-				w.buf.WriteString(fmt.Sprintf("%s.%s", pkg.Name(), t.Obj().Name()))
-			}
-		} else {
-			w.buf.WriteString(t.Obj().Name())
-		}
+		w.writeTypeName(t.Obj())
+
+	case *types.Alias:
+		// The alias as written, e.g. in the declaration of a field.
+		w.writeTypeName(t.Obj())
 
 	default:
 		// For externally defined implementations of Type.
@@ -206,6 +199,33 @@ func (w *typeWriter) writeType(typ types.Type, visited []types.Type) {
 	}
 }
 
+// writeTypeName writes the name of the named type or alias obj,
+// qualified with the name of its package if it is imported.
+func (w *typeWriter) writeTypeName(obj *types.TypeName) {
+	imported := obj.Pkg() != nil && obj.Pkg() != w.pkg
+	if isLocal(obj) {
+		// Local types cannot be qualified and are
+		// only accessible within their own package.
+		if imported {
+			w.hasError = true
+		}
+		w.buf.WriteString(obj.Name())
+	} else if imported {
+		pkg := obj.Pkg()
+		if name, ok := w.importNames[pkg.Path()]; ok {
+			if name == "." {
+				w.buf.WriteString(obj.Name())
+			} else {
+				w.buf.WriteString(fmt.Sprintf("%s.%s", name, obj.Name()))
+			}
+		} else {
+			w.buf.WriteString(fmt.Sprintf("%s.%s", pkg.Name(), obj.Name()))
+		}
+	} else {
+		w.buf.WriteString(obj.Name())
+	}
+}
+
 func (w *typeWriter) writeTuple(tup *types.Tuple, variadic bool, visited []types.Type) {
 	w.buf.WriteByte('(')
 	if tup != nil {
@@ -258,3 +278,17 @@ func (w *typeWriter) writeSignature(sig *types.Signature, visited []types.Type)
 	// multiple or named result(s)
 	w.writeTuple(sig.Results(), false, visited)
 }
+
+// quoteTag returns the struct tag as a raw string literal, like it is
+// usually written, or as an interpreted one if it contains a backquote.
+func quoteTag(tag string) string {
+	if strconv.CanBackquote(tag) {
+		return "`" + tag + "`"
+	}
+	return strconv.Quote(tag)
+}
+
+// isLocal reports whether obj is declared inside a function body.
+func isLocal(obj types.Object) bool {
+	return obj.Pkg() != nil && obj.Parent() != nil && obj.Parent() != obj.Pkg().Scope()
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// editID returns the ID of the edit replacing orig with code.
+// The ID only depends on the tokens of the edit, such that it
+// remains valid across invocations and editor restarts, and
+// after the code has been indented or reformatted.
+func editID(orig []byte, code string) string {
+	h := sha256.New()
+	o, c := tokens(orig), tokens([]byte(code))
+	fmt.Fprintf(h, "%d:%s", len(o), o)
+	h.Write(c)
+	return hex.EncodeToString(h.Sum(nil)[:12])
+}
+
+// tokens returns the tokens of src, one per line, without the
+// whitespace between them and the automatically inserted semicolons.
+func tokens(src []byte) []byte {
+	var (
+		s   scanner.Scanner
+		buf bytes.Buffer
+	)
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, scanner.ScanComments)
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return buf.Bytes()
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		if lit == "" {
+			lit = tok.String()
+		}
+		fmt.Fprintf(&buf, "%s\n", lit)
+	}
+}
+
+// setIDs assigns the ID of each edit in outs to the edits of src.
+func setIDs(src []byte, outs []output) error {
+	for i, out := range outs {
+		if out.Start < 0 || out.Start > out.End || out.End > len(src) {
+			return fmt.Errorf("invalid edit [%d, %d)", out.Start, out.End)
+		}
+		outs[i].ID = editID(src[out.Start:out.End], out.Code)
+	}
+	return nil
+}
+
+// undoEdit returns the edit which reverts the previously applied edit
+// with the given ID, which replaced orig with the code starting at offset
+// in src: a filled literal, a filled call or the declarations of -share.
+// It fails if the code has been changed since. The ID of the returned
+// edit reverts the undo, i.e. redoes the original edit.
+func undoEdit(src []byte, offset int, id string, orig []byte) (output, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return output{}, err
+	}
+	file := fset.File(f.Pos())
+	if offset > file.Size() {
+		return output{}, fmt.Errorf("file size (%d) is smaller than given offset (%d)", file.Size(), offset)
+	}
+
+	filled := false
+	for _, r := range editRanges(fset, f, file.Pos(offset)) {
+		if _, ok := r.node.(*ast.CompositeLit); ok {
+			filled = true
+		}
+		cur := src[r.start:r.end]
+		if editID(orig, string(cur)) != id {
+			continue
+		}
+		return output{
+			Start: r.start,
+			End:   r.end,
+			Code:  string(orig),
+			ID:    editID(cur, string(orig)),
+		}, nil
+	}
+	if filled {
+		return output{}, fmt.Errorf("literal at offset %d does not match edit %s", offset, id)
+	}
+	return output{}, errNotFound
+}
+
+// editRange is a region of the source which may have been written by an
+// edit: the code of node, or, if node is nil, an insertion.
+type editRange struct {
+	node       ast.Node
+	start, end int
+}
+
+// editRanges returns the regions of the source at pos which may have been
+// written by an edit, in order: an insertion at pos, the literals and calls
+// enclosing pos, innermost first, and the statements or declarations
+// starting at pos, followed by the whitespace up to the next one, which
+// -share inserts before a statement or declaration.
+func editRanges(fset *token.FileSet, f *ast.File, pos token.Pos) []editRange {
+	offset := fset.Position(pos).Offset
+	ranges := []editRange{{start: offset, end: offset}}
+	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
+	for _, n := range path {
+		switch n.(type) {
+		case *ast.CompositeLit, *ast.CallExpr:
+			ranges = append(ranges, editRange{
+				node:  n,
+				start: fset.Position(n.Pos()).Offset,
+				end:   fset.Position(n.End()).Offset,
+			})
+		}
+	}
+
+	start := func(n ast.Node) int {
+		if decl, ok := n.(*ast.GenDecl); ok && decl.Doc != nil {
+			return fset.Position(decl.Doc.Pos()).Offset
+		}
+		return fset.Position(n.Pos()).Offset
+	}
+	for _, n := range path {
+		var list []ast.Node
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = stmtNodes(n.List)
+		case *ast.CaseClause:
+			list = stmtNodes(n.Body)
+		case *ast.CommClause:
+			list = stmtNodes(n.Body)
+		case *ast.File:
+			for _, d := range n.Decls {
+				list = append(list, d)
+			}
+		}
+		for i, n := range list {
+			if start(n) != offset {
+				continue
+			}
+			for j := i; j < len(list); j++ {
+				end := fset.Position(list[j].End()).Offset
+				if j+1 < len(list) {
+					end = start(list[j+1])
+				}
+				ranges = append(ranges, editRange{start: offset, end: end})
+			}
+		}
+	}
+	return ranges
+}
+
+// stmtNodes returns the statements of list as nodes.
+func stmtNodes(list []ast.Stmt) []ast.Node {
+	nodes := make([]ast.Node, len(list))
+	for i, s := range list {
+		nodes[i] = s
+	}
+	return nodes
+}
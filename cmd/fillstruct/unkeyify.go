@@ -0,0 +1,72 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+)
+
+// unkeyify rewrites the keyed struct literal lit to a positional literal,
+// in place. For example, User{Name: "frank", ID: 1, Addr: nil} becomes
+// User{1, "frank", nil}. It returns an error if a field of lit is missing
+// or unexported. Nested keyed struct literals are rewritten as well, if
+// possible; the others are left keyed.
+func unkeyify(info *types.Info, lit *ast.CompositeLit) error {
+	if err := toPositional(info, lit); err != nil {
+		return err
+	}
+	ast.Inspect(lit, func(n ast.Node) bool {
+		if nested, ok := n.(*ast.CompositeLit); ok && nested != lit {
+			_ = toPositional(info, nested)
+		}
+		return true
+	})
+	return nil
+}
+
+// toPositional rewrites the keyed struct literal lit to a positional
+// literal, if all of its fields are present and exported.
+func toPositional(info *types.Info, lit *ast.CompositeLit) error {
+	tv, ok := info.Types[lit]
+	if !ok {
+		return errors.New("cannot unkeyify a literal of unknown type")
+	}
+	s, ok := tv.Type.Underlying().(*types.Struct)
+	if !ok {
+		return errors.New("cannot unkeyify a literal which is not a struct literal")
+	}
+
+	values := make([]ast.Expr, s.NumFields())
+	for _, e := range lit.Elts {
+		kv, ok := e.(*ast.KeyValueExpr)
+		if !ok {
+			return errors.New("cannot unkeyify a literal with positional fields")
+		}
+		id, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return fmt.Errorf("invalid field name %s", types.ExprString(kv.Key))
+		}
+		for i := 0; i < s.NumFields(); i++ {
+			if s.Field(i).Name() == id.Name {
+				values[i] = kv.Value
+			}
+		}
+	}
+
+	for i := 0; i < s.NumFields(); i++ {
+		field := s.Field(i)
+		if !field.Exported() {
+			return fmt.Errorf("cannot unkeyify a literal with the unexported field %s", field.Name())
+		}
+		if values[i] == nil {
+			return fmt.Errorf("cannot unkeyify a literal without the field %s", field.Name())
+		}
+	}
+	lit.Elts = values
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// verifyFill applies the edits in outs to src, the contents of the file
+// at path, loads the packages again with the result and checks that
+// filling the literals once more does not change them, see checkRefill.
+func verifyFill(cfg *packages.Config, patterns []string, path string, src []byte, outs []output, opts options) error {
+	res, err := applyEdits(src, outs)
+	if err != nil {
+		return err
+	}
+
+	vcfg := *cfg
+	vcfg.Fset = token.NewFileSet()
+	vcfg.Overlay = map[string][]byte{path: res}
+	for name, content := range cfg.Overlay {
+		if name != path {
+			vcfg.Overlay[name] = content
+		}
+	}
+	pkgs, err := packages.Load(&vcfg, patterns...)
+	if err != nil {
+		return err
+	}
+	opts = withSyntax(vcfg.Fset, pkgs, opts)
+	checkCgo(&vcfg, pkgs)
+	return checkRefill(pkgs, path, outs, opts)
+}
+
+// checkRefill fills the literals of the edits in outs again, in the
+// packages loaded with the edits applied, and reports an error if the
+// code of a literal changes. Insertions and edits of calls are skipped.
+func checkRefill(pkgs []*packages.Package, path string, outs []output, opts options) error {
+	sorted := make([]output, len(outs))
+	copy(sorted, outs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	shift := 0 // difference of the lengths of the edits before the current one
+	for _, out := range sorted {
+		start := out.Start + shift
+		shift += len(out.Code) - (out.End - out.Start)
+		if out.Start == out.End || !isLitCode(out.Code) {
+			continue
+		}
+
+		refilled, err := byOffset(pkgs, path, start, opts)
+		if err != nil {
+			return fmt.Errorf("cannot fill the literal at offset %d again: %v", start, err)
+		}
+		for _, r := range refilled {
+			if r.Start == start && r.Code != out.Code {
+				return fmt.Errorf("filling the literal at offset %d again changes it to:\n%s", start, r.Code)
+			}
+		}
+	}
+	return nil
+}
+
+// isLitCode reports whether code is a composite literal
+// or the address of one, as opposed to a call.
+func isLitCode(code string) bool {
+	_, expr, _, err := parseCode(code)
+	if err != nil {
+		return false
+	}
+	if u, ok := expr.(*ast.UnaryExpr); ok {
+		expr = u.X
+	}
+	_, ok := expr.(*ast.CompositeLit)
+	return ok
+}
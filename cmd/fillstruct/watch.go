@@ -0,0 +1,207 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"go/token"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// watchInterval is the interval in which -watch looks for changes.
+const watchInterval = 250 * time.Millisecond
+
+// watcher fills the literals at the offsets and lines of a file again
+// each time a Go file of its target, a file or a directory, changes.
+type watcher struct {
+	cfg      *packages.Config
+	patterns []string
+	target   string // watched file or directory
+	path     string // file whose literals are filled
+	offsets  intList // in the units of opts.encoding, see byteOffset
+	lines    intList
+	opts     options
+
+	indent, allowGenerated bool
+
+	timeout time.Duration // of each reload and fill, see withTimeout
+	stop    func()        // removes the go.mod tidied by -fix and stops the profiles, see run
+}
+
+// stamp identifies the contents of a file without reading it.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stamps returns the stamps of the Go files of the watched file or
+// directory target, indexed by their paths.
+func stamps(target string) map[string]stamp {
+	res := make(map[string]stamp)
+	fi, err := os.Stat(target)
+	if err != nil {
+		return res
+	}
+	if !fi.IsDir() {
+		res[target] = stamp{fi.ModTime(), fi.Size()}
+		return res
+	}
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return res
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			res[filepath.Join(target, e.Name())] = stamp{fi.ModTime(), fi.Size()}
+		}
+	}
+	return res
+}
+
+// run emits the edits for the loaded packages pkgs and then, each time
+// the target changes, for the packages updated by reload. If they cannot
+// be loaded, e.g. while go.mod is edited, the error is logged and the
+// previous packages are kept until the next change. Each reload and
+// fill gets the duration of -timeout anew. run never returns: once
+// fillstruct is interrupted or terminated, it calls w.stop and exits.
+func (w *watcher) run(pkgs []*packages.Package) {
+	sig := make(chan os.Signal, 1)
+	// The handler of startProfiles is replaced,
+	// as w.stop stops the profiles as well.
+	signal.Reset(os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		w.stop()
+		os.Exit(1)
+	}()
+
+	last := stamps(w.target)
+	ctx, cancel := withTimeout(w.timeout)
+	w.fill(ctx, pkgs)
+	cancel()
+	for {
+		time.Sleep(watchInterval)
+		cur := stamps(w.target)
+		if reflect.DeepEqual(cur, last) {
+			continue
+		}
+		// Renamed files are detected by checkFiles.
+		added := len(cur) != len(last)
+		last = cur
+		ctx, cancel := withTimeout(w.timeout)
+		w.cfg.Context = ctx
+		reloaded, err := w.reload(pkgs, added)
+		if err != nil {
+			log.Print(timedOut(ctx, w.timeout, err))
+			cancel()
+			continue
+		}
+		pkgs = reloaded
+		w.fill(ctx, pkgs)
+		cancel()
+	}
+}
+
+// reload returns pkgs with the packages containing the filled file
+// type-checked again, reusing the loaded imports, see checkFiles. The
+// packages are loaded again if files were added or removed, or if an
+// import was added, i.e. if added is set or checkFiles fails. A go.mod
+// tidied by -fix is still used then, and the packages get a new file set,
+// which replaces the one of w.cfg if they can be loaded.
+func (w *watcher) reload(pkgs []*packages.Package, added bool) ([]*packages.Package, error) {
+	if !added {
+		reused := true
+		for _, pkg := range pkgs {
+			if contains(pkg.GoFiles, w.path) && !checkFiles(w.cfg, pkg) {
+				reused = false
+				break
+			}
+		}
+		if reused {
+			return pkgs, nil
+		}
+	}
+	cfg := *w.cfg
+	cfg.Fset = token.NewFileSet()
+	pkgs, _, err := loadPackages(&cfg, w.patterns, false)
+	if err != nil {
+		return nil, err
+	}
+	w.cfg = &cfg
+	return pkgs, nil
+}
+
+// fill prints the edits of the literals in the file. Errors are logged.
+func (w *watcher) fill(ctx context.Context, pkgs []*packages.Package) {
+	// The declarations may have changed.
+	opts := withSyntax(w.cfg.Fset, pkgs, w.opts)
+
+	// The offsets refer to the file as it is now.
+	offsets := w.offsets
+	if src, err := readSource(w.path, nil); err == nil && needsEncoding(src, w.opts.encoding) {
+		offsets = make(intList, len(w.offsets))
+		for i := range offsets {
+			if offsets[i], err = byteOffset(src, w.offsets[i], w.opts.encoding); err != nil {
+				log.Print(err)
+				return
+			}
+		}
+	}
+
+	louts, errs := fillEach(ctx, pkgs, w.path, offsets, w.lines, opts)
+	if err := ctx.Err(); err != nil {
+		log.Print(timedOut(ctx, w.timeout, err))
+		return
+	}
+	var outs []output
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("offset %d, line %d: %v", w.offsets.at(i), w.lines.at(i), err)
+			continue
+		}
+		outs = append(outs, louts[i]...)
+	}
+	if outs = outermostEdits(outs); outs == nil {
+		return
+	}
+
+	src, err := readSource(w.path, nil)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	setIndents(src, outs, w.indent)
+	setLineEndings(src, outs)
+	if err := setIDs(src, outs); err != nil {
+		log.Print(err)
+		return
+	}
+	setRegions(src, outs)
+	if err := emit(w.path, nil, outs, w.allowGenerated, false, false, w.opts.encoding); err != nil {
+		log.Print(err)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
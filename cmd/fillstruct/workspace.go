@@ -0,0 +1,97 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// goEnvs caches the environments of the go command by directory.
+var goEnvs struct {
+	sync.Mutex
+	m map[string]map[string]string
+}
+
+// goEnv returns the value of the go environment variable key in dir,
+// or the empty string if the go command fails. The environment is read
+// by a single go env -json per directory, which is shared by all keys.
+func goEnv(dir, key string) string {
+	goEnvs.Lock()
+	defer goEnvs.Unlock()
+	env, ok := goEnvs.m[dir]
+	if !ok {
+		cmd := exec.Command("go", "env", "-json")
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err == nil {
+			err = json.Unmarshal(out, &env)
+		}
+		if err != nil {
+			env = nil
+		}
+		if goEnvs.m == nil {
+			goEnvs.m = make(map[string]map[string]string)
+		}
+		goEnvs.m[dir] = env
+	}
+	return env[key]
+}
+
+// workFile returns the path of the go.work file in effect in dir, as
+// reported by go env GOWORK, or the empty string if there is none.
+func workFile(dir string) string {
+	work := goEnv(dir, "GOWORK")
+	if work == "off" {
+		return ""
+	}
+	return work
+}
+
+// isAdHoc reports whether dir is neither in a module nor in GOPATH mode,
+// such that its package cannot be loaded and a file in dir is loaded as
+// an ad-hoc package instead, like go run file.go does.
+func isAdHoc(dir string) bool {
+	return goEnv(dir, "GOMOD") == os.DevNull
+}
+
+// loadRoot returns the directory from which the packages in dir are
+// loaded: the directory of the go.work file in effect, such that the
+// packages of all modules of the workspace are found, or else the
+// directory of the go.mod file, or dir itself.
+func loadRoot(dir string) string {
+	if work := workFile(dir); work != "" {
+		return filepath.Dir(work)
+	}
+	if mod := moduleDir(dir); mod != "" {
+		return mod
+	}
+	return dir
+}
+
+// workspaceEnv returns env for loading packages in a workspace: the
+// go command only accepts -mod=readonly and -mod=vendor in workspace
+// mode, so other -mod flags of GOFLAGS, e.g. -mod=mod, are removed.
+func workspaceEnv(env []string) []string {
+	res := make([]string, 0, len(env))
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GOFLAGS=") {
+			var keep []string
+			for _, flag := range strings.Fields(strings.TrimPrefix(kv, "GOFLAGS=")) {
+				if strings.HasPrefix(flag, "-mod=") && flag != "-mod=readonly" && flag != "-mod=vendor" {
+					continue
+				}
+				keep = append(keep, flag)
+			}
+			kv = "GOFLAGS=" + strings.Join(keep, " ")
+		}
+		res = append(res, kv)
+	}
+	return res
+}
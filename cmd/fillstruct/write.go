@@ -0,0 +1,75 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"golang.org/x/tools/imports"
+)
+
+// writeFile applies the edits in outs to the file at path and writes
+// the result back to disk. The contents of the file are taken from the
+// overlay if present. If fixImports is set, then the imports of the
+// resulting file are fixed like goimports does.
+func writeFile(path string, overlay map[string][]byte, outs []output, fixImports bool) error {
+	src, err := readSource(path, overlay)
+	if err != nil {
+		return err
+	}
+
+	res, err := applyEdits(src, outs)
+	if err != nil {
+		return err
+	}
+	if fixImports {
+		if res, err = imports.Process(path, res, nil); err != nil {
+			return err
+		}
+		res = restoreFile(src, res)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, res, fi.Mode().Perm())
+}
+
+// readSource returns the contents of the file at path,
+// which are taken from the overlay if present.
+func readSource(path string, overlay map[string][]byte) ([]byte, error) {
+	if src, ok := overlay[path]; ok {
+		return src, nil
+	}
+	return os.ReadFile(path)
+}
+
+// applyEdits returns src with the code of each output
+// replacing the range [Start, End) of the original src.
+func applyEdits(src []byte, outs []output) ([]byte, error) {
+	sorted := make([]output, len(outs))
+	copy(sorted, outs)
+	sort.Slice(sorted, func(i, j int) bool {
+		// Insertions go before an edit starting at the same offset.
+		if sorted[i].Start == sorted[j].Start {
+			return sorted[i].End > sorted[j].End
+		}
+		return sorted[i].Start > sorted[j].Start
+	})
+
+	res := append([]byte(nil), src...)
+	end := len(src)
+	for _, out := range sorted {
+		if out.Start < 0 || out.Start > out.End || out.End > end {
+			return nil, fmt.Errorf("invalid or overlapping edit [%d, %d)", out.Start, out.End)
+		}
+		res = append(res[:out.Start], append([]byte(out.Code), res[out.End:]...)...)
+		end = out.Start
+	}
+	return res, nil
+}
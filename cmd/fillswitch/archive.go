@@ -0,0 +1,66 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// writeArchive writes the files of the overlay, with the edits in outs
+// applied to the file at path, to w. The archive has the format read by
+// -modified, see buildutil.ParseOverlayArchive, such that the result can
+// be passed on to the next tool, e.g. fillstruct -modified.
+func writeArchive(w io.Writer, path string, overlay map[string][]byte, outs []output) error {
+	src, err := readSource(path, overlay)
+	if err != nil {
+		return err
+	}
+	res, err := applyEdits(src, outs)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string][]byte, len(overlay)+1)
+	for name, content := range overlay {
+		files[name] = content
+	}
+	files[path] = res
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%s\n%d\n", name, len(files[name])); err != nil {
+			return err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEdits returns src with the code of each output
+// replacing the range [Start, End) of the original src.
+func applyEdits(src []byte, outs []output) ([]byte, error) {
+	sorted := make([]output, len(outs))
+	copy(sorted, outs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start > sorted[j].Start })
+
+	res := append([]byte(nil), src...)
+	end := len(src)
+	for _, out := range sorted {
+		if out.Start < 0 || out.Start > out.End || out.End > end {
+			return nil, fmt.Errorf("invalid or overlapping edit [%d, %d)", out.Start, out.End)
+		}
+		res = append(res[:out.Start], append([]byte(out.Code), res[out.End:]...)...)
+		end = out.Start
+	}
+	return res, nil
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/davidrjenni/reftools/fillswitch"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Default clauses, selected with the -default flag.
+const (
+	defaultNone  = "none"  // no default clause
+	defaultEmpty = "empty" // an empty default clause
+	defaultPanic = "panic" // panic(fmt.Sprintf("unexpected type %T", v))
+	defaultError = "error" // return fmt.Errorf("unexpected type %T", v)
+)
+
+var errNoErrorResult = errors.New("the function of the switch does not return an error")
+
+func validDefault(dflt string) bool {
+	switch dflt {
+	case defaultNone, defaultEmpty, defaultPanic, defaultError:
+		return true
+	}
+	return false
+}
+
+// defaultClause returns the default clause to add to swtch, or nil if
+// none is to be added, e.g. because swtch has one already. The packages
+// in the clause, e.g. fmt, are qualified by qf.
+func defaultClause(pkg *packages.Package, swtch ast.Stmt, qf types.Qualifier, dflt string) (*ast.CaseClause, error) {
+	var format, subject string
+	switch swtch := swtch.(type) {
+	case *ast.SwitchStmt:
+		format, subject = "unexpected value %v", types.ExprString(swtch.Tag)
+	case *ast.TypeSwitchStmt:
+		format, subject = "unexpected type %T", types.ExprString(assertedExpr(swtch))
+		// In the default clause, the variable declared
+		// in the switch has the type of the switched value.
+		if assign, ok := swtch.Assign.(*ast.AssignStmt); ok && len(assign.Lhs) == 1 {
+			subject = types.ExprString(assign.Lhs[0])
+		}
+	}
+	return newDefaultClause(pkg, swtch, qf, format, subject, dflt)
+}
+
+// newDefaultClause returns the default clause to add to swtch, reporting
+// subject with format, or nil if none is to be added.
+func newDefaultClause(pkg *packages.Package, swtch ast.Stmt, qf types.Qualifier, format, subject, dflt string) (*ast.CaseClause, error) {
+	for _, cc := range switchBody(swtch).List {
+		if cc.(*ast.CaseClause).List == nil {
+			return nil, nil
+		}
+	}
+
+	call := func(fun string) *ast.CallExpr {
+		return &ast.CallExpr{
+			Fun:  ast.NewIdent(stdFunc(qf, "fmt", fun)),
+			Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(format)}, ast.NewIdent(subject)},
+		}
+	}
+	switch dflt {
+	case defaultEmpty:
+		return &ast.CaseClause{}, nil
+	case defaultPanic:
+		panicCall := &ast.CallExpr{Fun: ast.NewIdent("panic"), Args: []ast.Expr{call("Sprintf")}}
+		return &ast.CaseClause{Body: []ast.Stmt{&ast.ExprStmt{X: panicCall}}}, nil
+	case defaultError:
+		sig := enclosingSignature(pkg, swtch)
+		if sig == nil || sig.Results().Len() == 0 {
+			return nil, errNoErrorResult
+		}
+		results := sig.Results()
+		if !types.Identical(results.At(results.Len()-1).Type(), types.Universe.Lookup("error").Type()) {
+			return nil, errNoErrorResult
+		}
+		ret := &ast.ReturnStmt{}
+		for i := 0; i < results.Len()-1; i++ {
+			ret.Results = append(ret.Results, ast.NewIdent(zeroValue(qf, results.At(i).Type())))
+		}
+		ret.Results = append(ret.Results, call("Errorf"))
+		return &ast.CaseClause{Body: []ast.Stmt{ret}}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// stdFunc returns the function name of the standard library package
+// path, e.g. "fmt.Errorf", with the package qualified by qf.
+func stdFunc(qf types.Qualifier, path, name string) string {
+	if q := qf(types.NewPackage(path, path)); q != "" {
+		return q + "." + name
+	}
+	return name
+}
+
+// enclosingSignature returns the signature of the innermost function
+// declaration or literal containing swtch, or nil if there is none.
+func enclosingSignature(pkg *packages.Package, swtch ast.Stmt) *types.Signature {
+	f := enclosingFile(pkg, swtch)
+	if f == nil {
+		return nil
+	}
+	path, _ := astutil.PathEnclosingInterval(f, swtch.Pos(), swtch.End())
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			sig, _ := pkg.TypesInfo.TypeOf(n).(*types.Signature)
+			return sig
+		case *ast.FuncDecl:
+			if obj := pkg.TypesInfo.Defs[n.Name]; obj != nil {
+				sig, _ := obj.Type().(*types.Signature)
+				return sig
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// enclosingFile returns the file of pkg containing n, or nil if there is
+// none.
+func enclosingFile(pkg *packages.Package, n ast.Node) *ast.File {
+	for _, f := range pkg.Syntax {
+		if f.Pos() <= n.Pos() && n.End() <= f.End() {
+			return f
+		}
+	}
+	return nil
+}
+
+// zeroValue returns the zero value of t as an expression with the
+// packages qualified by qf.
+func zeroValue(qf types.Qualifier, t types.Type) string {
+	if _, ok := t.(*types.TypeParam); ok {
+		return "*new(" + fillswitch.QualifiedTypeString(t, qf) + ")"
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return fillswitch.QualifiedTypeString(t, qf) + "{}"
+	}
+	return "nil"
+}
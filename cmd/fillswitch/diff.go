@@ -0,0 +1,163 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// diffContext is the number of unchanged lines around a change in a hunk.
+const diffContext = 3
+
+// lineChange replaces the lines del of a file, starting at the
+// zero-based line old, with the lines ins.
+type lineChange struct {
+	old int
+	del []string
+	ins []string
+}
+
+// writeDiff writes the changes of the edits in outs to src, the
+// contents of the file at path, to w as a unified diff like gofmt -d.
+// Nothing is written if the edits do not change src.
+func writeDiff(w io.Writer, path string, src []byte, outs []output) error {
+	changes, err := lineChanges(src, outs)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "diff -u %s.orig %s\n--- %s.orig\n+++ %s\n", path, path, path, path)
+	lines := splitLines(src)
+	delta := 0 // number of lines added before the current hunk
+	for len(changes) > 0 {
+		// A hunk contains the changes whose contexts overlap.
+		n := 1
+		for n < len(changes) && changes[n].old-changes[n-1].end() <= 2*diffContext {
+			n++
+		}
+		hunk := changes[:n]
+		changes = changes[n:]
+
+		start := max(hunk[0].old-diffContext, 0)
+		stop := min(hunk[n-1].end()+diffContext, len(lines))
+		added := 0
+		for _, c := range hunk {
+			added += len(c.ins) - len(c.del)
+		}
+		fmt.Fprintf(bw, "@@ -%s +%s @@\n", hunkRange(start, stop-start), hunkRange(start+delta, stop-start+added))
+
+		i := start
+		for _, c := range hunk {
+			writeLines(bw, " ", lines[i:c.old])
+			writeLines(bw, "-", c.del)
+			writeLines(bw, "+", c.ins)
+			i = c.end()
+		}
+		writeLines(bw, " ", lines[i:stop])
+		delta += added
+	}
+	return bw.Flush()
+}
+
+// lineChanges returns the changes of the lines of src made by the edits
+// in outs. The edits on the same lines form one change, from
+// which the unchanged lines at the beginning and the end are removed.
+func lineChanges(src []byte, outs []output) ([]lineChange, error) {
+	sorted := make([]output, len(outs))
+	for i, out := range outs {
+		if out.Start < 0 || out.Start > out.End || out.End > len(src) {
+			return nil, fmt.Errorf("invalid edit [%d, %d)", out.Start, out.End)
+		}
+		sorted[i] = out
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var changes []lineChange
+	for len(sorted) > 0 {
+		// Extend the edits to whole lines and merge
+		// the ones which touch the same lines.
+		from := bytes.LastIndexByte(src[:sorted[0].Start], '\n') + 1
+		n, to := 0, 0
+		for n < len(sorted) && (n == 0 || sorted[n].Start < to) {
+			to = len(src)
+			if i := bytes.IndexByte(src[sorted[n].End:], '\n'); i >= 0 {
+				to = sorted[n].End + i + 1
+			}
+			n++
+		}
+
+		group := make([]output, n)
+		for i, out := range sorted[:n] {
+			group[i] = output{Start: out.Start - from, End: out.End - from, Code: out.Code}
+		}
+		sorted = sorted[n:]
+		res, err := applyEdits(src[from:to], group)
+		if err != nil {
+			return nil, err
+		}
+
+		del, ins := splitLines(src[from:to]), splitLines(res)
+		old := bytes.Count(src[:from], []byte("\n"))
+		for len(del) > 0 && len(ins) > 0 && del[0] == ins[0] {
+			del, ins = del[1:], ins[1:]
+			old++
+		}
+		for len(del) > 0 && len(ins) > 0 && del[len(del)-1] == ins[len(ins)-1] {
+			del, ins = del[:len(del)-1], ins[:len(ins)-1]
+		}
+		if len(del) > 0 || len(ins) > 0 {
+			changes = append(changes, lineChange{old: old, del: del, ins: ins})
+		}
+	}
+	return changes, nil
+}
+
+// end returns the zero-based line after the lines deleted by c.
+func (c lineChange) end() int {
+	return c.old + len(c.del)
+}
+
+// hunkRange formats the range of count lines starting at the zero-based
+// line start for a hunk header. An empty range refers to the line before.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+// splitLines splits b into lines, each including its newline.
+// The last line lacks the newline if b does not end with one.
+func splitLines(b []byte) []string {
+	var lines []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			i = len(b)
+		}
+		lines = append(lines, string(b[:i]))
+		b = b[i:]
+	}
+	return lines
+}
+
+// writeLines writes lines to w, each prefixed with prefix.
+func writeLines(w io.Writer, prefix string, lines []string) {
+	for _, line := range lines {
+		fmt.Fprint(w, prefix, line)
+		if line[len(line)-1] != '\n' {
+			fmt.Fprint(w, "\n\\ No newline at end of file\n")
+		}
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// errorType is an error type which can be the target of errors.As.
+type errorType struct {
+	typ  types.Type
+	expr string // the type as written in the file, e.g. "*os.PathError"
+	name string // the name of the type, e.g. "PathError"
+	pkg  string // the name of the package of the type in the file
+}
+
+// errorsFunc returns e as a call of a function of the errors package with
+// two arguments, e.g. errors.As or errors.Is, and the name of the function.
+// It returns nil and "" if e is no such call.
+func errorsFunc(info types.Info, e ast.Expr) (*ast.CallExpr, string) {
+	call, ok := e.(*ast.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return nil, ""
+	}
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil, ""
+	}
+	fn, ok := info.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "errors" {
+		return nil, ""
+	}
+	return call, fn.Name()
+}
+
+// isErrorsAs reports whether e is a call of errors.As
+// whose target is the address of a variable, e.g. &perr.
+func isErrorsAs(info types.Info, e ast.Expr) bool {
+	call, name := errorsFunc(info, e)
+	if name != "As" {
+		return false
+	}
+	u, ok := call.Args[1].(*ast.UnaryExpr)
+	if !ok || u.Op != token.AND {
+		return false
+	}
+	_, ok = u.X.(*ast.Ident)
+	return ok
+}
+
+// findErrorsAs returns the first if statement of the chain of
+// if-else statements with errors.As conditions enclosing the
+// interval [start, end].
+func findErrorsAs(f *ast.File, info types.Info, start, end token.Pos) (*ast.IfStmt, error) {
+	path, _ := astutil.PathEnclosingInterval(f, start, end)
+	for i, n := range path {
+		head, ok := n.(*ast.IfStmt)
+		if !ok || !isErrorsAs(info, head.Cond) {
+			continue
+		}
+		for _, n := range path[i+1:] {
+			parent, ok := n.(*ast.IfStmt)
+			if !ok || parent.Else != head || !isErrorsAs(info, parent.Cond) {
+				break
+			}
+			head = parent
+		}
+		return head, nil
+	}
+	return nil, errNotFound
+}
+
+// errorTypes returns the exported error types of the packages imported
+// by the file f, sorted by their expressions. Types implementing error
+// with a pointer receiver are returned as pointers.
+func errorTypes(f *ast.File, info types.Info) []errorType {
+	errType := types.Universe.Lookup("error").Type()
+	errIface := errType.Underlying().(*types.Interface)
+
+	var typs []errorType
+	for _, pkgName := range importedPackages(f, info) {
+		scope := pkgName.Imported().Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !obj.Exported() {
+				continue
+			}
+			if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				continue
+			}
+
+			et := errorType{name: name, pkg: pkgName.Name(), expr: pkgName.Name() + "." + name}
+			t := obj.Type()
+			switch {
+			case types.IsInterface(t):
+				if types.Identical(t, errType) || !types.AssignableTo(t, errType) {
+					continue
+				}
+				et.typ = t
+			case types.Implements(t, errIface):
+				et.typ = t
+			case types.Implements(types.NewPointer(t), errIface):
+				et.typ = types.NewPointer(t)
+				et.expr = "*" + et.expr
+			default:
+				continue
+			}
+			typs = append(typs, et)
+		}
+	}
+	sort.Slice(typs, func(i, j int) bool { return typs[i].expr < typs[j].expr })
+	return typs
+}
+
+// importedPackages returns the names of the packages imported by the file
+// f, except for the blank and dot imports.
+func importedPackages(f *ast.File, info types.Info) []*types.PkgName {
+	var pkgs []*types.PkgName
+	for _, spec := range f.Imports {
+		if spec.Name != nil && (spec.Name.Name == "_" || spec.Name.Name == ".") {
+			continue
+		}
+		obj := info.Implicits[spec]
+		if spec.Name != nil {
+			obj = info.Defs[spec.Name]
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			pkgs = append(pkgs, pkgName)
+		}
+	}
+	return pkgs
+}
+
+// fillErrorsAs adds an else-if clause with an errors.As condition to the
+// chain starting with head for every error type of the packages imported
+// by the file f which is not a target in the chain yet. It returns the
+// declaration of the new targets, or nil if there are none.
+func fillErrorsAs(pkg *packages.Package, f *ast.File, head *ast.IfStmt, opts options) *ast.DeclStmt {
+	// Find the last errors.As clause of the chain; the new
+	// clauses are inserted after it, before an else block.
+	var existing []types.Type
+	last := head
+	for {
+		target := last.Cond.(*ast.CallExpr).Args[1].(*ast.UnaryExpr).X
+		existing = append(existing, pkg.TypesInfo.TypeOf(target))
+		next, ok := last.Else.(*ast.IfStmt)
+		if !ok || !isErrorsAs(*pkg.TypesInfo, next.Cond) {
+			break
+		}
+		last = next
+	}
+
+	used := make(map[string]bool)
+	free := freeName(pkg.Types, head.Pos(), used)
+
+	call := head.Cond.(*ast.CallExpr)
+	decl := &ast.GenDecl{Tok: token.VAR}
+	var found []types.Type
+	for _, et := range errorTypes(f, *pkg.TypesInfo) {
+		if containsType(existing, et.typ) || containsType(found, et.typ) {
+			continue
+		}
+		found = append(found, et.typ)
+
+		name := errorVarName(et, free)
+		used[name] = true
+		decl.Specs = append(decl.Specs, &ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  ast.NewIdent(et.expr),
+		})
+		clause := &ast.IfStmt{
+			Cond: &ast.CallExpr{
+				Fun:  call.Fun,
+				Args: []ast.Expr{call.Args[0], &ast.UnaryExpr{Op: token.AND, X: ast.NewIdent(name)}},
+			},
+			Body: &ast.BlockStmt{List: caseBody(et.expr, opts.body)},
+			Else: last.Else,
+		}
+		last.Else = clause
+		last = clause
+	}
+	if len(decl.Specs) == 0 {
+		return nil
+	}
+	return &ast.DeclStmt{Decl: decl}
+}
+
+// errorsAsEdits fills the errors.As if-else chain starting with head and
+// returns the edit of the chain preceded by the declaration of the new
+// targets. If the chain continues an if statement, e.g. if err == nil {}
+// else if errors.As(err, &perr) {}, the declaration is a second edit
+// inserted before the outermost if statement instead.
+func errorsAsEdits(prog *program, pkg *packages.Package, f *ast.File, head *ast.IfStmt, opts options) ([]output, error) {
+	outer := head
+	path, _ := astutil.PathEnclosingInterval(f, head.Pos(), head.End())
+	for _, n := range path {
+		if parent, ok := n.(*ast.IfStmt); ok && parent.Else == outer {
+			outer = parent
+		}
+	}
+
+	start := prog.Fset.Position(head.Pos()).Offset
+	end := prog.Fset.Position(head.End()).Offset
+	decl := fillErrorsAs(pkg, f, head, opts)
+	if decl == nil {
+		out, err := prepareOutput(head, start, end)
+		if err != nil {
+			return nil, err
+		}
+		return []output{out}, nil
+	}
+	if outer == head {
+		out, err := prepareOutput([]ast.Stmt{decl, head}, start, end)
+		if err != nil {
+			return nil, err
+		}
+		return []output{out}, nil
+	}
+
+	out, err := prepareOutput(head, start, end)
+	if err != nil {
+		return nil, err
+	}
+	at := prog.Fset.Position(outer.Pos()).Offset
+	declOut, err := prepareOutput(decl, at, at)
+	if err != nil {
+		return nil, err
+	}
+	declOut.Code += "\n"
+	return []output{out, declOut}, nil
+}
+
+// freeName returns a function reporting whether a name is neither used
+// nor declared in the scope at pos in pkg, nor a keyword.
+func freeName(pkg *types.Package, pos token.Pos, used map[string]bool) func(name string) bool {
+	scope := pkg.Scope().Innermost(pos)
+	if scope == nil {
+		scope = pkg.Scope()
+	}
+	return func(name string) bool {
+		if used[name] || token.IsKeyword(name) {
+			return false
+		}
+		_, obj := scope.LookupParent(name, token.NoPos)
+		return obj == nil
+	}
+}
+
+// containsType reports whether typs contains a type identical to t.
+func containsType(typs []types.Type, t types.Type) bool {
+	for _, typ := range typs {
+		if types.Identical(typ, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorVarName returns a free name for a target of the error type et,
+// e.g. "pathErr" for "*os.PathError" or "osPathErr" if it is taken.
+func errorVarName(et errorType, free func(name string) bool) string {
+	base := lowerInitialism(et.name)
+	if strings.HasSuffix(base, "Error") {
+		base = strings.TrimSuffix(base, "Error") + "Err"
+	}
+	if free(base) {
+		return base
+	}
+	r := []rune(base)
+	qualified := et.pkg + string(unicode.ToUpper(r[0])) + string(r[1:])
+	name := qualified
+	for i := 2; !free(name); i++ {
+		name = fmt.Sprintf("%s%d", qualified, i)
+	}
+	return name
+}
+
+// lowerInitialism lower-cases the leading upper-case letters of name,
+// except for the last one if it starts the next word, e.g. "urlError"
+// for "URLError" and "eof" for "EOF".
+func lowerInitialism(name string) string {
+	r := []rune(name)
+	n := 0
+	for n < len(r) && unicode.IsUpper(r[n]) {
+		n++
+	}
+	if n > 1 && n < len(r) {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
+
+// errorsAsByLine fills the innermost errors.As if-else chain of the file f
+// spanning the given line.
+func errorsAsByLine(prog *program, pkg *packages.Package, f *ast.File, line int, opts options) ([]output, error) {
+	var inner *ast.IfStmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		s, ok := n.(*ast.IfStmt)
+		if !ok || !isErrorsAs(*pkg.TypesInfo, s.Cond) {
+			return true
+		}
+		if prog.Fset.Position(s.Pos()).Line <= line && line <= prog.Fset.Position(s.End()).Line {
+			inner = s
+		}
+		return true
+	})
+	if inner == nil {
+		return nil, errNotFound
+	}
+
+	head, err := findErrorsAs(f, *pkg.TypesInfo, inner.Pos(), inner.End())
+	if err != nil {
+		return nil, err
+	}
+	return errorsAsEdits(prog, pkg, f, head, opts)
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/davidrjenni/reftools/fillswitch"
+	"golang.org/x/tools/go/packages"
+)
+
+var errNoErrorSwitch = errors.New("not a switch on an error variable")
+
+// fillErrorSwitch rewrites swtch, a (type) switch on an error variable, as
+// a switch without tag with errors.Is and errors.As cases, e.g.
+// switch { case errors.Is(err, io.EOF): case errors.As(err, &pathErr): },
+// and adds a case for every sentinel error and error type which is not
+// handled yet. A switch without tag with such cases is only filled. It
+// returns the declarations of the new targets followed by the switch,
+// with the packages qualified by qf.
+func fillErrorSwitch(ctx context.Context, prog *program, pkg *packages.Package, f *ast.File, swtch ast.Stmt, qf types.Qualifier, opts options) ([]ast.Stmt, error) {
+	info := pkg.TypesInfo
+
+	used := make(map[string]bool)
+	free := freeName(pkg.Types, swtch.Pos(), used)
+	decl := &ast.GenDecl{Tok: token.VAR}
+	// target declares a new target of type t for errors.As.
+	target := func(t types.Type) string {
+		et := newErrorType(qf, t)
+		name := errorVarName(et, free)
+		used[name] = true
+		decl.Specs = append(decl.Specs, &ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  ast.NewIdent(et.expr),
+		})
+		return name
+	}
+
+	var (
+		subject  ast.Expr
+		res      *ast.SwitchStmt
+		handled  []types.Type                  // the types of the targets of errors.As
+		sentinel = make(map[types.Object]bool) // the errors of errors.Is
+	)
+	switch s := swtch.(type) {
+	case *ast.TypeSwitchStmt:
+		subject = assertedExpr(s)
+		if !isErrorVar(info, subject) {
+			return nil, errNoErrorSwitch
+		}
+		res = &ast.SwitchStmt{Init: s.Init, Body: &ast.BlockStmt{}}
+		for _, stmt := range s.Body.List {
+			cc := stmt.(*ast.CaseClause)
+			// The variable declared in the switch
+			// is replaced by the target or the error.
+			name := types.ExprString(subject)
+			var list []ast.Expr
+			for _, e := range cc.List {
+				if isNil(info, e) {
+					list = append(list, &ast.BinaryExpr{X: subject, Op: token.EQL, Y: ast.NewIdent("nil")})
+					continue
+				}
+				t := info.TypeOf(e)
+				handled = append(handled, t)
+				tname := target(t)
+				list = append(list, errorsCall(qf, "As", subject, &ast.UnaryExpr{Op: token.AND, X: ast.NewIdent(tname)}))
+				if len(cc.List) == 1 {
+					name = tname
+				}
+			}
+			if obj := info.Implicits[cc]; obj != nil {
+				rename(info, cc.Body, obj, name)
+			}
+			res.Body.List = append(res.Body.List, &ast.CaseClause{List: list, Body: cc.Body})
+		}
+
+	case *ast.SwitchStmt:
+		if s.Tag != nil {
+			subject = s.Tag
+			if !isErrorVar(info, subject) {
+				return nil, errNoErrorSwitch
+			}
+			res = &ast.SwitchStmt{Init: s.Init, Body: &ast.BlockStmt{}}
+			for _, stmt := range s.Body.List {
+				cc := stmt.(*ast.CaseClause)
+				var list []ast.Expr
+				for _, e := range cc.List {
+					if isNil(info, e) {
+						list = append(list, &ast.BinaryExpr{X: subject, Op: token.EQL, Y: ast.NewIdent("nil")})
+						continue
+					}
+					if obj := usedObject(info, e); obj != nil {
+						sentinel[obj] = true
+					}
+					list = append(list, errorsCall(qf, "Is", subject, e))
+				}
+				res.Body.List = append(res.Body.List, &ast.CaseClause{List: list, Body: cc.Body})
+			}
+			break
+		}
+
+		// Fill the switch without tag.
+		res = s
+		for _, stmt := range s.Body.List {
+			for _, e := range stmt.(*ast.CaseClause).List {
+				call, name := errorsFunc(*info, e)
+				switch name {
+				case "As":
+					if p, ok := info.TypeOf(call.Args[1]).(*types.Pointer); ok {
+						handled = append(handled, p.Elem())
+					}
+				case "Is":
+					if obj := usedObject(info, call.Args[1]); obj != nil {
+						sentinel[obj] = true
+					}
+				default:
+					continue
+				}
+				if subject == nil {
+					subject = call.Args[0]
+				}
+			}
+		}
+		if subject == nil || !isErrorVar(info, subject) {
+			return nil, errNoErrorSwitch
+		}
+	}
+
+	typs, vars, err := errorCandidates(ctx, prog, pkg, f, qf, opts)
+	if err != nil {
+		return nil, err
+	}
+	var clauses []ast.Stmt
+	for _, v := range vars {
+		if sentinel[v] {
+			continue
+		}
+		name := v.Name()
+		if q := qf(v.Pkg()); q != "" {
+			name = q + "." + name
+		}
+		clauses = append(clauses, &ast.CaseClause{
+			List: []ast.Expr{errorsCall(qf, "Is", subject, ast.NewIdent(name))},
+			Body: caseBody(name, opts.body),
+		})
+	}
+	for _, t := range typs {
+		if containsType(handled, t) {
+			continue
+		}
+		name := target(t)
+		clauses = append(clauses, &ast.CaseClause{
+			List: []ast.Expr{errorsCall(qf, "As", subject, &ast.UnaryExpr{Op: token.AND, X: ast.NewIdent(name)})},
+			Body: caseBody(fillswitch.QualifiedTypeString(t, qf), opts.body),
+		})
+	}
+	cc, err := newDefaultClause(pkg, swtch, qf, "unexpected error: %v", types.ExprString(subject), opts.dflt)
+	if err != nil {
+		return nil, err
+	}
+	if cc != nil {
+		clauses = append(clauses, cc)
+	}
+	res.Body.List = placeClauses(res.Body.List, clauses, opts.insert)
+
+	if len(decl.Specs) == 0 {
+		return []ast.Stmt{res}, nil
+	}
+	return []ast.Stmt{&ast.DeclStmt{Decl: decl}, res}, nil
+}
+
+// errorCandidates returns the error types and the sentinel errors, i.e.
+// the variables of type error, for the cases of a switch in the file f
+// of pkg. Without -scope, they are the ones of pkg and of the packages
+// imported by f; otherwise, they are searched in the scope.
+func errorCandidates(ctx context.Context, prog *program, pkg *packages.Package, f *ast.File, qf types.Qualifier, opts options) ([]types.Type, []types.Object, error) {
+	errType := types.Universe.Lookup("error").Type()
+
+	var objs []types.Object
+	if prog.deps {
+		pkgs := []*types.Package{pkg.Types}
+		for _, pkgName := range importedPackages(f, *pkg.TypesInfo) {
+			pkgs = append(pkgs, pkgName.Imported())
+		}
+		objs = fillswitch.PackageObjects(pkgs...)
+	} else {
+		var err error
+		if objs, err = findObjects(ctx, prog, pkg.Types); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fopts := opts.cases(prog.Fset, qf)
+	var typs []types.Type
+	for _, t := range fillswitch.TypeCases(pkg.Types, errType.Underlying().(*types.Interface), objs, fopts) {
+		// A target of type error matches every error.
+		if !types.Identical(t, errType) {
+			typs = append(typs, t)
+		}
+	}
+	var vars []types.Object
+	for _, v := range fillswitch.ValueCases(pkg.Types, errType, objs, fopts) {
+		if _, ok := v.(*types.Var); ok && types.Identical(v.Type(), errType) {
+			vars = append(vars, v)
+		}
+	}
+	return typs, vars, nil
+}
+
+// newErrorType returns the error type t with the packages qualified by qf.
+func newErrorType(qf types.Qualifier, t types.Type) errorType {
+	et := errorType{typ: t, expr: fillswitch.QualifiedTypeString(t, qf), name: "target"}
+	if n, ok := deref(t).(*types.Named); ok {
+		et.name = n.Obj().Name()
+		if n.Obj().Pkg() != nil {
+			et.pkg = n.Obj().Pkg().Name()
+		}
+	}
+	return et
+}
+
+// isErrorVar reports whether e is a variable or a field of type error,
+// which can be passed to errors.Is and errors.As repeatedly.
+func isErrorVar(info *types.Info, e ast.Expr) bool {
+	switch e.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		return types.Identical(info.TypeOf(e), types.Universe.Lookup("error").Type())
+	}
+	return false
+}
+
+// isNil reports whether e is the predeclared nil.
+func isNil(info *types.Info, e ast.Expr) bool {
+	id, ok := ast.Unparen(e).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = info.Uses[id].(*types.Nil)
+	return ok
+}
+
+// usedObject returns the object denoted by e, an identifier or a
+// qualified identifier, or nil.
+func usedObject(info *types.Info, e ast.Expr) types.Object {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		return info.Uses[e]
+	case *ast.SelectorExpr:
+		return info.Uses[e.Sel]
+	}
+	return nil
+}
+
+// errorsCall returns the call errors.<fun>(err, arg)
+// with the errors package qualified by qf.
+func errorsCall(qf types.Qualifier, fun string, err, arg ast.Expr) *ast.CallExpr {
+	return &ast.CallExpr{
+		Fun:  ast.NewIdent(stdFunc(qf, "errors", fun)),
+		Args: []ast.Expr{err, arg},
+	}
+}
+
+// rename renames the uses of obj in body to name.
+func rename(info *types.Info, body []ast.Stmt, obj types.Object, name string) {
+	for _, stmt := range body {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && info.Uses[id] == obj {
+				id.Name = name
+			}
+			return true
+		})
+	}
+}
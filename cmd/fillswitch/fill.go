@@ -5,167 +5,311 @@
 package main
 
 import (
+	"context"
 	"go/ast"
+	"go/token"
 	"go/types"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
-	"golang.org/x/tools/go/loader"
+	"github.com/davidrjenni/reftools/fillswitch"
+	"golang.org/x/tools/go/packages"
 )
 
-func fillSwitch(pkg *loader.PackageInfo, lprog *loader.Program, swtch ast.Stmt, typ types.Type) ast.Stmt {
-	// Do not try to fill an empty switch statement (with no tag expression and therefore typ == nil).
+// fillSwitch returns the case clauses missing in the (type) switch swtch
+// on a value of type typ, with the packages qualified by qf. The existing
+// cases are left untouched.
+func fillSwitch(ctx context.Context, pkg *packages.Package, prog *program, swtch ast.Stmt, typ types.Type, qf types.Qualifier, opts options) ([]ast.Stmt, error) {
+	// A switch statement without a tag expression, and therefore
+	// typ == nil, has no cases to fill, except with -errors-switch.
 	if typ == nil {
-		return swtch
+		return nil, errNoTag
 	}
 
-	switch swtch := swtch.(type) {
-	case *ast.SwitchStmt:
-		existing := make(map[string]bool)
-		// Don't add the identifier we switch over to the case statements.
-		if id, ok := swtch.Tag.(*ast.Ident); ok {
-			existing[id.Name] = true
+	find := func(iface *types.Interface) ([]types.Object, error) {
+		// The implementations of the empty interface are all types.
+		if iface != nil && iface.NumMethods() > 0 && opts.index != nil {
+			return findImplementations(ctx, prog, pkg.Types, iface, opts.index)
 		}
-		for _, cc := range swtch.Body.List {
-			for _, e := range cc.(*ast.CaseClause).List {
-				existing[typeString(pkg.Pkg, pkg.Info.TypeOf(e))] = true
-			}
-		}
-		for _, v := range findConstsAndVars(lprog, pkg.Pkg, typ) {
-			name := ast.NewIdent(v.Name())
-			if imported(pkg.Pkg, v) {
-				name = ast.NewIdent(v.Pkg().Name() + "." + v.Name())
-			}
-			if !existing[v.Name()] {
-				swtch.Body.List = append(swtch.Body.List, &ast.CaseClause{
-					List: []ast.Expr{name},
-				})
-			}
-		}
-		return swtch
+		return findObjects(ctx, prog, pkg.Types)
+	}
+	clauses, err := fillswitch.Clauses(pkg.Types, pkg.TypesInfo, swtch, find, opts.cases(prog.Fset, qf))
+	if err != nil {
+		return nil, err
+	}
 
-	case *ast.TypeSwitchStmt:
-		iface, ok := typ.Underlying().(*types.Interface)
-		if !ok {
-			return swtch
-		}
-		existing := make(map[string]bool)
-		for _, cc := range swtch.Body.List {
-			for _, e := range cc.(*ast.CaseClause).List {
-				name := typeString(pkg.Pkg, pkg.Info.TypeOf(e))
-				existing[name] = true
-			}
-		}
-		for _, t := range findTypes(lprog, pkg.Pkg, iface) {
-			if ts := typeString(pkg.Pkg, t); !existing[ts] {
-				swtch.Body.List = append(swtch.Body.List, &ast.CaseClause{
-					List: []ast.Expr{ast.NewIdent(ts)},
-				})
-			}
-		}
-		return swtch
+	cc, err := defaultClause(pkg, swtch, qf, opts.dflt)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(clauses); cc != nil && n > 0 && isDefault(clauses[n-1]) {
+		// in place of the empty default clause of a sealed interface
+		clauses[n-1] = cc
+	} else if cc != nil {
+		clauses = append(clauses, cc)
+	}
+	return clauses, nil
+}
 
-	default:
-		panic("unreachable")
+// cases returns the settings of opts for the cases proposed by the
+// fillswitch package, which are qualified by qf.
+func (opts options) cases(fset *token.FileSet, qf types.Qualifier) fillswitch.Options {
+	fopts := fillswitch.Options{
+		Receivers: opts.receivers,
+		Order:     opts.order,
+		Fset:      fset,
+		Include:   opts.include,
+		Exclude:   opts.exclude,
+		Body:      func(name string) []ast.Stmt { return caseBody(name, opts.body) },
+		Qualifier: qf,
 	}
+	if opts.reach != nil {
+		fopts.Filter = opts.reach.filter
+	}
+	return fopts
 }
 
-func findConstsAndVars(lprog *loader.Program, pkg *types.Package, typ types.Type) []types.Object {
-	var vars []types.Object
-	for _, info := range lprog.AllPackages {
-		for _, obj := range info.Defs {
-			switch obj := obj.(type) {
-			case *types.Const:
-				if visible(pkg, obj) && types.AssignableTo(obj.Type(), typ) {
-					vars = append(vars, obj)
-				}
-			case *types.Var:
-				if visible(pkg, obj) && !obj.IsField() && types.AssignableTo(obj.Type(), typ) {
-					vars = append(vars, obj)
-				}
+// Insertion positions of the new cases, selected with the -insert flag.
+const (
+	insertEnd     = "end"            // after the existing cases
+	insertDefault = "before-default" // before the default clause, if any
+	insertSorted  = "sorted"         // interleaved with the existing cases, see insertionIndexes
+)
+
+// insertCases returns the edits inserting clauses into the body of swtch
+// at the positions selected by insert, such that the existing cases,
+// their bodies and comments are left as they are. The clauses inserted
+// at the end replace the closing brace, which is put on a line of its
+// own; the others replace the keyword of the clause they precede.
+func insertCases(fset *token.FileSet, swtch ast.Stmt, clauses []ast.Stmt, insert string) ([]output, error) {
+	body := switchBody(swtch)
+	indexes := insertionIndexes(body.List, clauses, insert)
+	var outs []output
+	for i := 0; i < len(clauses); {
+		j := i + 1
+		for j < len(clauses) && indexes[j] == indexes[i] {
+			j++
+		}
+		if k := indexes[i]; k < len(body.List) {
+			keyword := "case"
+			if isDefault(body.List[k]) {
+				keyword = "default"
+			}
+			start := fset.Position(body.List[k].Pos()).Offset
+			out, err := prepareOutput(clauses[i:j], start, start+len(keyword))
+			if err != nil {
+				return nil, err
 			}
+			out.Code += "\n" + keyword
+			outs = append(outs, out)
+		} else {
+			out, err := appendCases(fset, body, clauses[i:j])
+			if err != nil {
+				return nil, err
+			}
+			outs = append(outs, out)
 		}
+		i = j
 	}
-
-	sort.Sort(objsByString(vars))
-	return vars
+	if len(outs) == 0 {
+		rbrace := fset.Position(body.Rbrace).Offset
+		outs = append(outs, output{Start: rbrace, End: rbrace + 1, Code: "}"})
+	}
+	return outs, nil
 }
 
-func findTypes(lprog *loader.Program, pkg *types.Package, iface types.Type) []types.Type {
-	var typs []types.Type
-
-	err := types.Universe.Lookup("error").Type()
-	if types.AssignableTo(err, iface) {
-		typs = append(typs, err)
+// appendCases returns the edit inserting clauses before the closing brace
+// of body.
+func appendCases(fset *token.FileSet, body *ast.BlockStmt, clauses []ast.Stmt) (output, error) {
+	rbrace := fset.Position(body.Rbrace).Offset
+	out, err := prepareOutput(clauses, rbrace, rbrace+1)
+	if err != nil {
+		return output{}, err
 	}
+	out.Code += "\n}"
+	// The closing brace may follow the opening
+	// brace or the last case on the same line.
+	prev := body.Lbrace
+	if n := len(body.List); n > 0 {
+		prev = body.List[n-1].End()
+	}
+	if fset.Position(prev).Line == fset.Position(body.Rbrace).Line {
+		out.Code = "\n" + out.Code
+	}
+	return out, nil
+}
 
-	for _, info := range lprog.AllPackages {
-		for _, obj := range info.Defs {
-			obj, ok := obj.(*types.TypeName)
-			if !ok || obj.IsAlias() || !visible(pkg, obj) {
-				continue
+// insertionIndexes returns for each of the new clauses the index of the
+// existing clause before which it is inserted, or len(existing) if it is
+// appended, in increasing order. With insertSorted, the clauses are sorted
+// by the text of their first expression or channel, ignoring a leading *,
+// and inserted before the first existing case which follows them, or
+// after the last existing case; a new default clause is appended.
+func insertionIndexes(existing, clauses []ast.Stmt, insert string) []int {
+	indexes := make([]int, len(clauses))
+	switch insert {
+	case insertDefault:
+		at := len(existing)
+		for k, stmt := range existing {
+			if isDefault(stmt) {
+				at = k
 			}
-
-			t := obj.Type().(*types.Named)
-			// Ignore iface itself and empty interfaces.
-			if i, ok := t.Underlying().(*types.Interface); ok && (iface == i || i.NumMethods() == 0) {
+		}
+		for i := range indexes {
+			indexes[i] = at
+		}
+	case insertSorted:
+		sort.SliceStable(clauses, func(i, j int) bool {
+			if isDefault(clauses[i]) || isDefault(clauses[j]) {
+				return !isDefault(clauses[i]) && isDefault(clauses[j])
+			}
+			return clauseKey(clauses[i]) < clauseKey(clauses[j])
+		})
+		last := -1 // the last existing case
+		for k, stmt := range existing {
+			if !isDefault(stmt) {
+				last = k
+			}
+		}
+		for i, cc := range clauses {
+			indexes[i] = last + 1
+			if isDefault(cc) {
+				indexes[i] = len(existing)
 				continue
 			}
-
-			if types.AssignableTo(t, iface) {
-				typs = append(typs, t)
-			} else if p := types.NewPointer(t); types.AssignableTo(p, iface) {
-				typs = append(typs, p)
+			for k, stmt := range existing[:last+1] {
+				if !isDefault(stmt) && clauseKey(cc) < clauseKey(stmt) {
+					indexes[i] = k
+					break
+				}
 			}
 		}
+	default:
+		for i := range indexes {
+			indexes[i] = len(existing)
+		}
 	}
+	return indexes
+}
 
-	sort.Sort(typesByString(typs))
-	return typs
+// placeClauses returns the existing clauses with the new clauses inserted
+// at the positions selected by insert, see insertionIndexes.
+func placeClauses(existing, clauses []ast.Stmt, insert string) []ast.Stmt {
+	indexes := insertionIndexes(existing, clauses, insert)
+	res := make([]ast.Stmt, 0, len(existing)+len(clauses))
+	i := 0
+	for k, stmt := range existing {
+		for ; i < len(clauses) && indexes[i] == k; i++ {
+			res = append(res, clauses[i])
+		}
+		res = append(res, stmt)
+	}
+	return append(res, clauses[i:]...)
 }
 
-func imported(pkg *types.Package, obj types.Object) bool {
-	return obj.Pkg() != pkg
+// isDefault reports whether the case or communication clause stmt is a
+// default clause.
+func isDefault(stmt ast.Stmt) bool {
+	switch cc := stmt.(type) {
+	case *ast.CaseClause:
+		return cc.List == nil
+	case *ast.CommClause:
+		return cc.Comm == nil
+	}
+	return false
 }
 
-func visible(pkg *types.Package, obj types.Object) bool {
-	if obj.Pkg() == pkg {
-		return true
+// clauseKey returns the text of the first expression of the case clause
+// stmt, or of the channel of the communication clause stmt, without a
+// leading *.
+func clauseKey(stmt ast.Stmt) string {
+	var x ast.Expr
+	switch cc := stmt.(type) {
+	case *ast.CaseClause:
+		if len(cc.List) > 0 {
+			x = cc.List[0]
+		}
+	case *ast.CommClause:
+		x = commChan(cc.Comm)
 	}
-	if !obj.Exported() {
-		return false
+	if x == nil {
+		return ""
 	}
+	return strings.TrimPrefix(types.ExprString(x), "*")
+}
 
-	// Rough approximation at the "internal" rules.
-
-	path := obj.Pkg().Path()
-	i := 0
+// Case bodies, selected with the -body flag.
+const (
+	bodyEmpty     = "empty"      // empty case bodies
+	bodyTodoNamed = "todo-named" // panic("TODO: <name>") with the name of the case
+	bodySnippet   = "snippet"    // a numbered tab stop, see snippetEdits
+)
 
-	switch {
-	case strings.HasSuffix(path, "/internal"):
-		i = len(path) - len("/internal")
-	case strings.Contains(path, "/internal/"):
-		i = strings.LastIndex(path, "/internal/") + 1
-	case path == "internal", strings.HasPrefix(path, "internal/"):
-		i = 0
+// caseBody returns the body of a generated case clause for the
+// constant, variable or type with the given name.
+func caseBody(name, body string) []ast.Stmt {
+	switch body {
+	case bodySnippet:
+		return []ast.Stmt{&ast.ExprStmt{X: ast.NewIdent(tabStop)}}
+	case bodyTodoNamed:
 	default:
-		return true
+		return nil
 	}
-	if i > 0 {
-		i--
+	return []ast.Stmt{
+		&ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun:  ast.NewIdent("panic"),
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("TODO: " + name)}},
+			},
+		},
 	}
-	prefix := path[:i]
-	return len(prefix) > 0 && strings.HasPrefix(pkg.Path(), prefix)
 }
 
-type typesByString []types.Type
-
-func (t typesByString) Len() int           { return len(t) }
-func (t typesByString) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t typesByString) Less(i, j int) bool { return t[i].String() < t[j].String() }
-
-type objsByString []types.Object
+// findObjects returns the types, constants and variables defined in the
+// packages of prog which can be the cases of a switch in pkg, see
+// fillswitch.Clauses.
+func findObjects(ctx context.Context, prog *program, pkg *types.Package) ([]types.Object, error) {
+	var (
+		mu   sync.Mutex
+		objs []types.Object
+	)
+	err := searchPackages(ctx, prog, func(p *packages.Package) {
+		if !searchable(pkg, p) {
+			return
+		}
+		var found []types.Object
+		for _, obj := range p.TypesInfo.Defs {
+			switch obj.(type) {
+			case *types.TypeName, *types.Const, *types.Var:
+				if fillswitch.Visible(pkg, obj) {
+					found = append(found, obj)
+				}
+			}
+		}
+		mu.Lock()
+		objs = append(objs, found...)
+		mu.Unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
 
-func (o objsByString) Len() int           { return len(o) }
-func (o objsByString) Swap(i, j int)      { o[i], o[j] = o[j], o[i] }
-func (o objsByString) Less(i, j int) bool { return o[i].String() < o[j].String() }
+// searchable reports whether the cases of a switch in pkg can come from
+// p. Another variant of pkg, e.g. the package augmented with its test
+// files, is not searched: its objects are distinct from the ones of pkg
+// and would be mistaken for imported ones. Neither are packages which
+// cannot be imported, i.e. external test packages and commands.
+func searchable(pkg *types.Package, p *packages.Package) bool {
+	switch {
+	case p.Types == pkg:
+		return true
+	case p.PkgPath == pkg.Path():
+		return false
+	default:
+		return p.Name != "main" && !strings.HasSuffix(p.PkgPath, "_test")
+	}
+}
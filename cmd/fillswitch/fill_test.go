@@ -6,26 +6,69 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+
+	"github.com/davidrjenni/reftools/fillswitch"
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/packages"
 )
 
 func TestFillByOffset(t *testing.T) {
 	tests := [...]struct {
-		folder string
-		offset int
+		folder       string
+		offset       int
+		reachable    bool
+		body         string
+		order        string
+		dflt         string
+		errorsAs     bool
+		errorsSwitch bool
+		ctxDone      bool
 	}{
 		{folder: "typeswitch_1", offset: 75},
 		{folder: "typeswitch_2", offset: 59},
 		{folder: "typeswitch_3", offset: 69},
 		{folder: "typeswitch_4", offset: 67},
 		{folder: "typeswitch_5", offset: 160},
+		{folder: "typeswitch_generic", offset: 157},
+		{folder: "typeswitch_union", offset: 158},
 		{folder: "broken_typeswitch", offset: 146},
 		{folder: "switch_1", offset: 78},
-		{folder: "empty_switch", offset: 51},
 		{folder: "multipkgs", offset: 75},
+		{folder: "reachable", offset: 338, reachable: true},
+		{folder: "todo_named", offset: 206, body: bodyTodoNamed},
+		{folder: "todo_named_value", offset: 92, body: bodyTodoNamed},
+		{folder: "enum_values", offset: 138, body: bodyTodoNamed},
+		{folder: "nested_select", offset: 285},
+		{folder: "nested_range", offset: 285},
+		{folder: "errors_as", offset: 99, errorsAs: true},
+		{folder: "order_decl", offset: 73, order: fillswitch.OrderDecl},
+		{folder: "order_pkg", offset: 73, order: fillswitch.OrderPkg},
+		{folder: "default_panic", offset: 138, dflt: defaultPanic},
+		{folder: "default_error", offset: 151, dflt: defaultError},
+		{folder: "sealed", offset: 235},
+		{folder: "errors_switch", offset: 327, errorsSwitch: true},
+		{folder: "errors_switch_tagless", offset: 350, errorsSwitch: true},
+		{folder: "select", offset: 189, ctxDone: true},
+		{folder: "cursor_anywhere", offset: 83},
+		{folder: "cursor_anywhere", offset: 95},
+		{folder: "cursor_anywhere", offset: 98},
+		{folder: "renamed_import", offset: 61},
+		{folder: "dot_import", offset: 51},
+		{folder: "import_collision", offset: 303},
 	}
 
 	for _, test := range tests {
@@ -33,22 +76,22 @@ func TestFillByOffset(t *testing.T) {
 		if err != nil {
 			t.Fatalf("%s: %v\n", test.folder, err)
 		}
-		lprog, err := load(path, false)
+		prog, err := load(path, nil, scopeDefault)
 		if err != nil {
 			t.Fatalf("%s: %v\n", test.folder, err)
 		}
 
-		var buf bytes.Buffer
-		if err = byOffset(lprog, path, test.offset, &buf); err != nil {
-			t.Fatalf("%s: %v\n", test.folder, err)
+		opts := options{body: test.body, order: test.order, dflt: test.dflt, errorsAs: test.errorsAs, errorsSwitch: test.errorsSwitch, ctxDone: test.ctxDone}
+		if test.reachable {
+			opts.reach, _ = buildReachability(prog)
 		}
 
-		var outs []output
-		if err = json.NewDecoder(&buf).Decode(&outs); err != nil {
+		outs, err := byOffset(context.Background(), prog, path, test.offset, opts)
+		if err != nil {
 			t.Fatalf("%s: %v\n", test.folder, err)
 		}
-		if len(outs) != 1 {
-			t.Fatalf("%s: expected len(outs) == 1\n", test.folder)
+		if len(outs) == 0 {
+			t.Fatalf("%s: expected len(outs) > 0\n", test.folder)
 		}
 		got := []byte(outs[0].Code)
 
@@ -60,6 +103,43 @@ func TestFillByOffset(t *testing.T) {
 		if !bytes.Equal(got, want) {
 			t.Errorf("%s:\ngot:\n%s\n\nwant:\n%s\n\n", test.folder, got, want)
 		}
+		checkImports(t, test.folder, outs[1:])
+	}
+}
+
+func TestFillErrorsAsElseIf(t *testing.T) {
+	path, err := absPath("testdata/errors_as_else_if/input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := load(path, nil, scopeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offset := bytes.Index(src, []byte("errors.As"))
+	outs, err := byOffset(context.Background(), prog, path, offset, options{errorsAs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outs) != 2 {
+		t.Fatalf("got %d edits, want the chain and the declaration", len(outs))
+	}
+	got, err := applyEdits(src, indentEdits(src, outs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := ioutil.ReadFile("testdata/errors_as_else_if/output.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got:\n%s\n\nwant:\n%s\n\n", got, want)
 	}
 }
 
@@ -75,7 +155,8 @@ func TestFillByLine(t *testing.T) {
 		{folder: "typeswitch_5", line: 10},
 		{folder: "broken_typeswitch", line: 7},
 		{folder: "switch_1", line: 7},
-		{folder: "empty_switch", line: 6},
+		{folder: "nested_select", line: 19},
+		{folder: "nested_range", line: 19},
 	}
 
 	for _, test := range tests {
@@ -83,22 +164,17 @@ func TestFillByLine(t *testing.T) {
 		if err != nil {
 			t.Fatalf("%s: %v\n", test.folder, err)
 		}
-		lprog, err := load(path, false)
+		prog, err := load(path, nil, scopeDefault)
 		if err != nil {
 			t.Fatalf("%s: %v\n", test.folder, err)
 		}
 
-		var buf bytes.Buffer
-		if err = byLine(lprog, path, test.line, &buf); err != nil {
+		outs, err := byLine(context.Background(), prog, path, test.line, options{})
+		if err != nil {
 			t.Fatalf("%s: %v\n", test.folder, err)
 		}
-
-		var outs []output
-		if err = json.NewDecoder(&buf).Decode(&outs); err != nil {
-			t.Fatal(err)
-		}
-		if len(outs) != 1 {
-			t.Fatalf("%s: expected len(outs) == 1\n", test.folder)
+		if len(outs) == 0 {
+			t.Fatalf("%s: expected len(outs) > 0\n", test.folder)
 		}
 		got := []byte(outs[0].Code)
 
@@ -110,5 +186,662 @@ func TestFillByLine(t *testing.T) {
 		if !bytes.Equal(got, want) {
 			t.Errorf("%s:\ngot:\n%s\n\nwant:\n%s\n\n", test.folder, got, want)
 		}
+		checkImports(t, test.folder, outs[1:])
+	}
+}
+
+// checkImports compares the edit adding imports in outs with the one in
+// imports.golden of the folder, if any.
+func checkImports(t *testing.T, folder string, outs []output) {
+	t.Helper()
+	want, err := ioutil.ReadFile(filepath.Join("./testdata", folder, "imports.golden"))
+	if os.IsNotExist(err) {
+		if len(outs) != 0 {
+			t.Errorf("%s: got %+v, want no imports", folder, outs)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("%s: %v\n", folder, err)
+	}
+	if len(outs) != 1 || outs[0].Code != string(want) {
+		t.Errorf("%s: got %+v, want imports %q", folder, outs, want)
+	}
+}
+
+func TestFillAt(t *testing.T) {
+	path, err := absPath("testdata/typeswitch_1/input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/typeswitch_1/output.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := [...]struct {
+		offset, line int
+		err          error
+	}{
+		{offset: 1, line: 6},  // no switch at the offset, fall back to the line
+		{offset: 56, line: 1}, // the offset takes precedence
+		{line: 6},
+		{offset: 1, err: errNotFound},
+		{line: 1, err: errNotFound},
+	}
+	for _, test := range tests {
+		// Filling modifies the syntax trees.
+		prog, err := load(path, nil, scopeDefault)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outs, err := fillAt(context.Background(), prog, path, test.offset, test.line, options{})
+		if err != test.err {
+			t.Errorf("offset %d, line %d: got error %v, want %v", test.offset, test.line, err, test.err)
+			continue
+		}
+		if test.err == nil && (len(outs) != 1 || outs[0].Code != string(want)) {
+			t.Errorf("offset %d, line %d: got %+v, want code %q", test.offset, test.line, outs, want)
+		}
+	}
+}
+
+func TestFillTagless(t *testing.T) {
+	path, err := absPath("testdata/empty_switch/input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := load(path, nil, scopeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, at := range [][2]int{{51, 0}, {0, 6}} {
+		if outs, err := fillAt(context.Background(), prog, path, at[0], at[1], options{}); err != errNoTag {
+			t.Errorf("offset %d, line %d: got %+v, error %v, want %v", at[0], at[1], outs, err, errNoTag)
+		}
+	}
+}
+
+func TestFillScope(t *testing.T) {
+	path, err := absPath("testdata/typeswitch_5/input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imports := "\n\nimport (\n\t\"io\"\n\n\t\"github.com/davidrjenni/reftools/cmd/fillswitch/testdata/typeswitch_5/internal/foo\"\n\t_ \"github.com/davidrjenni/reftools/cmd/fillswitch/testdata/typeswitch_5/internal/foo\"\n)"
+	tests := [...]struct {
+		scope   string
+		want    string
+		imports string // the code of the edit adding imports, if any
+	}{
+		{
+			scope: scopePackage,
+			want:  "case *panicReader:\ncase myReadWriter:\n}",
+		},
+		{
+			scope:   "./testdata/typeswitch_5/internal/...",
+			want:    "case *panicReader:\ncase *foo.NopReader1:\ncase myReadWriter:\ncase foo.NopReader2:\n}",
+			imports: imports,
+		},
+		{
+			scope:   "io,./testdata/typeswitch_5/internal/foo",
+			want:    "case *panicReader:\ncase *foo.NopReader1:\ncase *io.LimitedReader:\ncase *io.PipeReader:\ncase *io.SectionReader:\ncase myReadWriter:\ncase foo.NopReader2:\ncase io.ReadCloser:\ncase io.ReadSeekCloser:\ncase io.ReadSeeker:\ncase io.ReadWriteCloser:\ncase io.ReadWriteSeeker:\ncase io.ReadWriter:\n}",
+			imports: imports,
+		},
+	}
+	for _, test := range tests {
+		prog, err := load(path, nil, test.scope)
+		if err != nil {
+			t.Fatalf("%s: %v", test.scope, err)
+		}
+		outs, err := byLine(context.Background(), prog, path, 10, options{})
+		if err != nil {
+			t.Fatalf("%s: %v", test.scope, err)
+		}
+		if len(outs) == 0 || outs[0].Code != test.want {
+			t.Errorf("%s: got %+v, want code %q", test.scope, outs, test.want)
+			continue
+		}
+		if test.imports == "" && len(outs) != 1 || test.imports != "" && (len(outs) != 2 || outs[1].Code != test.imports) {
+			t.Errorf("%s: got %+v, want imports %q", test.scope, outs[1:], test.imports)
+		}
+	}
+}
+
+func TestImplIndex(t *testing.T) {
+	path, err := absPath("testdata/typeswitch_5/input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := load(path, nil, "io,./testdata/typeswitch_5/internal/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	fill := func() string {
+		idx, err := openIndex(indexPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outs, err := byLine(context.Background(), prog, path, 10, options{index: idx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.save(); err != nil {
+			t.Fatal(err)
+		}
+		return outs[0].Code
+	}
+	// edit changes the entries of package io and its test variant in the index.
+	edit := func(fn func(*indexEntry)) {
+		idx, err := openIndex(indexPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []string{"io", "io [io.test]"} {
+			e := idx.packages[id]
+			if e == nil {
+				t.Fatalf("no entry for %s in the index", id)
+			}
+			fn(e)
+		}
+		idx.dirty = true
+		if err := idx.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const want = "case *panicReader:\ncase *foo.NopReader1:\ncase *io.LimitedReader:\ncase *io.PipeReader:\ncase *io.SectionReader:\ncase myReadWriter:\ncase foo.NopReader2:\ncase io.ReadCloser:\ncase io.ReadSeekCloser:\ncase io.ReadSeeker:\ncase io.ReadWriteCloser:\ncase io.ReadWriteSeeker:\ncase io.ReadWriter:\n}"
+	if got := fill(); got != want {
+		t.Errorf("without index: got %q, want %q", got, want)
+	}
+
+	// The implementations are taken from the index.
+	edit(func(e *indexEntry) {
+		for iface := range e.Impls {
+			e.Impls[iface] = []string{"PipeReader"}
+		}
+	})
+	if got, want := fill(), "case *panicReader:\ncase *foo.NopReader1:\ncase *io.PipeReader:\ncase myReadWriter:\ncase foo.NopReader2:\n}"; got != want {
+		t.Errorf("with index: got %q, want %q", got, want)
+	}
+
+	// A stale entry is rebuilt.
+	edit(func(e *indexEntry) { e.Key = "stale" })
+	if got := fill(); got != want {
+		t.Errorf("with stale index: got %q, want %q", got, want)
+	}
+}
+
+func TestSkipBodies(t *testing.T) {
+	parse := skipBodies("/p")
+	src := []byte("package p\n\nfunc f() { g() }\n")
+	for _, test := range []struct {
+		filename string
+		body     bool
+	}{
+		{filename: "/p/p.go", body: true},
+		{filename: "/q/q.go", body: false},
+	} {
+		f, err := parse(token.NewFileSet(), test.filename, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := f.Decls[0].(*ast.FuncDecl).Body != nil; body != test.body {
+			t.Errorf("%s: got body %v, want %v", test.filename, body, test.body)
+		}
+	}
+}
+
+func TestFillModified(t *testing.T) {
+	// The unsaved file only exists in the archive and uses
+	// the modified version of the other file of the package.
+	overlay, err := absOverlay(map[string][]byte{
+		"testdata/typeswitch_1/input.go":   []byte("package p\n\nimport \"go/ast\"\n\ntype decl = ast.Decl\n"),
+		"testdata/typeswitch_1/unsaved.go": []byte("package p\n\nfunc f(d decl) {\n\tswitch d.(type) {\n\t}\n}\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := filepath.Abs("testdata/typeswitch_1/unsaved.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overlay[path] == nil {
+		t.Fatalf("%s not in overlay", path)
+	}
+
+	prog, err := load(path, overlay, scopeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outs, err := byLine(context.Background(), prog, path, 4, options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The unsaved file does not import go/ast.
+	want := []output{
+		{Start: 48, End: 49, Code: "case *ast.BadDecl:\ncase *ast.FuncDecl:\ncase *ast.GenDecl:\n}"},
+		{Start: 9, End: 9, Code: "\n\nimport \"go/ast\""},
+	}
+	if !reflect.DeepEqual(outs, want) {
+		t.Errorf("got %+v, want %+v", outs, want)
+	}
+}
+
+func TestSearchPackages(t *testing.T) {
+	prog := &program{}
+	for i := 0; i < 100; i++ {
+		prog.all = append(prog.all, &packages.Package{Types: types.NewPackage("p", "p")})
+	}
+
+	var n int32
+	count := func(*packages.Package) { atomic.AddInt32(&n, 1) }
+	if err := searchPackages(context.Background(), prog, count); err != nil {
+		t.Fatal(err)
+	}
+	if n != 100 {
+		t.Errorf("got %d searched packages, want 100", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := searchPackages(ctx, prog, count); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	path := "/p.go"
+	overlay := map[string][]byte{
+		path:        []byte("package p\n\nfunc f() {\n\tswitch x {\n\t}\n}\n"),
+		"/other.go": []byte("package p\n"),
+	}
+	start := len("package p\n\nfunc f() {\n\t")
+	outs := []output{{Start: start, End: start + len("switch x {\n\t}"), Code: "switch x {\n\tcase a:\n\t}"}}
+
+	var buf bytes.Buffer
+	if err := writeArchive(&buf, path, overlay, outs); err != nil {
+		t.Fatal(err)
+	}
+	files, err := buildutil.ParseOverlayArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+	if got, want := string(files["/other.go"]), "package p\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := string(files[path]), "package p\n\nfunc f() {\n\tswitch x {\n\tcase a:\n\t}\n}\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	var src bytes.Buffer
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&src, "line %d\n", i)
+	}
+	src.WriteString("switch x {\n}")
+	at := func(s string) int { return strings.Index(src.String(), s) }
+
+	tests := [...]struct {
+		name string
+		outs []output
+		want string
+	}{
+		{
+			name: "no changes",
+			outs: []output{{Start: at("line 2"), End: at("line 2"), Code: ""}},
+		},
+		{
+			name: "merged hunk",
+			outs: []output{
+				{Start: at("line 2\n"), End: at("line 3"), Code: "line two\n"},
+				{Start: at("line 7"), End: at("line 8"), Code: ""},
+			},
+			want: `@@ -1,10 +1,9 @@
+ line 1
+-line 2
++line two
+ line 3
+ line 4
+ line 5
+ line 6
+-line 7
+ line 8
+ line 9
+ line 10
+`,
+		},
+		{
+			name: "separate hunks without newline at end of file",
+			outs: []output{
+				{Start: at("line 2\n"), End: at("line 2\n") + len("line 2"), Code: "line 2\nline 2.5"},
+				{Start: at("switch"), End: src.Len(), Code: "switch x {\ncase a:\n}"},
+			},
+			want: `@@ -1,5 +1,6 @@
+ line 1
+ line 2
++line 2.5
+ line 3
+ line 4
+ line 5
+@@ -19,4 +20,5 @@
+ line 19
+ line 20
+ switch x {
++case a:
+ }
+\ No newline at end of file
+`,
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := writeDiff(&buf, "p.go", src.Bytes(), test.outs); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		want := test.want
+		if want != "" {
+			want = "diff -u p.go.orig p.go\n--- p.go.orig\n+++ p.go\n" + want
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", test.name, got, want)
+		}
+	}
+}
+
+func TestIndentEdits(t *testing.T) {
+	src := []byte("func f() {\n\tswitch x {\n\t}\n}\n")
+	start := bytes.Index(src, []byte("switch"))
+	outs := indentEdits(src, []output{{Start: start, End: start + len("switch x {\n\t}"), Code: "switch x {\ncase a:\n\n\tf()\n}"}})
+	if got, want := outs[0].Code, "switch x {\n\tcase a:\n\n\t\tf()\n\t}"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTextEdits(t *testing.T) {
+	src := []byte("package p\n\n// ä😀\nfunc f() { /* ä😀 */ switch x {\n\t}\n}\n")
+	start := bytes.Index(src, []byte("switch"))
+	rbrace := bytes.Index(src, []byte("}\n}"))
+	edits, err := textEdits(src, []output{
+		{Start: start, End: start + len("switch"), Code: "switch"},
+		{Start: rbrace, End: rbrace + 1, Code: "case a:\n}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []textEdit{
+		{Range: lspRange{Start: lspPosition{Line: 3, Character: 21}, End: lspPosition{Line: 3, Character: 27}}, NewText: "switch"},
+		{Range: lspRange{Start: lspPosition{Line: 4, Character: 1}, End: lspPosition{Line: 4, Character: 2}}, NewText: "case a:\n\t}"},
+	}
+	if !reflect.DeepEqual(edits, want) {
+		t.Errorf("got %+v, want %+v", edits, want)
+	}
+
+	if _, err := textEdits(src, []output{{Start: 0, End: len(src) + 1}}); err == nil {
+		t.Error("expected an error for an offset beyond the end of the file")
+	}
+}
+
+func TestImportsEdit(t *testing.T) {
+	tests := [...]struct {
+		src   string
+		names map[string]string
+		want  string
+	}{
+		{
+			src:   "package p\n\nfunc f() {}\n",
+			names: map[string]string{"go/ast": "ast"},
+			want:  "package p\n\nimport \"go/ast\"\n\nfunc f() {}\n",
+		},
+		{
+			src:   "package p\n\nimport (\n\t\"io\"\n\n\t\"example.com/a\"\n)\n\n// f does nothing.\nfunc f() {}\n",
+			names: map[string]string{"os": "os", "example.com/b.v2": "b"},
+			want:  "package p\n\nimport (\n\t\"io\"\n\t\"os\"\n\n\t\"example.com/a\"\n\tb \"example.com/b.v2\"\n)\n\n// f does nothing.\nfunc f() {}\n",
+		},
+	}
+	for _, test := range tests {
+		out, err := importsEdit([]byte(test.src), test.names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := applyEdits([]byte(test.src), []output{out})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res) != test.want {
+			t.Errorf("got:\n%s\nwant:\n%s", res, test.want)
+		}
+	}
+}
+
+func TestSnippetEdits(t *testing.T) {
+	clauses := []ast.Stmt{
+		&ast.CaseClause{List: []ast.Expr{ast.NewIdent("A")}, Body: caseBody("A", bodySnippet)},
+		&ast.CaseClause{List: []ast.Expr{ast.NewIdent("B")}, Body: []ast.Stmt{&ast.ExprStmt{X: &ast.BasicLit{Kind: token.STRING, Value: `"$\\"`}}}},
+		&ast.CaseClause{List: []ast.Expr{ast.NewIdent("C")}, Body: caseBody("C", bodySnippet)},
+	}
+	out, err := prepareOutput(clauses, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outs := snippetEdits([]output{out})
+	if got, want := outs[0].Code, "case A:\n\t$1\ncase B:\n\t\"\\$\\\\\\\\\"\ncase C:\n\t$2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestInsertCases(t *testing.T) {
+	tests := [...]struct {
+		src, want string
+	}{
+		{
+			src:  "switch x {\n\tcase a: // keep\n\t\tf( )\n\t}",
+			want: "switch x {\n\tcase a: // keep\n\t\tf( )\n\tcase b:\n\t}",
+		},
+		{
+			src:  "switch x {}",
+			want: "switch x {\n\tcase b:\n\t}",
+		},
+		{
+			src:  "switch x { case a: }",
+			want: "switch x { case a: \n\tcase b:\n\t}",
+		},
+	}
+	for _, test := range tests {
+		src := "package p\n\nfunc f() {\n\t" + test.src + "\n}\n"
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		swtch := f.Decls[0].(*ast.FuncDecl).Body.List[0]
+		clause := &ast.CaseClause{List: []ast.Expr{ast.NewIdent("b")}}
+		outs, err := insertCases(fset, swtch, []ast.Stmt{clause}, insertEnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := applyEdits([]byte(src), indentEdits([]byte(src), outs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "package p\n\nfunc f() {\n\t" + test.want + "\n}\n"; string(res) != want {
+			t.Errorf("%q: got %q, want %q", test.src, res, want)
+		}
+	}
+}
+
+func TestInsertPositions(t *testing.T) {
+	const src = "package p\n\nfunc f() {\n\tswitch x {\n\tcase *b:\n\t\tf()\n\tcase d:\n\tdefault:\n\t}\n}\n"
+	tests := [...]struct {
+		insert, want string
+	}{
+		{
+			insert: insertEnd,
+			want:   "case *b:\n\t\tf()\n\tcase d:\n\tdefault:\n\tcase e:\n\tcase a:\n\tcase *c:\n\t}",
+		},
+		{
+			insert: insertDefault,
+			want:   "case *b:\n\t\tf()\n\tcase d:\n\tcase e:\n\tcase a:\n\tcase *c:\n\tdefault:\n\t}",
+		},
+		{
+			insert: insertSorted,
+			want:   "case a:\n\tcase *b:\n\t\tf()\n\tcase *c:\n\tcase d:\n\tcase e:\n\tdefault:\n\t}",
+		},
+	}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		swtch := f.Decls[0].(*ast.FuncDecl).Body.List[0]
+		var clauses []ast.Stmt
+		for _, name := range []string{"e", "a", "*c"} {
+			clauses = append(clauses, &ast.CaseClause{List: []ast.Expr{ast.NewIdent(name)}})
+		}
+		outs, err := insertCases(fset, swtch, clauses, test.insert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := applyEdits([]byte(src), indentEdits([]byte(src), outs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "package p\n\nfunc f() {\n\tswitch x {\n\t" + test.want + "\n}\n"; string(res) != want {
+			t.Errorf("%s: got %q, want %q", test.insert, res, want)
+		}
+
+		clauses = []ast.Stmt{&ast.CaseClause{List: []ast.Expr{ast.NewIdent("a")}}}
+		list := placeClauses(switchBody(swtch).List, clauses, test.insert)
+		var got []string
+		for _, cc := range list {
+			got = append(got, clauseKey(cc))
+		}
+		want := map[string]string{insertEnd: "b d  a", insertDefault: "b d a ", insertSorted: "a b d "}[test.insert]
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s: got clauses %q, want %q", test.insert, got, want)
+		}
+	}
+}
+
+func TestDefaultClause(t *testing.T) {
+	const src = `package p
+
+func f(x int) {
+	switch x {
+	}
+}
+
+func g(x int) (bool, error) {
+	switch x {
+	default:
+	}
+	return false, nil
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object), Types: make(map[ast.Expr]types.TypeAndValue)}
+	tpkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &packages.Package{Types: tpkg, TypesInfo: info, Syntax: []*ast.File{f}}
+	swtch := func(i int) ast.Stmt { return f.Decls[i].(*ast.FuncDecl).Body.List[0] }
+
+	if _, err := defaultClause(pkg, swtch(0), nil, defaultError); err != errNoErrorResult {
+		t.Errorf("f: got error %v, want %v", err, errNoErrorResult)
+	}
+	for _, dflt := range []string{defaultEmpty, defaultPanic, defaultError} {
+		if cc, err := defaultClause(pkg, swtch(1), nil, dflt); cc != nil || err != nil {
+			t.Errorf("g, %s: got %v, %v, want no clause for the existing default", dflt, cc, err)
+		}
+	}
+}
+
+func TestStaleCases(t *testing.T) {
+	src := `package p
+
+type Shape interface{ Area() int }
+
+type Square struct{}
+
+func (Square) Area() int { return 0 }
+
+type Circle struct{}
+
+type Color int
+
+const Red Color = 0
+
+func f(s Shape, c Color) {
+	switch s.(type) {
+	case Square, Circle:
+	case nil:
+	case Triangle:
+	}
+	switch c {
+	case Red, Blue:
+	case "x":
+	default:
+	}
+}`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "stale.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Error: func(error) {}}
+	pkg, _ := conf.Check(f.Name.Name, fset, []*ast.File{f}, &info)
+
+	var got []string
+	var swtchs []ast.Stmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt:
+			swtch := n.(ast.Stmt)
+			typ, _ := switchType(info, swtch)
+			stale := staleCases(pkg, info, swtch, typ)
+			for _, s := range stale {
+				got = append(got, types.ExprString(s.expr)+": "+s.reason)
+			}
+			deleteCases(swtch, stale)
+			swtchs = append(swtchs, swtch)
+		}
+		return true
+	})
+	want := []string{
+		"Circle: Circle does not implement Shape (missing method Area)",
+		"Triangle: undefined",
+		"Blue: undefined",
+		`"x": untyped string is not assignable to Color`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	for _, swtch := range swtchs {
+		if err := format.Node(&buf, token.NewFileSet(), swtch); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteByte('\n')
+	}
+	wantCode := "switch s.(type) {\ncase Square:\ncase nil:\n}\nswitch c {\ncase Red:\ndefault:\n}\n"
+	if buf.String() != wantCode {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), wantCode)
 	}
 }
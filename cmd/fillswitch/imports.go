@@ -0,0 +1,176 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+
+	"github.com/davidrjenni/reftools/fillswitch"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fileImports keeps track of the packages referred to by the code
+// generated for a file which the file does not import yet, such that
+// their imports can be added like goimports does.
+type fileImports struct {
+	pkg      *types.Package
+	qf       types.Qualifier   // see fillswitch.FileQualifier
+	imported map[string]bool   // the import paths of the file, except the blank imports
+	names    map[string]bool   // the names of the imports of the file and of the packages to import
+	missing  map[string]string // the names of the packages to import by import path
+}
+
+func newFileImports(f *ast.File, pkg *packages.Package) *fileImports {
+	imps := &fileImports{
+		pkg:      pkg.Types,
+		qf:       fillswitch.FileQualifier(f, pkg.Types, pkg.TypesInfo),
+		imported: make(map[string]bool),
+		names:    make(map[string]bool),
+		missing:  make(map[string]string),
+	}
+	for _, imp := range f.Imports {
+		if imp.Name != nil && imp.Name.Name == "_" {
+			continue
+		}
+		if path, err := strconv.Unquote(imp.Path.Value); err == nil {
+			imps.imported[path] = true
+		}
+		if imp.Name != nil {
+			imps.names[imp.Name.Name] = true
+		} else if pkgName, ok := pkg.TypesInfo.Implicits[imp].(*types.PkgName); ok {
+			imps.names[pkgName.Name()] = true
+		}
+	}
+	return imps
+}
+
+// qualifier qualifies p like the qualifier of the file and records p if
+// the file does not import it. Like goimports, such a package is imported
+// under a numbered name, e.g. rand2, if its name is taken by an import of
+// the file, another package to import or a declaration of the package.
+func (imps *fileImports) qualifier(p *types.Package) string {
+	if p == imps.pkg || imps.imported[p.Path()] {
+		return imps.qf(p)
+	}
+	if name, ok := imps.missing[p.Path()]; ok {
+		return name
+	}
+	name := p.Name()
+	for i := 2; imps.names[name] || imps.pkg.Scope().Lookup(name) != nil; i++ {
+		name = fmt.Sprintf("%s%d", p.Name(), i)
+	}
+	imps.names[name] = true
+	imps.missing[p.Path()] = name
+	return name
+}
+
+// addEdit returns outs, the edits of the file at path, with an edit
+// adding the imports of the packages referred to by their code which the
+// file does not import. The packages qualified but left out of the code,
+// e.g. of existing cases, are not imported.
+func (imps *fileImports) addEdit(prog *program, path string, outs []output) ([]output, error) {
+	used := make(map[string]bool) // the identifiers followed by a period
+	for _, out := range outs {
+		var s scanner.Scanner
+		fset := token.NewFileSet()
+		s.Init(fset.AddFile("", -1, len(out.Code)), []byte(out.Code), nil, 0)
+		prev := token.ILLEGAL
+		var lit string
+		for {
+			_, tok, l := s.Scan()
+			if tok == token.EOF {
+				break
+			}
+			if tok == token.PERIOD && prev == token.IDENT {
+				used[lit] = true
+			}
+			prev, lit = tok, l
+		}
+	}
+	names := make(map[string]string) // by import path
+	for p, name := range imps.missing {
+		if used[name] {
+			names[p] = name
+		}
+	}
+	if len(names) == 0 {
+		return outs, nil
+	}
+
+	src, err := readSource(path, prog.overlay)
+	if err != nil {
+		return nil, err
+	}
+	out, err := importsEdit(src, names)
+	if err != nil {
+		return nil, err
+	}
+	return append(outs, out), nil
+}
+
+// importsEdit returns the edit adding the imports of the packages with
+// the given names by import path to src. It replaces the imports and
+// whatever follows the package clause up to them with the code formatted
+// by astutil.AddNamedImport, which adds an import to the group of the
+// most similar import path. A package is imported under its name if it
+// differs from the last element of its path.
+func importsEdit(src []byte, names map[string]string) (output, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return output{}, err
+	}
+	start, end := importsRange(fset, f)
+
+	paths := make([]string, 0, len(names))
+	for p := range names {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		name := names[p]
+		if name == path.Base(p) {
+			name = ""
+		}
+		astutil.AddNamedImport(fset, f, name, p)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return output{}, err
+	}
+	// The comments after the imports are printed, too,
+	// but are left out of the edit.
+	fset = token.NewFileSet()
+	res, err := parser.ParseFile(fset, "", buf.Bytes(), parser.ImportsOnly)
+	if err != nil {
+		return output{}, err
+	}
+	resStart, resEnd := importsRange(fset, res)
+	return output{Start: start, End: end, Code: buf.String()[resStart:resEnd]}, nil
+}
+
+// importsRange returns the offsets of the end of the package clause of f
+// and of the end of its imports, or of the package clause if it has none.
+func importsRange(fset *token.FileSet, f *ast.File) (int, int) {
+	start := fset.Position(f.Name.End()).Offset
+	end := start
+	for _, decl := range f.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			end = fset.Position(d.End()).Offset
+		}
+	}
+	return start, end
+}
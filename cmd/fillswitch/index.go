@@ -0,0 +1,224 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// implIndex is an on-disk index of the types implementing the interfaces
+// of type switches, by package, which is reused across runs. The entry
+// of a package is valid as long as its key is unchanged: the version of
+// its module, or the names, sizes and modification times of its files,
+// together with the keys of its dependencies.
+type implIndex struct {
+	path    string
+	overlay map[string][]byte // the files replacing the ones on disk
+
+	mu       sync.Mutex
+	keys     map[*packages.Package]string // the keys of the packages of this run
+	packages map[string]*indexEntry       // the entries by package ID
+	dirty    bool                         // whether packages is to be saved
+}
+
+type indexEntry struct {
+	Key   string              `json:"key"`
+	Impls map[string][]string `json:"impls"` // the names of the implementations by interface, see interfaceKey
+}
+
+// openIndex reads the index at path. A missing or corrupt index is
+// rebuilt from scratch.
+func openIndex(path string, overlay map[string][]byte) (*implIndex, error) {
+	idx := &implIndex{
+		path:     path,
+		overlay:  overlay,
+		keys:     make(map[*packages.Package]string),
+		packages: make(map[string]*indexEntry),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &idx.packages); err != nil {
+		idx.packages = make(map[string]*indexEntry)
+	}
+	return idx, nil
+}
+
+// save writes the index to disk if it has changed. The file is replaced
+// atomically, such that concurrent runs see either index.
+func (idx *implIndex) save() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.dirty {
+		return nil
+	}
+	data, err := json.Marshal(idx.packages)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(idx.path), filepath.Base(idx.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), idx.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	idx.dirty = false
+	return nil
+}
+
+// implementations returns the type names declared in the scope of p whose
+// types or pointer types implement iface. They are taken from the index
+// if its entry for p is valid; otherwise they are added to it.
+func (idx *implIndex) implementations(p *packages.Package, iface *types.Interface) []types.Object {
+	ikey := interfaceKey(iface)
+	idx.mu.Lock()
+	key := idx.key(p)
+	e := idx.packages[p.ID]
+	names, ok := []string(nil), false
+	if e != nil && e.Key == key {
+		names, ok = e.Impls[ikey]
+	}
+	idx.mu.Unlock()
+
+	scope := p.Types.Scope()
+	if !ok {
+		names = []string{} // not nil in the JSON
+		for _, name := range scope.Names() {
+			tn, isType := scope.Lookup(name).(*types.TypeName)
+			if !isType || tn.IsAlias() {
+				continue
+			}
+			if t, isNamed := tn.Type().(*types.Named); isNamed && (types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface)) {
+				names = append(names, name)
+			}
+		}
+		idx.mu.Lock()
+		if e = idx.packages[p.ID]; e == nil || e.Key != key {
+			e = &indexEntry{Key: key, Impls: make(map[string][]string)}
+			idx.packages[p.ID] = e
+		}
+		e.Impls[ikey] = names
+		idx.dirty = true
+		idx.mu.Unlock()
+	}
+
+	var objs []types.Object
+	for _, name := range names {
+		if obj := scope.Lookup(name); obj != nil {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// key returns the key of the entry of p, which changes whenever p or one
+// of its dependencies may have changed. It must be called with idx.mu
+// held.
+func (idx *implIndex) key(p *packages.Package) string {
+	if k, ok := idx.keys[p]; ok {
+		return k
+	}
+	h := sha256.New()
+	if m := p.Module; m != nil && m.Version != "" && m.Replace == nil {
+		// The files of a module version do not change.
+		fmt.Fprintf(h, "%s@%s\n", m.Path, m.Version)
+	} else {
+		for _, name := range p.CompiledGoFiles {
+			if src, ok := idx.overlay[name]; ok {
+				fmt.Fprintf(h, "%s %x\n", name, sha256.Sum256(src))
+			} else if fi, err := os.Stat(name); err == nil {
+				fmt.Fprintf(h, "%s %d %d\n", name, fi.Size(), fi.ModTime().UnixNano())
+			} else {
+				fmt.Fprintf(h, "%s\n", name)
+			}
+		}
+	}
+	paths := make([]string, 0, len(p.Imports))
+	for path := range p.Imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s %s\n", path, idx.key(p.Imports[path]))
+	}
+	k := hex.EncodeToString(h.Sum(nil))
+	idx.keys[p] = k
+	return k
+}
+
+// interfaceKey returns the method set of iface as a string, with the
+// types qualified with the package paths.
+func interfaceKey(iface *types.Interface) string {
+	methods := make([]string, iface.NumMethods())
+	for i := range methods {
+		m := iface.Method(i)
+		methods[i] = m.Id() + " " + types.TypeString(m.Type(), nil)
+	}
+	return strings.Join(methods, "; ")
+}
+
+// findImplementations returns the candidates for the cases of a type
+// switch in pkg on a value of type iface: the types defined in pkg, and
+// the implementations of iface declared by the other packages of prog,
+// which are taken from the index.
+func findImplementations(ctx context.Context, prog *program, pkg *types.Package, iface *types.Interface, idx *implIndex) ([]types.Object, error) {
+	var (
+		mu   sync.Mutex
+		objs []types.Object
+	)
+	err := searchPackages(ctx, prog, func(p *packages.Package) {
+		if !searchable(pkg, p) {
+			return
+		}
+		var found []types.Object
+		if p.Types == pkg {
+			// The package of the switch is being edited.
+			for _, obj := range p.TypesInfo.Defs {
+				if tn, ok := obj.(*types.TypeName); ok {
+					found = append(found, tn)
+				}
+			}
+		} else {
+			found = idx.implementations(p, iface)
+		}
+		mu.Lock()
+		objs = append(objs, found...)
+		mu.Unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Scopes of the search for cases, selected with the -scope flag. Any
+// other scope is a comma-separated list of package patterns.
+const (
+	scopeDefault   = ""          // the package, its importers in the module and all dependencies
+	scopePackage   = "package"   // the package of the file
+	scopeModule    = "module"    // the packages of the module of the file
+	scopeWorkspace = "workspace" // the packages of the modules of the workspace
+)
+
+// program contains the package of the file to fill and the packages
+// in which the cases are searched.
+type program struct {
+	Fset    *token.FileSet
+	initial []*packages.Package // the loaded packages, including their test variants
+	all     []*packages.Package // the packages to search
+	deps    bool                // whether all dependencies are searched
+	overlay map[string][]byte   // the files replacing the ones on disk
+}
+
+// load loads the package containing the file at path and the packages
+// of the scope. The files in overlay replace the ones on disk.
+func load(path string, overlay map[string][]byte, scope string) (*program, error) {
+	cfg := &packages.Config{
+		Mode:    packages.LoadAllSyntax | packages.NeedModule,
+		Tests:   true,
+		Dir:     filepath.Dir(path),
+		Fset:    token.NewFileSet(),
+		Overlay: overlay,
+	}
+	patterns := []string{"file=" + path}
+	switch scope {
+	case scopeDefault:
+		pkg, err := containingPackage(path, overlay)
+		if err != nil {
+			return nil, err
+		}
+		rev, err := reverseDeps(pkg, overlay)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, rev...)
+	case scopePackage:
+		// Only a test file can use the types of the tests.
+		cfg.Tests = strings.HasSuffix(path, "_test.go")
+		cfg.ParseFile = skipBodies(filepath.Dir(path))
+	case scopeModule:
+		pkg, err := containingPackage(path, overlay)
+		if err != nil {
+			return nil, err
+		}
+		if pkg == nil || pkg.Module == nil || pkg.Module.Dir == "" {
+			return nil, fmt.Errorf("%s is not in a module", path)
+		}
+		cfg.Dir = pkg.Module.Dir
+		patterns = append(patterns, "./...")
+	case scopeWorkspace:
+		patterns = append(patterns, "work")
+	default:
+		// Relative patterns are relative to the working directory.
+		cfg.Dir = ""
+		patterns = append(patterns, strings.Split(scope, ",")...)
+	}
+
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var initial []*packages.Package
+	for _, pkg := range pkgs {
+		// Skip the generated test main packages.
+		if !strings.HasSuffix(pkg.ID, ".test") {
+			initial = append(initial, pkg)
+		}
+	}
+
+	prog := &program{Fset: cfg.Fset, initial: initial, deps: scope == scopeDefault, overlay: overlay}
+	if !prog.deps {
+		prog.all = initial
+		return prog, nil
+	}
+	packages.Visit(initial, nil, func(pkg *packages.Package) {
+		prog.all = append(prog.all, pkg)
+	})
+	return prog, nil
+}
+
+// skipBodies returns a parser for packages.Config which drops the function
+// bodies of the files outside of dir, as only the types declared by the
+// dependencies of the package in dir are needed. This saves most of the
+// work of type-checking the dependencies.
+func skipBodies(dir string) func(*token.FileSet, string, []byte) (*ast.File, error) {
+	return func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		f, err := parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+		if f != nil && filepath.Dir(filename) != dir {
+			for _, decl := range f.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok {
+					fn.Body = nil
+				}
+			}
+		}
+		return f, err
+	}
+}
+
+// containingPackage returns the package containing the file at path,
+// with its name, files and module, or nil if there is none.
+func containingPackage(path string, overlay map[string][]byte) (*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedModule,
+		Tests:   true,
+		Dir:     filepath.Dir(path),
+		Overlay: overlay,
+	}
+	pkgs, err := packages.Load(cfg, "file="+path)
+	if err != nil || len(pkgs) == 0 {
+		return nil, err
+	}
+	return pkgs[0], nil
+}
+
+// reverseDeps returns the import paths of the packages in the module of
+// pkg which import it, directly or indirectly, including through their
+// tests. Outside of a module, it returns none.
+func reverseDeps(pkg *packages.Package, overlay map[string][]byte) ([]string, error) {
+	if pkg == nil || pkg.Module == nil || pkg.Module.Dir == "" {
+		return nil, nil
+	}
+	root := pkg.PkgPath
+	if t := forTest(pkg); t != "" {
+		root = t
+	}
+
+	cfg := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedImports,
+		Tests:   true,
+		Dir:     pkg.Module.Dir,
+		Overlay: overlay,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, err
+	}
+
+	importers := make(map[string][]*packages.Package)
+	for _, pkg := range pkgs {
+		// Skip the generated test main packages.
+		if strings.HasSuffix(pkg.ID, ".test") {
+			continue
+		}
+		for _, imp := range pkg.Imports {
+			importers[imp.PkgPath] = append(importers[imp.PkgPath], pkg)
+		}
+	}
+
+	seen := map[string]bool{root: true}
+	loaded := map[string]bool{root: true}
+	work := []string{root}
+	var rev []string
+	for len(work) > 0 {
+		imp := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, pkg := range importers[imp] {
+			if !seen[pkg.PkgPath] {
+				seen[pkg.PkgPath] = true
+				work = append(work, pkg.PkgPath)
+			}
+			// The tests are loaded with the package under test.
+			p := pkg.PkgPath
+			if t := forTest(pkg); t != "" {
+				p = t
+			}
+			if !loaded[p] {
+				loaded[p] = true
+				rev = append(rev, p)
+			}
+		}
+	}
+	sort.Strings(rev)
+	return rev, nil
+}
+
+// file returns the syntax tree of the file at path and its package. The
+// file is looked up in the package without its test files if possible,
+// e.g. if the file is not a test file.
+func (prog *program) file(path string) (*ast.File, *packages.Package) {
+	var file *ast.File
+	var pkg *packages.Package
+	for _, p := range prog.initial {
+		for _, f := range p.Syntax {
+			if prog.Fset.File(f.Pos()).Name() != path {
+				continue
+			}
+			if pkg == nil || forTest(pkg) != "" && forTest(p) == "" {
+				file, pkg = f, p
+			}
+		}
+	}
+	return file, pkg
+}
+
+// forTest returns the import path of the package whose tests pkg is
+// built for, e.g. "p" for "p [p.test]" and "q [p.test]", or "" if pkg
+// is not a test variant.
+func forTest(pkg *packages.Package) string {
+	i := strings.Index(pkg.ID, " [")
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSuffix(pkg.ID[i+len(" ["):], ".test]")
+}
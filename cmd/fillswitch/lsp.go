@@ -0,0 +1,84 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// textEdit is the TextEdit of the Language Server Protocol.
+type textEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+// lspPosition is a position in a file as in the Language Server Protocol:
+// the zero-based line and the offset in UTF-16 code units in that line.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// writeTextEdits writes the edits in outs of the file at path to w as a
+// JSON list of LSP text edits. As clients apply them as is, the code is
+// indented like the line on which the edit starts.
+func writeTextEdits(w io.Writer, path string, overlay map[string][]byte, outs []output) error {
+	src, err := readSource(path, overlay)
+	if err != nil {
+		return err
+	}
+	edits, err := textEdits(src, outs)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(edits)
+}
+
+// textEdits converts the edits in outs of src to LSP text edits.
+func textEdits(src []byte, outs []output) ([]textEdit, error) {
+	edits := make([]textEdit, 0, len(outs))
+	for _, out := range indentEdits(src, outs) {
+		if out.Start > out.End {
+			return nil, fmt.Errorf("invalid edit [%d, %d)", out.Start, out.End)
+		}
+		start, err := lspPos(src, out.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := lspPos(src, out.End)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, textEdit{Range: lspRange{Start: start, End: end}, NewText: out.Code})
+	}
+	return edits, nil
+}
+
+// lspPos returns the LSP position of the byte offset in src.
+func lspPos(src []byte, offset int) (lspPosition, error) {
+	if offset < 0 || offset > len(src) {
+		return lspPosition{}, fmt.Errorf("invalid offset %d", offset)
+	}
+	lineStart := bytes.LastIndexByte(src[:offset], '\n') + 1
+	var char int
+	for line := src[lineStart:offset]; len(line) > 0; {
+		r, size := utf8.DecodeRune(line)
+		char++
+		if r >= 0x10000 {
+			char++ // a surrogate pair
+		}
+		line = line[size:]
+	}
+	return lspPosition{Line: bytes.Count(src[:lineStart], []byte("\n")), Character: char}, nil
+}
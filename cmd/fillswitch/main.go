@@ -54,101 +54,454 @@
 //
 // after applying fillswitch for the (type) switch statements.
 //
+// The cases are searched in the package of the file, its dependencies,
+// including the ones in other modules, and the packages of its module
+// which import it, including their tests. A test file is filled with
+// the types declared in the tests of its package, too.
+//
+// The types and values of other packages are qualified with the names
+// under which the file imports them, e.g. goast.Ident for the import
+// goast "go/ast", and are not qualified for dot imports. If the cases
+// refer to packages which the file does not import yet, their imports
+// are added like goimports does, by an additional edit replacing the
+// imports of the file. A package whose name is already taken, e.g. by an
+// import of another package with the same name, is imported under a
+// numbered name, e.g. rand2 "math/rand/v2".
+//
+// With -scope, the cases are only searched in the package of the file
+// (package), the packages of its module (module), the packages of the
+// modules of the go.work file (workspace), or the packages matching the
+// given comma-separated patterns, e.g. -scope=./...,example.com/api/...
+// Relative patterns are relative to the working directory. External
+// test packages and commands are not searched, except for the package
+// of the file.
+//
+// With -scope=package, only the types declared by the dependencies are
+// type-checked, without their function bodies, and the tests are only
+// loaded for a test file. This is much faster and exactly right for
+// sealed interfaces, i.e. interfaces with unexported methods, which only
+// the types of their package can implement. -reachable then only takes
+// the conversions in the package of the file into account.
+//
+// With -index=<file>, the implementations of the interfaces of type
+// switches found in the other packages are kept in an index in the file
+// and reused by later runs instead of checking every type of these
+// packages again. The entry of a package is rebuilt whenever its module
+// version, or the names, sizes or modification times of its files, or the
+// entry of one of its dependencies change. Entries are never removed, so
+// the file grows with every package seen; delete it to start over. By
+// default, no index is used.
+//
+// A switch on a value gets a case for each constant and variable of its
+// type, e.g. for each sentinel error of a switch on an error. If the type
+// is an enum, i.e. a named integer or string type with constants, e.g.
+// type Kind int with const ( Small Kind = iota; Large ), only its
+// constants are added, from all files of their package. Of several
+// constants with the same value, only the first declared one is added,
+// and none whose value is already the one of a case.
+//
+// A type switch on a value of a type parameter, e.g. switch any(v).(type),
+// gets a case for each term of the union of its constraint instead, e.g.
+// int, string and MyType for ~int | ~string | MyType. A term ~T gets a
+// case for T. If the constraint has no union, the cases are the types
+// implementing its methods. Likewise, a switch whose static type is an
+// interface with a union, e.g. switch v.(type) on the value of the type
+// parameter itself, gets a case for each term of the union, without
+// searching the program for implementations. Such a switch only compiles
+// once the value is converted to an interface, e.g. any(v).
+//
+// The generated cases are sorted by -order: alphabetically by their names
+// qualified with the import paths (alpha, the default), in declaration
+// order (decl), or alphabetically grouped by the declaring package (pkg).
+// With decl and pkg, the predeclared types come first, followed by the
+// package of the file and the other packages by import path. The cases
+// for the terms of a union are always in the order of the union.
+//
+// By default, the new cases are inserted after the existing ones. With
+// -insert=before-default, they are inserted before the default clause
+// instead. With -insert=sorted, they are sorted by their expressions as
+// written, ignoring a leading *, instead of by -order, and each one is
+// inserted before the first existing case which follows it, or after the
+// last existing case. This keeps the cases of a sorted switch sorted.
+// The cases of -errors-switch are placed likewise.
+//
+// A type implementing the interface only with pointer receivers gets a
+// case for its pointer type *T. If the methods have value receivers, both
+// T and *T implement it, and -receivers selects the cases: T (value, the
+// default), always *T (ptr), *T if T has other methods with pointer
+// receivers, as its values are then usually pointers, and T otherwise
+// (auto), or both.
+//
+// A type switch on a sealed interface, i.e. an interface with an
+// unexported method such as ast.Expr, gets a case for each type of the
+// package declaring the method which implements it, regardless of
+// -scope, as no other package can declare one. Types of other packages
+// which only implement it by embedding one of these types are left out.
+// Unexported types of another package cannot be named in a case and are
+// left out, too; since the cases are then not exhaustive, the switch gets
+// an empty default clause unless it has one or -default adds another.
+//
+// With -include and -exclude, only a subset of the cases is generated,
+// e.g. to leave out mocks, test helpers or whole packages. A case is
+// matched by its name, e.g. MockShape, or by its name qualified with the
+// package path, e.g. example.com/internal/mocks.MockShape. With -include,
+// only the matching cases are generated; the ones matching -exclude are
+// not.
+//
+// With -default, a default clause is added to a switch without one: an
+// empty one, one which panics with the unexpected type or value, e.g.
+// panic(fmt.Sprintf("unexpected type %T", v)), or one which returns it as
+// an error, e.g. return nil, fmt.Errorf("unexpected type %T", v), with the
+// zero values for the other results of the function. The import of fmt is
+// added if the file lacks it.
+//
 // Usage:
 //
 // 	% fillstruct [-modified] -file=<filename> -offset=<byte offset> -line=<line number>
 //
 // Flags:
 //
-// -file:     filename
+// -file:      filename
+//
+// -modified:  read an archive of modified files from stdin
+//
+// -offset:    byte offset of the (type) switch, optional if -line is present
+//
+// -line:      line number of the (type) switch, optional if -offset is present
+//
+// -reachable: only add cases for types whose values are converted to an interface somewhere in the program
+//
+// -body:      body of the generated cases: empty, todo-named (panic with the name of the case) or snippet (a numbered LSP snippet tab stop)
+//
+// -receivers: cases for types implementing the interface with value receivers: value (T), ptr (*T), auto (*T if T has methods with pointer receivers) or both
+//
+// -include:   only generate the cases whose name or name qualified with the package path, e.g. example.com/shapes.Square, matches the regular expression
+//
+// -exclude:   do not generate the cases whose name or name qualified with the package path, e.g. example.com/mocks.MockShape, matches the regular expression
+//
+// -default:   default clause to add if there is none: none, empty, panic (panic with the unexpected type or value) or error (return it as an error)
+//
+// -archive:   print the file with the filled switch statement, and the other modified files, as an archive instead of the edits
 //
-// -modified: read an archive of modified files from stdin
+// -lsp:       print the edits as LSP TextEdits with line and UTF-16 character positions
 //
-// -offset:   byte offset of the (type) switch, optional if -line is present
+// -errors-as: fill the errors.As if-else chain at the selection with a clause for every exported error type of the packages imported by the file
 //
-// -line:     line number of the (type) switch, optional if -offset is present
+// -errors-switch: rewrite the switch on an error at the selection as a switch with an errors.Is or errors.As case for every sentinel error and error type
+//
+// -ctx-done:  add a case receiving from ctx.Done() to a select statement, where ctx is the innermost context.Context in scope
+//
+// -verify:    report the cases of all switches in the file whose types do not implement the switched interface anymore or whose values are undefined
+//
+// -delete:    with -verify, print the edits deleting the reported cases instead
+//
+// -w:         write the result to the file instead of printing the edits
+//
+// -d:         print the changes to the file as a unified diff instead of the edits
+//
+// -scope:     where to search for cases: package, module, workspace or comma-separated package patterns; by default the package, its importers in the module and all dependencies
+//
+// -order:     order of the generated cases: alpha (alphabetical), decl (declaration order) or pkg (alphabetical, grouped by package)
+//
+// -index:     file of the index of the implementations of interfaces, which is reused across runs and never shrinks; none by default
+//
+// -insert:    where to insert the new cases: end (after the existing cases), before-default (before the default clause) or sorted (interleaved with the existing cases in alphabetical order)
+//
+// Like gofmt, -w writes the filled switch statements to the file, while
+// -d prints the changes as a unified diff; both can be combined. The
+// generated code is indented like the switch statement. They cannot be
+// combined with -archive.
+//
+// With -modified, the files of the archive read from stdin replace the
+// ones on disk, e.g. the unsaved buffers of an editor. The archive holds
+// the name and the size in bytes of each file, followed by its content.
+// The file to fill may also be a new file which only exists in the archive.
+//
+// With -lsp, the edits are printed as a JSON list of TextEdits of the
+// Language Server Protocol, e.g. {"range": {"start": {"line": 9,
+// "character": 1}, "end": ...}, "newText": ...}, with zero-based lines
+// and characters counted in UTF-16 code units, such that language server
+// shims can pass them on as is. Unlike the default edits, the code is
+// indented like the switch statement.
+//
+// With -body=snippet, the code of the edits is an LSP snippet: the body of
+// every generated case is a numbered tab stop, $1, $2, ..., such that the
+// user can jump from case to case after applying the edit, and the other
+// occurrences of $, } and \ are escaped. It cannot be combined with -w,
+// -d or -archive.
+//
+// With -archive, the whole updated file is printed in the archive format
+// read by -modified, together with the other modified files read from
+// stdin. This allows chaining tools, e.g. fillswitch and fillstruct, on
+// unsaved buffers.
+//
+// With -errors-as, the selection is an if statement whose condition is a
+// call of errors.As, e.g. if errors.As(err, &perr) {}. The chain of if-else
+// statements it belongs to is extended with an else-if clause for every
+// exported error type of the packages imported by the file which is not
+// handled yet. The targets of the new clauses are declared before the
+// chain, e.g. var linkErr *os.LinkError, or, if the chain continues an if
+// statement, e.g. if err == nil {} else if errors.As(err, &perr) {},
+// before that statement in a second edit.
+//
+// With -errors-switch, the selected switch on an error variable is
+// rewritten as a switch without tag, e.g. switch e := err.(type) with
+// case *os.PathError becomes switch with case errors.As(err, &pathErr),
+// and case io.EOF of switch err becomes case errors.Is(err, io.EOF). The
+// bodies of the cases are kept, with the variable declared in the type
+// switch replaced by the target. An errors.Is case is added for every
+// sentinel error, i.e. variable of type error, and an errors.As case for
+// every error type which is not handled yet: without -scope, the ones of
+// the package and of the packages imported by the file, otherwise the
+// ones of the scope. A switch without tag with such cases is filled the
+// same way. The targets are declared before the switch.
+//
+// A select statement gets a case for each channel which none of its cases
+// uses yet: the variables in scope of a channel type, e.g. parameters, and
+// the channel fields of the receiver of the enclosing method, e.g. s.quit.
+// A send-only channel gets a case sending the zero value of its element
+// type, e.g. case out <- 0, the others a receiving case, e.g. case <-in.
+// With -ctx-done, a case <-ctx.Done() is added, too, with ctx being the
+// innermost variable of type context.Context in scope.
+//
+// With -verify, neither -offset nor -line is needed. Instead of filling a
+// switch, fillswitch checks the cases of all (type) switches in the file,
+// the reverse direction of filling: it reports the types of type switch
+// cases which do not implement the switched interface anymore, and the
+// values of switch cases which are undefined, e.g. removed constants, or
+// have another type, one per line, and exits with status 1 if there are
+// any. With -delete, the edits removing these cases are printed instead.
+//
+// The offset can be anywhere from the indentation of the switch keyword
+// up to the end of the closing brace, including blank lines of the body.
+// Of nested switches, the innermost one is filled.
 //
 // If -offset as well as -line are present, then the tool first uses the
 // more specific offset information. If there was no (type) switch found
 // at the given offset, then the line information is used.
 //
+// Only the missing cases are added to a switch: the existing cases, their
+// bodies, comments and order are left untouched. Instead of the whole
+// switch statement, the edit replaces its closing brace with the new cases
+// followed by the closing brace on a line of its own.
+//
 package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"go/ast"
-	"go/build"
 	"go/format"
-	"go/parser"
 	"go/token"
 	"go/types"
-	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"strings"
 
-	"golang.org/x/tools/go/ast/astutil"
+	"github.com/davidrjenni/reftools/fillswitch"
 	"golang.org/x/tools/go/buildutil"
-	"golang.org/x/tools/go/loader"
-	"golang.org/x/tools/refactor/importgraph"
+	"golang.org/x/tools/go/packages"
 )
 
-var errNotFound = errors.New("no switch statement found")
+var (
+	errNotFound = errors.New("no switch statement found")
+	errNoTag    = errors.New("cannot fill a switch statement without a tag")
+)
 
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("fillswitch: ")
 
 	var (
-		filename = flag.String("file", "", "filename")
-		modified = flag.Bool("modified", false, "read an archive of modified files from stdin")
-		offset   = flag.Int("offset", 0, "byte offset of the (type) switch, optional if -line is present")
-		line     = flag.Int("line", 0, "line number of the (type) switch, optional if -offset is present")
+		filename  = flag.String("file", "", "filename")
+		modified  = flag.Bool("modified", false, "read an archive of modified files from stdin")
+		offset    = flag.Int("offset", 0, "byte offset of the (type) switch, optional if -line is present")
+		line      = flag.Int("line", 0, "line number of the (type) switch, optional if -offset is present")
+		reachable = flag.Bool("reachable", false, "only add cases for types whose values are converted to an interface somewhere in the program")
+		body      = flag.String("body", bodyEmpty, "body of the generated cases: empty, todo-named (panic with the name of the case) or snippet (a numbered LSP snippet tab stop)")
+		receivers = flag.String("receivers", fillswitch.ReceiversValue, "cases for types implementing the interface with value receivers: value (T), ptr (*T), auto (*T if T has methods with pointer receivers) or both")
+		include   = flag.String("include", "", "only generate the cases whose name or name qualified with the package path, e.g. example.com/shapes.Square, matches the regular expression")
+		exclude   = flag.String("exclude", "", "do not generate the cases whose name or name qualified with the package path, e.g. example.com/mocks.MockShape, matches the regular expression")
+		dflt      = flag.String("default", defaultNone, "default clause to add if there is none: none, empty, panic (panic with the unexpected type or value) or error (return it as an error)")
+		archive   = flag.Bool("archive", false, "print the file with the filled switch statement, and the other modified files, as an archive instead of the edits")
+		lsp       = flag.Bool("lsp", false, "print the edits as LSP TextEdits with line and UTF-16 character positions")
+		errorsAs  = flag.Bool("errors-as", false, "fill the errors.As if-else chain at the selection with a clause for every exported error type of the packages imported by the file")
+		errSwitch = flag.Bool("errors-switch", false, "rewrite the switch on an error at the selection as a switch with an errors.Is or errors.As case for every sentinel error and error type")
+		ctxDone   = flag.Bool("ctx-done", false, "add a case receiving from ctx.Done() to a select statement, where ctx is the innermost context.Context in scope")
+		verify    = flag.Bool("verify", false, "report the cases of all switches in the file whose types do not implement the switched interface anymore or whose values are undefined")
+		del       = flag.Bool("delete", false, "with -verify, print the edits deleting the reported cases instead")
+		write     = flag.Bool("w", false, "write the result to the file instead of printing the edits")
+		diff      = flag.Bool("d", false, "print the changes to the file as a unified diff instead of the edits")
+		scope     = flag.String("scope", scopeDefault, "where to search for cases: package, module, workspace or comma-separated package patterns; by default the package, its importers in the module and all dependencies")
+		order     = flag.String("order", fillswitch.OrderAlpha, "order of the generated cases: alpha (alphabetical), decl (declaration order) or pkg (alphabetical, grouped by package)")
+		index     = flag.String("index", "", "file of the index of the implementations of interfaces, which is reused across runs and never shrinks; none by default")
+		insert    = flag.String("insert", insertEnd, "where to insert the new cases: end (after the existing cases), before-default (before the default clause) or sorted (interleaved with the existing cases in alphabetical order)")
 	)
 	flag.Parse()
 
-	if (*offset == 0 && *line == 0) || *filename == "" {
+	if (*offset == 0 && *line == 0 && !*verify) || *filename == "" {
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
+	if *del && !*verify {
+		log.Fatal("-delete requires -verify")
+	}
+	if *errorsAs && *errSwitch {
+		log.Fatal("-errors-as cannot be combined with -errors-switch")
+	}
+	if *archive && (*write || *diff) {
+		log.Fatal("-archive cannot be combined with -w or -d")
+	}
+	if *lsp && (*archive || *write || *diff) {
+		log.Fatal("-lsp cannot be combined with -archive, -w or -d")
+	}
+	if *body == bodySnippet && (*archive || *write || *diff) {
+		log.Fatal("-body=snippet cannot be combined with -archive, -w or -d")
+	}
+	if *body != bodyEmpty && *body != bodyTodoNamed && *body != bodySnippet {
+		log.Fatalf("invalid body %q", *body)
+	}
+	if !validDefault(*dflt) {
+		log.Fatalf("invalid default %q", *dflt)
+	}
+	if !validReceivers(*receivers) {
+		log.Fatalf("invalid receivers %q", *receivers)
+	}
+	if !validOrder(*order) {
+		log.Fatalf("invalid order %q", *order)
+	}
+	if !validInsert(*insert) {
+		log.Fatalf("invalid insert %q", *insert)
+	}
+
+	var (
+		overlay map[string][]byte
+		err     error
+	)
+	if *modified {
+		overlay, err = buildutil.ParseOverlayArchive(os.Stdin)
+		if err != nil {
+			log.Fatalf("invalid archive: %v", err)
+		}
+		if overlay, err = absOverlay(overlay); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	path, err := absPath(*filename)
+	if os.IsNotExist(err) {
+		// The file may be an unsaved buffer only present in the archive.
+		if p, _ := filepath.Abs(*filename); overlay[p] != nil {
+			path, err = p, nil
+		}
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	lprog, err := load(path, *modified)
+	prog, err := load(path, overlay, *scope)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if *offset > 0 {
-		err = byOffset(lprog, path, *offset, os.Stdout)
-		switch err {
-		case nil:
-			return
-		case errNotFound:
-			// try using line information
-		default:
+	if *verify {
+		outs, found, err := verifyFile(os.Stdout, prog, path, *del)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *del {
+			err = emit(path, overlay, outs, *archive, *lsp, *write, *diff)
+		} else if found {
+			os.Exit(1)
+		}
+		if err != nil {
 			log.Fatal(err)
 		}
+		return
 	}
 
-	if *line > 0 {
-		err = byLine(lprog, path, *line, os.Stdout)
-		switch err {
-		case nil:
-			return
-		default:
+	includeRE, err := compileFilter(*include)
+	if err != nil {
+		log.Fatalf("invalid -include: %v", err)
+	}
+	excludeRE, err := compileFilter(*exclude)
+	if err != nil {
+		log.Fatalf("invalid -exclude: %v", err)
+	}
+
+	opts := options{body: *body, order: *order, dflt: *dflt, receivers: *receivers, include: includeRE, exclude: excludeRE, errorsAs: *errorsAs, errorsSwitch: *errSwitch, ctxDone: *ctxDone, insert: *insert}
+	if *reachable {
+		var illTyped []string
+		opts.reach, illTyped = buildReachability(prog)
+		if len(illTyped) > 0 {
+			log.Printf("-reachable adds all the types of packages with errors: %s", strings.Join(illTyped, ", "))
+		}
+	}
+	if *index != "" {
+		if opts.index, err = openIndex(*index, overlay); err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	log.Fatal(errNotFound)
+	// Stop searching for candidates on interrupt.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	outs, err := fillAt(ctx, prog, path, *offset, *line, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *body == bodySnippet {
+		outs = snippetEdits(outs)
+	}
+	if opts.index != nil {
+		// The index only saves time.
+		if err := opts.index.save(); err != nil {
+			log.Printf("could not save the index: %v", err)
+		}
+	}
+
+	if err := emit(path, overlay, outs, *archive, *lsp, *write, *diff); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// emit prints the edits of the file at path as JSON or, with lsp, as LSP
+// text edits or, with archive, the updated file and the other modified
+// files as an archive. With diff, the changes are printed as a unified
+// diff instead and, with write, the edits are applied to the file, like
+// gofmt -d and -w.
+func emit(path string, overlay map[string][]byte, outs []output, archive, lsp, write, diff bool) error {
+	if archive {
+		return writeArchive(os.Stdout, path, overlay, outs)
+	}
+	if lsp {
+		return writeTextEdits(os.Stdout, path, overlay, outs)
+	}
+	if !write && !diff {
+		return json.NewEncoder(os.Stdout).Encode(outs)
+	}
+	src, err := readSource(path, overlay)
+	if err != nil {
+		return err
+	}
+	outs = indentEdits(src, outs)
+	if diff {
+		if err := writeDiff(os.Stdout, path, src, outs); err != nil {
+			return err
+		}
+	}
+	if write {
+		return writeFile(path, overlay, outs)
+	}
+	return nil
 }
 
 func absPath(filename string) (string, error) {
@@ -159,187 +512,286 @@ func absPath(filename string) (string, error) {
 	return filepath.Abs(eval)
 }
 
-func load(path string, modified bool) (*loader.Program, error) {
-	ctx := &build.Default
-	if modified {
-		archive, err := buildutil.ParseOverlayArchive(os.Stdin)
-		if err != nil {
-			return nil, err
-		}
-		ctx = buildutil.OverlayContext(ctx, archive)
+// compileFilter compiles the regular expression of -include or -exclude.
+// It returns nil for an empty expression.
+func compileFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
 	}
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil, err
-	}
-	pkg, err := buildutil.ContainingPackage(ctx, cwd, path)
-	if err != nil {
-		return nil, err
+	return regexp.Compile(expr)
+}
+
+func validReceivers(receivers string) bool {
+	switch receivers {
+	case fillswitch.ReceiversAuto, fillswitch.ReceiversPtr, fillswitch.ReceiversValue, fillswitch.ReceiversBoth:
+		return true
 	}
+	return false
+}
 
-	conf := &loader.Config{Build: ctx}
-	allowErrors(conf)
-	conf.ImportWithTests(pkg.ImportPath)
+func validInsert(insert string) bool {
+	switch insert {
+	case insertEnd, insertDefault, insertSorted:
+		return true
+	}
+	return false
+}
 
-	_, rev, _ := importgraph.Build(ctx)
-	for p := range rev.Search(pkg.ImportPath) {
-		conf.ImportWithTests(p)
+func validOrder(order string) bool {
+	switch order {
+	case fillswitch.OrderAlpha, fillswitch.OrderDecl, fillswitch.OrderPkg:
+		return true
 	}
-	return conf.Load()
+	return false
 }
 
-func allowErrors(lconf *loader.Config) {
-	ctxt := *lconf.Build
-	ctxt.CgoEnabled = false
-	lconf.Build = &ctxt
-	lconf.AllowErrors = true
-	lconf.ParserMode = parser.AllErrors
-	lconf.TypeChecker.Error = func(error) {}
+// absOverlay returns overlay with absolute file names, as expected by
+// go/packages and used for the file to fill. The symbolic links of the
+// files which exist on disk are evaluated.
+func absOverlay(overlay map[string][]byte) (map[string][]byte, error) {
+	abs := make(map[string][]byte, len(overlay))
+	for name, content := range overlay {
+		path, err := absPath(name)
+		if os.IsNotExist(err) {
+			path, err = filepath.Abs(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		abs[path] = content
+	}
+	return abs, nil
 }
 
-func byOffset(lprog *loader.Program, path string, offset int, dst io.Writer) error {
-	f, pkg, pos, err := findPos(lprog, path, offset)
-	if err != nil {
-		return err
+// fillAt fills the (type) switch at the offset or, if there is none,
+// the ones spanning the line. A zero offset or line is not used.
+func fillAt(ctx context.Context, prog *program, path string, offset, line int, opts options) ([]output, error) {
+	err := errNotFound
+	if offset > 0 {
+		var outs []output
+		outs, err = byOffset(ctx, prog, path, offset, opts)
+		if err != errNotFound {
+			return outs, err
+		}
+	}
+	if line > 0 {
+		return byLine(ctx, prog, path, line, opts)
 	}
+	return nil, err
+}
 
-	swtch, typ, err := findSwitchStmt(f, pkg.Info, pos)
+func byOffset(ctx context.Context, prog *program, path string, offset int, opts options) ([]output, error) {
+	f, pkg, pos, err := findPos(prog, path, offset)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	start := lprog.Fset.Position(swtch.Pos()).Offset
-	end := lprog.Fset.Position(swtch.End()).Offset
+	if opts.errorsAs {
+		head, err := findErrorsAs(f, *pkg.TypesInfo, pos, pos)
+		if err != nil {
+			return nil, err
+		}
+		return errorsAsEdits(prog, pkg, f, head, opts)
+	}
 
-	newSwtch := fillSwitch(pkg, lprog, swtch, typ)
-	out, err := prepareOutput(newSwtch, start, end)
+	swtch, typ, err := findSwitchStmt(prog.Fset, f, *pkg.TypesInfo, pos)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return json.NewEncoder(dst).Encode([]output{out})
-}
 
-func findPos(lprog *loader.Program, path string, offset int) (*ast.File, *loader.PackageInfo, token.Pos, error) {
-	for _, pkg := range lprog.InitialPackages() {
-		for _, f := range pkg.Files {
-			if file := lprog.Fset.File(f.Pos()); file.Name() == path {
-				if offset > file.Size() {
-					return nil, nil, 0,
-						fmt.Errorf("file size (%d) is smaller than given offset (%d)", file.Size(), offset)
-				}
-				return f, pkg, file.Pos(offset), nil
-			}
-		}
+	imps := newFileImports(f, pkg)
+	outs, err := fillEdits(ctx, prog, pkg, f, swtch, typ, imps.qualifier, opts)
+	if err != nil {
+		return nil, err
 	}
-	return nil, nil, 0, fmt.Errorf("could not find file %q", path)
+	return imps.addEdit(prog, path, outs)
 }
 
-func findSwitchStmt(f *ast.File, info types.Info, pos token.Pos) (ast.Stmt, types.Type, error) {
-	path, _ := astutil.PathEnclosingInterval(f, pos, pos)
-	for _, n := range path {
-		switch n := n.(type) {
-		case *ast.SwitchStmt:
-			return n, info.Types[n.Tag].Type, nil
-
-		case *ast.TypeSwitchStmt:
-			switch stmt := n.Assign.(type) {
-			case *ast.AssignStmt:
-				return n, info.Types[stmt.Rhs[0].(*ast.TypeAssertExpr).X].Type, nil
-			case *ast.ExprStmt:
-				return n, info.Types[stmt.X.(*ast.TypeAssertExpr).X].Type, nil
-			}
-			return nil, nil, errors.New("invalid type switch")
-
-		default:
-			// continue
+// fillEdits returns the edits filling the (type) switch swtch in the file
+// f on a value of type typ, or the select statement swtch. The packages
+// in the code are qualified by qf.
+func fillEdits(ctx context.Context, prog *program, pkg *packages.Package, f *ast.File, swtch ast.Stmt, typ types.Type, qf types.Qualifier, opts options) ([]output, error) {
+	if sel, ok := swtch.(*ast.SelectStmt); ok {
+		return insertCases(prog.Fset, sel, fillSelect(pkg, f, sel, qf, opts), opts.insert)
+	}
+	if opts.errorsSwitch {
+		stmts, err := fillErrorSwitch(ctx, prog, pkg, f, swtch, qf, opts)
+		if err != nil {
+			return nil, err
+		}
+		start := prog.Fset.Position(swtch.Pos()).Offset
+		end := prog.Fset.Position(swtch.End()).Offset
+		out, err := prepareOutput(stmts, start, end)
+		if err != nil {
+			return nil, err
 		}
+		return []output{out}, nil
 	}
-	return nil, nil, errNotFound
+	clauses, err := fillSwitch(ctx, pkg, prog, swtch, typ, qf, opts)
+	if err != nil {
+		return nil, err
+	}
+	return insertCases(prog.Fset, swtch, clauses, opts.insert)
 }
 
-func byLine(lprog *loader.Program, path string, line int, dst io.Writer) (err error) {
-	var f *ast.File
-	var pkg *loader.PackageInfo
-	for _, p := range lprog.InitialPackages() {
-		for _, af := range p.Files {
-			if file := lprog.Fset.File(af.Pos()); file.Name() == path {
-				f = af
-				pkg = p
-			}
-		}
+func findPos(prog *program, path string, offset int) (*ast.File, *packages.Package, token.Pos, error) {
+	f, pkg := prog.file(path)
+	if f == nil {
+		return nil, nil, 0, fmt.Errorf("could not find file %q", path)
 	}
-	if f == nil || pkg == nil {
-		return fmt.Errorf("could not find file %q", path)
+	file := prog.Fset.File(f.Pos())
+	if offset > file.Size() {
+		return nil, nil, 0,
+			fmt.Errorf("file size (%d) is smaller than given offset (%d)", file.Size(), offset)
 	}
+	return f, pkg, file.Pos(offset), nil
+}
 
-	var outs []output
+// findSwitchStmt returns the innermost (type) switch or select statement
+// at pos.
+// The position can be anywhere from the indentation of the line of the
+// switch keyword up to and including the end of its closing brace, e.g.
+// on a blank line of the body.
+func findSwitchStmt(fset *token.FileSet, f *ast.File, info types.Info, pos token.Pos) (ast.Stmt, types.Type, error) {
+	file := fset.File(f.Pos())
+	var swtch ast.Stmt
 	ast.Inspect(f, func(n ast.Node) bool {
-		switch swtch := n.(type) {
-		case *ast.SwitchStmt:
-			startLine := lprog.Fset.Position(swtch.Pos()).Line
-			endLine := lprog.Fset.Position(swtch.End()).Line
-			if !(startLine <= line && line <= endLine) {
-				return true
+		switch n.(type) {
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			if file.LineStart(file.Line(n.Pos())) <= pos && pos <= n.End() {
+				swtch = n.(ast.Stmt)
 			}
+		}
+		return true
+	})
 
-			start := lprog.Fset.Position(swtch.Pos()).Offset
-			end := lprog.Fset.Position(swtch.End()).Offset
-			newSwtch := fillSwitch(pkg, lprog, swtch, pkg.Info.Types[swtch.Tag].Type)
+	switch n := swtch.(type) {
+	case *ast.SwitchStmt:
+		return n, info.Types[n.Tag].Type, nil
+	case *ast.TypeSwitchStmt:
+		if typ, ok := switchType(info, n); ok {
+			return n, typ, nil
+		}
+		return nil, nil, errors.New("invalid type switch")
+	case *ast.SelectStmt:
+		return n, nil, nil
+	default:
+		return nil, nil, errNotFound
+	}
+}
 
-			var out output
-			out, err = prepareOutput(newSwtch, start, end)
-			if err != nil {
-				return false
-			}
-			outs = append(outs, out)
+// switchType returns the type of the tag of a switch statement or the
+// type of the asserted expression of a type switch statement, and nil for
+// a select statement. It returns false for invalid type switches.
+func switchType(info types.Info, n ast.Stmt) (types.Type, bool) {
+	switch n := n.(type) {
+	case *ast.SwitchStmt:
+		return info.Types[n.Tag].Type, true
+	case *ast.SelectStmt:
+		return nil, true
+	case *ast.TypeSwitchStmt:
+		if x := assertedExpr(n); x != nil {
+			return info.Types[x].Type, true
+		}
+	}
+	return nil, false
+}
 
-		case *ast.TypeSwitchStmt:
-			startLine := lprog.Fset.Position(swtch.Pos()).Line
-			endLine := lprog.Fset.Position(swtch.End()).Line
-			if !(startLine <= line && line <= endLine) {
-				return true
-			}
+// switchBody returns the body of the (type) switch or select statement n.
+func switchBody(n ast.Stmt) *ast.BlockStmt {
+	switch n := n.(type) {
+	case *ast.SwitchStmt:
+		return n.Body
+	case *ast.TypeSwitchStmt:
+		return n.Body
+	case *ast.SelectStmt:
+		return n.Body
+	}
+	return nil
+}
 
-			var typ types.Type
-			switch stmt := swtch.Assign.(type) {
-			case *ast.AssignStmt:
-				typ = pkg.Info.Types[stmt.Rhs[0].(*ast.TypeAssertExpr).X].Type
-			case *ast.ExprStmt:
-				typ = pkg.Info.Types[stmt.X.(*ast.TypeAssertExpr).X].Type
-			default:
-				return true
-			}
+// assertedExpr returns the expression whose type is switched on in the
+// type switch n, e.g. x in switch y := x.(type), or nil if n is invalid.
+func assertedExpr(n *ast.TypeSwitchStmt) ast.Expr {
+	switch stmt := n.Assign.(type) {
+	case *ast.AssignStmt:
+		return stmt.Rhs[0].(*ast.TypeAssertExpr).X
+	case *ast.ExprStmt:
+		return stmt.X.(*ast.TypeAssertExpr).X
+	}
+	return nil
+}
 
-			newSwtch := fillSwitch(pkg, lprog, swtch, typ)
-			start := lprog.Fset.Position(swtch.Pos()).Offset
-			end := lprog.Fset.Position(swtch.End()).Offset
+func byLine(ctx context.Context, prog *program, path string, line int, opts options) ([]output, error) {
+	f, pkg := prog.file(path)
+	if f == nil {
+		return nil, fmt.Errorf("could not find file %q", path)
+	}
 
-			var out output
-			out, err = prepareOutput(newSwtch, start, end)
-			if err != nil {
-				return false
-			}
-			outs = append(outs, out)
+	if opts.errorsAs {
+		return errorsAsByLine(prog, pkg, f, line, opts)
+	}
 
+	// Collect the innermost switch statements spanning the line,
+	// such that a switch nested in the case of another switch,
+	// e.g. inside a select case or a for-range body, is filled
+	// instead of the enclosing switch.
+	var swtchs []ast.Stmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
 		default:
 			return true
 		}
-
-		return false
+		startLine := prog.Fset.Position(n.Pos()).Line
+		endLine := prog.Fset.Position(n.End()).Line
+		if !(startLine <= line && line <= endLine) {
+			return true
+		}
+		if _, ok := switchType(*pkg.TypesInfo, n.(ast.Stmt)); !ok {
+			return true
+		}
+		inner := swtchs[:0]
+		for _, s := range swtchs {
+			if !(s.Pos() <= n.Pos() && n.End() <= s.End()) {
+				inner = append(inner, s)
+			}
+		}
+		swtchs = append(inner, n.(ast.Stmt))
+		return true
 	})
-	if err != nil {
-		return err
-	}
-	if len(outs) == 0 {
-		return errNotFound
+	if len(swtchs) == 0 {
+		return nil, errNotFound
 	}
 
-	for i := len(outs)/2 - 1; i >= 0; i-- {
-		opp := len(outs) - 1 - i
-		outs[i], outs[opp] = outs[opp], outs[i]
+	imps := newFileImports(f, pkg)
+	outs := make([]output, 0, len(swtchs))
+	for i := len(swtchs) - 1; i >= 0; i-- {
+		swtch := swtchs[i]
+		typ, _ := switchType(*pkg.TypesInfo, swtch)
+		edits, err := fillEdits(ctx, prog, pkg, f, swtch, typ, imps.qualifier, opts)
+		if err != nil {
+			return nil, err
+		}
+		outs = append(outs, edits...)
 	}
+	return imps.addEdit(prog, path, outs)
+}
 
-	return json.NewEncoder(dst).Encode(outs)
+// options contains the settings given on the command line.
+type options struct {
+	reach        *reachability  // reachability of types, nil if all implementations are added
+	body         string         // body of the generated cases, see caseBody
+	order        string         // order of the generated cases, see fillswitch.Options
+	dflt         string         // default clause to add, see defaultClause
+	receivers    string         // choice between T and *T for the cases, see fillswitch.Options
+	include      *regexp.Regexp // cases to generate, see fillswitch.Options; nil if all are generated
+	exclude      *regexp.Regexp // cases not to generate, see fillswitch.Options; nil if all are generated
+	errorsAs     bool           // fill errors.As if-else chains instead of switches
+	errorsSwitch bool           // rewrite switches on errors with errors.Is and errors.As cases
+	ctxDone      bool           // add a ctx.Done() case to select statements, see fillSelect
+	index        *implIndex     // index of the implementations of interfaces, nil if not used
+	insert       string         // where to insert the new cases, see insertionIndexes
 }
 
 type output struct {
@@ -348,7 +800,8 @@ type output struct {
 	Code  string `json:"code"`
 }
 
-func prepareOutput(n ast.Node, start, end int) (output, error) {
+// prepareOutput formats n, which is a node or a list of statements.
+func prepareOutput(n interface{}, start, end int) (output, error) {
 	var buf bytes.Buffer
 	if err := format.Node(&buf, token.NewFileSet(), n); err != nil {
 		return output{}, err
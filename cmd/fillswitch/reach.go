@@ -0,0 +1,85 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// reachability approximates the set of types which
+// can flow into an interface value in a program.
+type reachability struct {
+	prog  *ssa.Program
+	types typeutil.Map // types converted to an interface somewhere in the program
+}
+
+// buildReachability builds the SSA form of all error-free packages
+// of prog and collects the types of all values which are converted
+// to an interface, i.e. the operands of all MakeInterface instructions.
+// It also returns the paths of the packages with errors, whose types
+// are all reachable, see reachable.
+func buildReachability(prog *program) (*reachability, []string) {
+	var illTyped []string
+	packages.Visit(prog.initial, nil, func(pkg *packages.Package) {
+		if pkg.IllTyped {
+			illTyped = append(illTyped, pkg.PkgPath)
+		}
+	})
+	ssaProg, _ := ssautil.AllPackages(prog.initial, ssa.InstantiateGenerics)
+	ssaProg.Build()
+
+	r := &reachability{prog: ssaProg}
+	for fn := range ssautil.AllFunctions(ssaProg) {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if mi, ok := instr.(*ssa.MakeInterface); ok {
+					r.types.Set(mi.X.Type(), true)
+				}
+			}
+		}
+	}
+	return r, illTyped
+}
+
+// reachable reports whether a value of type t can flow into an
+// interface value. Types of packages without SSA form, e.g. due
+// to type errors, are assumed to be reachable.
+func (r *reachability) reachable(t types.Type) bool {
+	if n, ok := deref(t).(*types.Named); ok && n.Obj().Pkg() != nil && r.prog.Package(n.Obj().Pkg()) == nil {
+		return true
+	}
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		// A case of an interface type matches all of its implementations.
+		return true
+	}
+	return r.types.At(t) != nil
+}
+
+// filter returns the types in typs which are reachable. If only
+// pointers to a type are converted to an interface, then the type
+// is replaced by its pointer type.
+func (r *reachability) filter(typs []types.Type) []types.Type {
+	var res []types.Type
+	for _, t := range typs {
+		if r.reachable(t) {
+			res = append(res, t)
+		} else if p := types.NewPointer(t); r.types.At(p) != nil {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func deref(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// searchPackages calls fn for all packages of prog, using up to
+// GOMAXPROCS goroutines. fn must be safe for concurrent use. No new
+// packages are searched once ctx is canceled; the error of ctx is
+// returned in that case.
+func searchPackages(ctx context.Context, prog *program, fn func(*packages.Package)) error {
+	work := make(chan *packages.Package)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pkg := range work {
+				fn(pkg)
+			}
+		}()
+	}
+
+	var err error
+loop:
+	for _, pkg := range prog.all {
+		select {
+		case work <- pkg:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		}
+	}
+	close(work)
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/davidrjenni/reftools/fillswitch"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// fillSelect returns the communication clauses missing in the select
+// statement sel of the file f: a receive case for every channel variable
+// in scope and every channel field of the receiver of the enclosing
+// method, or a send case of the zero value for send-only channels. With
+// opts.ctxDone, a case receiving from ctx.Done() is added, too, where ctx
+// is the innermost variable of type context.Context in scope. The
+// packages in the zero values are qualified by qf.
+func fillSelect(pkg *packages.Package, f *ast.File, sel *ast.SelectStmt, qf types.Qualifier, opts options) []ast.Stmt {
+	existing := make(map[string]bool) // the channels of the cases
+	for _, stmt := range sel.Body.List {
+		if ch := commChan(stmt.(*ast.CommClause).Comm); ch != nil {
+			existing[types.ExprString(ch)] = true
+		}
+	}
+
+	// The channels and their types; ctx.Done() has none.
+	var (
+		chans []ast.Expr
+		typs  []types.Type
+		ctx   types.Object
+	)
+	for _, v := range varsInScope(pkg.Types, sel.Pos()) {
+		switch {
+		case isChan(v.Type()):
+			chans = append(chans, ast.NewIdent(v.Name()))
+			typs = append(typs, v.Type())
+		case ctx == nil && isContext(v.Type()):
+			ctx = v
+		}
+	}
+	if recv := receiver(pkg.TypesInfo, f, sel); recv != nil {
+		if s, ok := deref(recv.Type()).Underlying().(*types.Struct); ok {
+			for i := 0; i < s.NumFields(); i++ {
+				if fld := s.Field(i); isChan(fld.Type()) && fillswitch.Visible(pkg.Types, fld) {
+					chans = append(chans, &ast.SelectorExpr{X: ast.NewIdent(recv.Name()), Sel: ast.NewIdent(fld.Name())})
+					typs = append(typs, fld.Type())
+				}
+			}
+		}
+	}
+	if opts.ctxDone && ctx != nil {
+		chans = append(chans, &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent(ctx.Name()), Sel: ast.NewIdent("Done")}})
+		typs = append(typs, nil)
+	}
+
+	var clauses []ast.Stmt
+	for i, ch := range chans {
+		if existing[types.ExprString(ch)] {
+			continue
+		}
+		var comm ast.Stmt
+		if c, ok := typs[i].(*types.Chan); ok && c.Dir() == types.SendOnly {
+			comm = &ast.SendStmt{Chan: ch, Value: ast.NewIdent(zeroValue(qf, c.Elem()))}
+		} else {
+			comm = &ast.ExprStmt{X: &ast.UnaryExpr{Op: token.ARROW, X: ch}}
+		}
+		clauses = append(clauses, &ast.CommClause{
+			Comm: comm,
+			Body: caseBody(types.ExprString(ch), opts.body),
+		})
+	}
+	return clauses
+}
+
+// commChan returns the channel of the communication comm of a case of a
+// select statement, or nil for the default case.
+func commChan(comm ast.Stmt) ast.Expr {
+	var x ast.Expr
+	switch comm := comm.(type) {
+	case *ast.SendStmt:
+		return comm.Chan
+	case *ast.ExprStmt:
+		x = comm.X
+	case *ast.AssignStmt:
+		x = comm.Rhs[0]
+	}
+	if u, ok := ast.Unparen(x).(*ast.UnaryExpr); ok && u.Op == token.ARROW {
+		return u.X
+	}
+	return nil
+}
+
+// varsInScope returns the variables of the function scopes enclosing pos
+// in pkg which are declared before pos and not shadowed, from the
+// innermost scope outwards, each in the order of declaration.
+func varsInScope(pkg *types.Package, pos token.Pos) []*types.Var {
+	var vars []*types.Var
+	seen := make(map[string]bool)
+	for s := pkg.Scope().Innermost(pos); s != nil && s != pkg.Scope(); s = s.Parent() {
+		var inner []*types.Var
+		for _, name := range s.Names() {
+			v, ok := s.Lookup(name).(*types.Var)
+			if seen[name] || !ok || v.Pos() >= pos {
+				continue
+			}
+			seen[name] = true
+			inner = append(inner, v)
+		}
+		sort.Slice(inner, func(i, j int) bool { return inner[i].Pos() < inner[j].Pos() })
+		vars = append(vars, inner...)
+	}
+	return vars
+}
+
+// receiver returns the receiver of the method enclosing n in the file f,
+// or nil if there is none or it is unnamed.
+func receiver(info *types.Info, f *ast.File, n ast.Node) types.Object {
+	path, _ := astutil.PathEnclosingInterval(f, n.Pos(), n.End())
+	for _, n := range path {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			continue
+		case *ast.FuncDecl:
+			if n.Recv == nil || len(n.Recv.List) == 0 || len(n.Recv.List[0].Names) == 0 {
+				return nil
+			}
+			return info.Defs[n.Recv.List[0].Names[0]]
+		}
+	}
+	return nil
+}
+
+func isChan(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Chan)
+	return ok
+}
+
+func isContext(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == "context" && n.Obj().Name() == "Context"
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// tabStop marks the tab stops in the generated code with -body=snippet.
+// As a NUL byte cannot occur in Go source, it cannot be confused with the
+// code of existing cases.
+const tabStop = "\x00"
+
+// snippetEscaper escapes the characters with a special meaning in the
+// snippet syntax of the Language Server Protocol.
+var snippetEscaper = strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`)
+
+// snippetEdits returns outs with their code as LSP snippets: the tab stops
+// are numbered $1, $2, ... in the order of the cases of each edit, and the
+// rest of the code is escaped.
+func snippetEdits(outs []output) []output {
+	res := make([]output, len(outs))
+	for i, out := range outs {
+		res[i] = out
+		parts := strings.Split(snippetEscaper.Replace(out.Code), tabStop)
+		var b strings.Builder
+		for j, part := range parts {
+			if j > 0 {
+				b.WriteString("$" + strconv.Itoa(j))
+			}
+			b.WriteString(part)
+		}
+		res[i].Code = b.String()
+	}
+	return res
+}
@@ -0,0 +1,14 @@
+package p
+
+type kind int
+
+const (
+	small kind = iota
+	large
+)
+
+func test(k kind) {
+	switch k {
+
+	}
+}
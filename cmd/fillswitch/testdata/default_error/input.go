@@ -0,0 +1,16 @@
+package p
+
+type kind int
+
+const (
+	small kind = iota
+	large
+)
+
+type point struct{ x, y int }
+
+func test(k kind) (int, string, point, *point, error) {
+	switch k {
+	}
+	return 0, "", point{}, nil, nil
+}
@@ -0,0 +1,14 @@
+package p
+
+type shape interface{ area() float64 }
+
+type square struct{}
+
+func (square) area() float64 { return 0 }
+
+func test(s shape) {
+	switch s := s.(type) {
+	case square:
+		println(s)
+	}
+}
@@ -0,0 +1,8 @@
+package p
+
+import . "go/ast"
+
+func test(d Decl) {
+	switch d.(type) {
+	}
+}
@@ -0,0 +1,20 @@
+package p
+
+type kind int
+
+const (
+	small kind = iota
+	medium
+	large
+)
+
+const defaultKind = medium
+
+var current kind
+
+func test(k kind) {
+	switch k {
+	case medium:
+		println("medium")
+	}
+}
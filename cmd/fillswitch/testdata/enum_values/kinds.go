@@ -0,0 +1,3 @@
+package p
+
+const huge kind = 3
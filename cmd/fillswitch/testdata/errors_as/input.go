@@ -0,0 +1,14 @@
+package p
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+func f(err error) {
+	var perr *os.PathError
+	if errors.As(err, &perr) {
+	} else {
+	}
+}
@@ -0,0 +1,14 @@
+package p
+
+import (
+	"errors"
+	"os"
+)
+
+func f(err error) {
+	var perr *os.PathError
+	if err == nil {
+	} else if errors.As(err, &perr) {
+	} else {
+	}
+}
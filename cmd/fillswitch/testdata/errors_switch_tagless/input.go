@@ -0,0 +1,24 @@
+package p
+
+import "errors"
+
+var (
+	errClosed = errors.New("closed")
+	errBusy   = errors.New("busy")
+)
+
+type notFoundError struct{ name string }
+
+func (e *notFoundError) Error() string { return e.name + " not found" }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timeout" }
+
+func test(err error) {
+	var nf *notFoundError
+	switch {
+	case errors.Is(err, errClosed):
+	case errors.As(err, &nf):
+	}
+}
@@ -0,0 +1,17 @@
+package p
+
+import (
+	"math/rand"
+
+	_ "github.com/davidrjenni/reftools/cmd/fillswitch/testdata/import_collision/internal/a/rand"
+	_ "github.com/davidrjenni/reftools/cmd/fillswitch/testdata/import_collision/internal/b/rand"
+)
+
+var _ = rand.Int
+
+type Seeder interface{ Seed() int }
+
+func test(s Seeder) {
+	switch s.(type) {
+	}
+}
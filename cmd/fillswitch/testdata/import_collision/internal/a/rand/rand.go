@@ -0,0 +1,5 @@
+package rand
+
+type A struct{}
+
+func (A) Seed() int { return 0 }
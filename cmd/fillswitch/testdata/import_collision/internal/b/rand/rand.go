@@ -0,0 +1,5 @@
+package rand
+
+type B struct{}
+
+func (B) Seed() int { return 0 }
@@ -0,0 +1,23 @@
+package p
+
+type shape interface {
+	area() float64
+}
+
+type circle struct{}
+
+func (circle) area() float64 { return 0 }
+
+type square struct{}
+
+func (*square) area() float64 { return 0 }
+
+func test(kind int, shapes []shape) {
+	switch kind {
+	case 0:
+		for _, s := range shapes {
+			switch s := s.(type) {
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package p
+
+type shape interface {
+	area() float64
+}
+
+type circle struct{}
+
+func (circle) area() float64 { return 0 }
+
+type square struct{}
+
+func (*square) area() float64 { return 0 }
+
+func test(shapes chan shape, done chan struct{}) {
+	for {
+		select {
+		case s := <-shapes:
+			switch s.(type) {
+			}
+		case <-done:
+			return
+		}
+	}
+}
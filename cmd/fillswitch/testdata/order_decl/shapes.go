@@ -0,0 +1,9 @@
+package p
+
+type triangle struct{}
+
+func (triangle) area() float64 { return 0 }
+
+type blob struct{}
+
+func (blob) area() float64 { return 0 }
@@ -0,0 +1,16 @@
+package p
+
+type shape interface{ area() float64 }
+
+func test(s shape) {
+	switch s.(type) {
+	}
+}
+
+type square struct{}
+
+func (square) area() float64 { return 0 }
+
+type circle struct{}
+
+func (*circle) area() float64 { return 0 }
@@ -0,0 +1,26 @@
+package p
+
+type shape interface {
+	area() float64
+}
+
+type circle struct{}
+
+func (circle) area() float64 { return 0 }
+
+type square struct{}
+
+func (square) area() float64 { return 0 }
+
+type triangle struct{}
+
+func (triangle) area() float64 { return 0 }
+
+func shapes() []shape {
+	return []shape{circle{}, &square{}}
+}
+
+func test(s shape) {
+	switch s.(type) {
+	}
+}
@@ -0,0 +1,8 @@
+package p
+
+import goast "go/ast"
+
+func test(d goast.Decl) {
+	switch d.(type) {
+	}
+}
@@ -0,0 +1,12 @@
+package p
+
+import "github.com/davidrjenni/reftools/cmd/fillswitch/testdata/sealed/node"
+
+// wrapper implements node.Node by embedding,
+// but is not one of the sealed types.
+type wrapper struct{ node.Ident }
+
+func test(n node.Node) {
+	switch n.(type) {
+	}
+}
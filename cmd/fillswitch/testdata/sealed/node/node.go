@@ -0,0 +1,27 @@
+package node
+
+type Node interface {
+	Pos() int
+	node()
+}
+
+type Expr interface {
+	Node
+	expr()
+}
+
+type Ident struct{}
+
+func (Ident) Pos() int { return 0 }
+func (Ident) node()    {}
+func (Ident) expr()    {}
+
+type Block struct{}
+
+func (*Block) Pos() int { return 0 }
+func (*Block) node()    {}
+
+type comment struct{}
+
+func (comment) Pos() int { return 0 }
+func (comment) node()    {}
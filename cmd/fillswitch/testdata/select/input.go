@@ -0,0 +1,16 @@
+package p
+
+import "context"
+
+type worker struct {
+	quit    chan struct{}
+	results chan<- string
+	count   int
+}
+
+func (w *worker) run(ctx context.Context, in <-chan int, errs chan error) {
+	select {
+	case v := <-in:
+		_ = v
+	}
+}
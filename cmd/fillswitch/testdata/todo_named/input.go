@@ -0,0 +1,18 @@
+package p
+
+type shape interface {
+	area() float64
+}
+
+type circle struct{}
+
+func (circle) area() float64 { return 0 }
+
+type square struct{}
+
+func (*square) area() float64 { return 0 }
+
+func test(s shape) {
+	switch s.(type) {
+	}
+}
@@ -0,0 +1,14 @@
+package p
+
+type kind int
+
+const (
+	small kind = iota
+	medium
+	large
+)
+
+func test(k kind) {
+	switch k {
+	}
+}
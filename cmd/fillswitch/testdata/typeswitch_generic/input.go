@@ -0,0 +1,17 @@
+package p
+
+type MyType struct{}
+
+type number interface {
+	~int | ~float64
+}
+
+type value interface {
+	number | ~string | MyType
+}
+
+func test[T value](v T) {
+	switch any(v).(type) {
+	case string:
+	}
+}
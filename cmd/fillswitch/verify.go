@@ -0,0 +1,175 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io"
+	"sort"
+
+	"github.com/davidrjenni/reftools/fillswitch"
+)
+
+// staleCase is a case expression of a (type) switch which does not
+// correspond to a type or value of the switched type anymore.
+type staleCase struct {
+	expr   ast.Expr
+	reason string
+}
+
+// staleCases returns the stale case expressions of the switch statement
+// swtch over a value of type typ: types of a type switch which do not
+// implement the switched interface and values which are undefined or not
+// assignable to the type of the tag.
+func staleCases(pkg *types.Package, info types.Info, swtch ast.Stmt, typ types.Type) []staleCase {
+	var body *ast.BlockStmt
+	var iface *types.Interface
+	switch swtch := swtch.(type) {
+	case *ast.SwitchStmt:
+		if typ == nil {
+			return nil
+		}
+		body = swtch.Body
+	case *ast.TypeSwitchStmt:
+		var ok bool
+		if iface, ok = typ.Underlying().(*types.Interface); !ok {
+			return nil
+		}
+		body = swtch.Body
+	}
+
+	var stale []staleCase
+	for _, cc := range body.List {
+		for _, e := range cc.(*ast.CaseClause).List {
+			if id, ok := e.(*ast.Ident); ok && id.Name == "nil" && iface != nil {
+				continue
+			}
+			t := info.TypeOf(e)
+			if t == nil || t == types.Typ[types.Invalid] {
+				stale = append(stale, staleCase{expr: e, reason: "undefined"})
+				continue
+			}
+			switch {
+			case iface != nil:
+				if types.IsInterface(t) || types.AssignableTo(t, iface) {
+					continue
+				}
+				reason := fmt.Sprintf("%s does not implement %s", fillswitch.TypeString(pkg, t), fillswitch.TypeString(pkg, typ))
+				if m, _ := types.MissingMethod(t, iface, true); m != nil {
+					reason += fmt.Sprintf(" (missing method %s)", m.Name())
+				}
+				stale = append(stale, staleCase{expr: e, reason: reason})
+			case !types.AssignableTo(t, typ) && !types.AssignableTo(typ, t):
+				reason := fmt.Sprintf("%s is not assignable to %s", fillswitch.TypeString(pkg, t), fillswitch.TypeString(pkg, typ))
+				stale = append(stale, staleCase{expr: e, reason: reason})
+			}
+		}
+	}
+	return stale
+}
+
+// deleteCases removes the stale case expressions from swtch. Case clauses
+// which are left without expressions are removed, so that they do not
+// turn into default clauses.
+func deleteCases(swtch ast.Stmt, stale []staleCase) {
+	isStale := make(map[ast.Expr]bool)
+	for _, s := range stale {
+		isStale[s.expr] = true
+	}
+
+	var body *ast.BlockStmt
+	switch swtch := swtch.(type) {
+	case *ast.SwitchStmt:
+		body = swtch.Body
+	case *ast.TypeSwitchStmt:
+		body = swtch.Body
+	}
+	clauses := body.List[:0]
+	for _, s := range body.List {
+		cc := s.(*ast.CaseClause)
+		if cc.List == nil {
+			clauses = append(clauses, cc)
+			continue
+		}
+		list := cc.List[:0]
+		for _, e := range cc.List {
+			if !isStale[e] {
+				list = append(list, e)
+			}
+		}
+		if len(list) > 0 {
+			cc.List = list
+			clauses = append(clauses, cc)
+		}
+	}
+	body.List = clauses
+}
+
+// verifyFile reports the stale cases of all (type) switches in the file at
+// path on w, one per line, prefixed with their positions. With del, the
+// edits deleting them are returned instead, one per switch. It reports
+// whether there are stale cases.
+func verifyFile(w io.Writer, prog *program, path string, del bool) ([]output, bool, error) {
+	f, pkg, _, err := findPos(prog, path, 0)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var swtchs []ast.Stmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt:
+			swtchs = append(swtchs, n.(ast.Stmt))
+		}
+		return true
+	})
+
+	stale := make([][]staleCase, len(swtchs))
+	var all []staleCase
+	for i, swtch := range swtchs {
+		if typ, ok := switchType(*pkg.TypesInfo, swtch); ok {
+			stale[i] = staleCases(pkg.Types, *pkg.TypesInfo, swtch, typ)
+			all = append(all, stale[i]...)
+		}
+	}
+	if !del {
+		sort.Slice(all, func(i, j int) bool { return all[i].expr.Pos() < all[j].expr.Pos() })
+		for _, s := range all {
+			pos := prog.Fset.Position(s.expr.Pos())
+			fmt.Fprintf(w, "%s: case %s: %s\n", pos, types.ExprString(s.expr), s.reason)
+		}
+		return nil, len(all) > 0, nil
+	}
+
+	// Nested switches are edited first, so that
+	// the edits of the enclosing ones include them.
+	var outs []output
+	for i := len(swtchs) - 1; i >= 0; i-- {
+		if len(stale[i]) == 0 {
+			continue
+		}
+		start := prog.Fset.Position(swtchs[i].Pos()).Offset
+		end := prog.Fset.Position(swtchs[i].End()).Offset
+		deleteCases(swtchs[i], stale[i])
+		out, err := prepareOutput(swtchs[i], start, end)
+		if err != nil {
+			return nil, false, err
+		}
+		outs = append(outs, out)
+	}
+
+	sort.Slice(outs, func(i, j int) bool { return outs[i].Start < outs[j].Start })
+	var res []output
+	for _, out := range outs {
+		// Skip the edits of switches nested in another edited one.
+		if n := len(res); n > 0 && out.End <= res[n-1].End {
+			continue
+		}
+		res = append(res, out)
+	}
+	return res, len(all) > 0, nil
+}
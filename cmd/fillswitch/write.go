@@ -0,0 +1,65 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+)
+
+// writeFile applies the edits in outs to the file at path and writes
+// the result back to disk. The contents of the file are taken from the
+// overlay if present.
+func writeFile(path string, overlay map[string][]byte, outs []output) error {
+	src, err := readSource(path, overlay)
+	if err != nil {
+		return err
+	}
+	res, err := applyEdits(src, outs)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, res, fi.Mode().Perm())
+}
+
+// indentEdits returns outs with the lines of their code after the first
+// one indented like the line of src on which the edit starts. The code is
+// printed without indentation for editors, which indent it themselves.
+// The rest of the line follows code ending with a newline, e.g. of an
+// inserted declaration, so it is indented as well.
+func indentEdits(src []byte, outs []output) []output {
+	res := make([]output, len(outs))
+	for i, out := range outs {
+		res[i] = out
+		if out.Start < 0 || out.Start > len(src) {
+			continue // reported by applyEdits
+		}
+		line := src[bytes.LastIndexByte(src[:out.Start], '\n')+1 : out.Start]
+		indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+		lines := strings.Split(out.Code, "\n")
+		for j := 1; j < len(lines); j++ {
+			if lines[j] != "" || j == len(lines)-1 && out.End < len(src) && src[out.End] != '\n' {
+				lines[j] = string(indent) + lines[j]
+			}
+		}
+		res[i].Code = strings.Join(lines, "\n")
+	}
+	return res
+}
+
+// readSource returns the contents of the file at path,
+// which are taken from the overlay if present.
+func readSource(path string, overlay map[string][]byte) ([]byte, error) {
+	if src, ok := overlay[path]; ok {
+		return src, nil
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,475 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package fillswitch proposes the missing cases of switch statements: the
+// types implementing the switched interface for a type switch, and the
+// constants and variables of the type of the tag for a switch on a value.
+// It is the library behind the fillswitch command, for other tools and
+// language servers which have type-checked packages at hand.
+//
+// The candidates for the cases are the objects of a program, e.g. the ones
+// of the scopes of the packages as returned by PackageObjects, or all the
+// objects defined in the packages, which include local types. A type
+// switch on a sealed interface, i.e. an interface with an unexported
+// method, only gets the types of the package declaring the method.
+package fillswitch
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Options are the settings for the proposed cases.
+type Options struct {
+	// Receivers chooses between T and *T for the cases of a type switch,
+	// see the Receivers constants. The empty string is ReceiversValue.
+	Receivers string
+
+	// Order is the order of the cases, see the Order constants. The
+	// empty string is OrderAlpha.
+	Order string
+
+	// Fset holds the positions of the candidates, which are needed for
+	// OrderDecl. Without it, the order of the declarations in a package
+	// is the order of their positions.
+	Fset *token.FileSet
+
+	// Include and Exclude filter the cases by their names, e.g. Square,
+	// or their names qualified with the package path, e.g.
+	// example.com/shapes.Square: if not nil, only the matches of
+	// Include are cases and none of the matches of Exclude.
+	Include, Exclude *regexp.Regexp
+
+	// Filter, if not nil, filters the types of the cases of a type
+	// switch proposed by Clauses, e.g. by the types whose values are
+	// converted to interfaces in the program.
+	Filter func([]types.Type) []types.Type
+
+	// Body, if not nil, returns the body of the case clause generated by
+	// Clauses for the type, constant or variable with the given name.
+	Body func(name string) []ast.Stmt
+
+	// Qualifier, if not nil, qualifies the names of the other packages in
+	// the cases generated by Clauses, e.g. a FileQualifier for the file of
+	// the switch, which respects renamed and dot imports. By default, the
+	// package names are used.
+	Qualifier types.Qualifier
+}
+
+// Clauses returns the case clauses missing in the (type) switch swtch in
+// pkg with the type information info. The existing cases are left out,
+// as are the constants whose value is already the one of a case. The
+// candidates for the cases are returned by find, which is only called if
+// they are needed, i.e. not for a type switch on a sealed interface, on an
+// interface with a union or on a value of a type parameter with a union
+// constraint. For a type switch,
+// find gets the interface whose implementations are needed, e.g. to look
+// them up in an index, and nil otherwise. Clauses returns no clauses for
+// a switch without tag or a type switch on a non-interface. A type switch
+// on a sealed interface which is implemented by unexported types of
+// another package, which cannot be named in a case, additionally gets an
+// empty default clause unless it has one, since its cases cannot be
+// exhaustive.
+func Clauses(pkg *types.Package, info *types.Info, swtch ast.Stmt, find func(iface *types.Interface) ([]types.Object, error), opts Options) ([]ast.Stmt, error) {
+	body := opts.Body
+	if body == nil {
+		body = func(string) []ast.Stmt { return nil }
+	}
+	qf := opts.Qualifier
+	if qf == nil {
+		qf = packageQualifier(pkg)
+	}
+
+	var clauses []ast.Stmt
+	switch swtch := swtch.(type) {
+	case *ast.SwitchStmt:
+		// Do not try to fill a switch without tag.
+		if swtch.Tag == nil {
+			return nil, nil
+		}
+		typ := info.TypeOf(swtch.Tag)
+		if typ == nil {
+			return nil, nil
+		}
+		existing := make(map[string]bool) // the expressions of the cases
+		values := make(map[string]bool)   // the constant values of the cases
+		// Don't add the identifier we switch over to the case statements.
+		if id, ok := swtch.Tag.(*ast.Ident); ok {
+			existing[id.Name] = true
+		}
+		for _, cc := range swtch.Body.List {
+			for _, e := range cc.(*ast.CaseClause).List {
+				existing[types.ExprString(e)] = true
+				if val := info.Types[e].Value; val != nil {
+					values[val.ExactString()] = true
+				}
+			}
+		}
+		objs, err := find(nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range ValueCases(pkg, typ, objs, opts) {
+			name := v.Name()
+			if q := qf(v.Pkg()); q != "" {
+				name = q + "." + name
+			}
+			if existing[name] {
+				continue
+			}
+			if c, ok := v.(*types.Const); ok {
+				// Several constants can have the same value, e.g.
+				// Default = Medium, but the cases must not.
+				if values[c.Val().ExactString()] {
+					continue
+				}
+				values[c.Val().ExactString()] = true
+			}
+			clauses = append(clauses, &ast.CaseClause{
+				List: []ast.Expr{ast.NewIdent(name)},
+				Body: body(name),
+			})
+		}
+
+	case *ast.TypeSwitchStmt:
+		x := assertedExpr(swtch)
+		if x == nil {
+			return nil, nil
+		}
+		typ := info.TypeOf(x)
+		if typ == nil {
+			return nil, nil
+		}
+		iface, ok := typ.Underlying().(*types.Interface)
+		if !ok {
+			return nil, nil
+		}
+		// Don't add the switched interface, e.g. a copy of
+		// it in a package recompiled for its tests.
+		existing := map[string]bool{QualifiedTypeString(typ, qf): true}
+		for _, cc := range swtch.Body.List {
+			for _, e := range cc.(*ast.CaseClause).List {
+				existing[QualifiedTypeString(info.TypeOf(e), qf)] = true
+			}
+		}
+		var typs []types.Type
+		if tp := convertedTypeParam(info, swtch); tp != nil {
+			// The value can only have the types of the constraint.
+			typs = unionTypes(tp)
+			if ciface, ok := tp.Constraint().Underlying().(*types.Interface); ok && typs == nil && ciface.NumMethods() > 0 {
+				iface = ciface
+			}
+		} else {
+			// An interface with a union, e.g. the type of a value
+			// of a type parameter, only has the types of the union.
+			typs = unionTypes(iface)
+		}
+		hidden := false
+		if typs == nil {
+			var objs []types.Object
+			if sealed := sealedPackage(iface); sealed == nil {
+				var err error
+				if objs, err = find(iface); err != nil {
+					return nil, err
+				}
+			} else {
+				hidden = sealedHidden(pkg, sealed, iface)
+			}
+			typs = TypeCases(pkg, iface, objs, opts)
+		} else {
+			typs = includedTypes(typs, opts)
+		}
+		if opts.Filter != nil {
+			typs = opts.Filter(typs)
+		}
+		for _, t := range typs {
+			if ts := QualifiedTypeString(t, qf); !existing[ts] {
+				clauses = append(clauses, &ast.CaseClause{
+					List: []ast.Expr{ast.NewIdent(ts)},
+					Body: body(ts),
+				})
+			}
+		}
+		if hidden && !hasDefault(swtch.Body) {
+			clauses = append(clauses, &ast.CaseClause{})
+		}
+	}
+	return clauses, nil
+}
+
+// TypeCases returns the types of the cases of a type switch in pkg on a
+// value of type iface: the types of the candidates objs, and the error
+// type, which implement iface and can be named in pkg, in the order of
+// opts. For a sealed interface, the candidates are the types of the
+// package declaring its unexported method instead.
+func TypeCases(pkg *types.Package, iface *types.Interface, objs []types.Object, opts Options) []types.Type {
+	var typs []types.Type
+	if sealed := sealedPackage(iface); sealed != nil {
+		typs = sealedTypes(pkg, sealed, iface, opts.Receivers)
+	} else {
+		errType := types.Universe.Lookup("error").Type()
+		if types.AssignableTo(errType, iface) {
+			typs = append(typs, errType)
+		}
+		for _, obj := range objs {
+			typs = append(typs, candidateTypes(pkg, obj, iface, opts.Receivers)...)
+		}
+		typs = uniqTypes(typs)
+	}
+	orderTypes(opts.Fset, pkg, typs, opts.Order)
+	return includedTypes(typs, opts)
+}
+
+// ValueCases returns the constants and variables of the candidates objs
+// which are assignable to typ and can be named in pkg, for the cases of a
+// switch in pkg on a value of type typ, in the order of opts. If typ is
+// an enum type, only its constants are returned, see enumConsts.
+func ValueCases(pkg *types.Package, typ types.Type, objs []types.Object, opts Options) []types.Object {
+	var vars []types.Object
+	for _, obj := range objs {
+		switch obj := obj.(type) {
+		case *types.Const:
+			// Untyped constants are assignable to any type
+			// which can represent them, e.g. utf8.RuneSelf.
+			if Visible(pkg, obj) && !isUntyped(obj.Type()) && types.AssignableTo(obj.Type(), typ) {
+				vars = append(vars, obj)
+			}
+		case *types.Var:
+			if Visible(pkg, obj) && !obj.IsField() && types.AssignableTo(obj.Type(), typ) {
+				vars = append(vars, obj)
+			}
+		}
+	}
+
+	sort.Sort(objsByString(vars))
+	uniq := vars[:0]
+	for i, v := range vars {
+		// Packages recompiled for the tests of
+		// another package yield the same objects.
+		if i == 0 || v.String() != vars[i-1].String() {
+			uniq = append(uniq, v)
+		}
+	}
+	vars = enumConsts(uniq, typ)
+	orderObjects(opts.Fset, pkg, vars, opts.Order)
+
+	res := vars[:0]
+	for _, v := range vars {
+		if included(v, opts) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// PackageObjects returns the objects declared in the scopes of pkgs, the
+// candidates for the cases of switches in other packages.
+func PackageObjects(pkgs ...*types.Package) []types.Object {
+	var objs []types.Object
+	for _, p := range pkgs {
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			objs = append(objs, scope.Lookup(name))
+		}
+	}
+	return objs
+}
+
+// Visible reports whether obj can be referred to in pkg: it is declared in
+// pkg, or it is exported and not in an internal package which pkg cannot
+// import.
+func Visible(pkg *types.Package, obj types.Object) bool {
+	if obj.Pkg() == pkg {
+		return true
+	}
+	if !obj.Exported() {
+		return false
+	}
+
+	// Rough approximation at the "internal" rules.
+
+	path := obj.Pkg().Path()
+	i := 0
+
+	switch {
+	case strings.HasSuffix(path, "/internal"):
+		i = len(path) - len("/internal")
+	case strings.Contains(path, "/internal/"):
+		i = strings.LastIndex(path, "/internal/") + 1
+	case path == "internal", strings.HasPrefix(path, "internal/"):
+		i = 0
+	default:
+		return true
+	}
+	if i > 0 {
+		i--
+	}
+	prefix := path[:i]
+	return len(prefix) > 0 && strings.HasPrefix(pkg.Path(), prefix)
+}
+
+// enumConsts returns the constants in vars if typ is an enum type, i.e. a
+// named integer or string type with constants, as its variables hold a
+// state rather than a value of the enum. Of several constants with the
+// same value, only the first declared one is returned, e.g. Medium but
+// not Default = Medium. Aliases of enum types are enum types, too.
+// Otherwise, vars is returned as is.
+func enumConsts(vars []types.Object, typ types.Type) []types.Object {
+	typ = types.Unalias(typ)
+	basic, ok := typ.Underlying().(*types.Basic)
+	if _, named := typ.(*types.Named); !named || !ok || basic.Info()&(types.IsInteger|types.IsString) == 0 {
+		return vars
+	}
+
+	var consts []types.Object
+	first := make(map[string]types.Object) // the first constant of each value
+	for _, v := range vars {
+		c, ok := v.(*types.Const)
+		if !ok {
+			continue
+		}
+		consts = append(consts, c)
+		val := c.Val().ExactString()
+		if f, ok := first[val]; !ok || declaredBefore(c, f) {
+			first[val] = c
+		}
+	}
+	if len(consts) == 0 {
+		return vars
+	}
+
+	res := make([]types.Object, 0, len(first))
+	for _, c := range consts {
+		if first[c.(*types.Const).Val().ExactString()] == c {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// declaredBefore reports whether a is declared before b, ordering the
+// packages by their paths.
+func declaredBefore(a, b types.Object) bool {
+	if a.Pkg().Path() != b.Pkg().Path() {
+		return a.Pkg().Path() < b.Pkg().Path()
+	}
+	return a.Pos() < b.Pos()
+}
+
+// candidateTypes returns the types of the cases for obj, if it is a type
+// name, in a type switch in pkg on a value of type iface.
+func candidateTypes(pkg *types.Package, obj types.Object, iface types.Type, receivers string) []types.Type {
+	tn, ok := obj.(*types.TypeName)
+	if !ok || tn.IsAlias() || !Visible(pkg, tn) {
+		return nil
+	}
+
+	// Skip type parameters and generic types,
+	// which cannot be used as a case uninstantiated.
+	t, ok := tn.Type().(*types.Named)
+	if !ok || t.TypeParams().Len() > 0 {
+		return nil
+	}
+	// Ignore iface itself and empty interfaces.
+	if i, ok := t.Underlying().(*types.Interface); ok && (iface == i || i.NumMethods() == 0) {
+		return nil
+	}
+	return caseTypes(t, iface, receivers)
+}
+
+// uniqTypes sorts typs and removes the duplicates.
+func uniqTypes(typs []types.Type) []types.Type {
+	sort.Sort(typesByString(typs))
+	uniq := typs[:0]
+	for i, t := range typs {
+		// Packages recompiled for the tests of
+		// another package yield the same types.
+		if i == 0 || t.String() != typs[i-1].String() {
+			uniq = append(uniq, t)
+		}
+	}
+	return uniq
+}
+
+// included reports whether a case is generated for obj, a type or a
+// value, with the filters of opts: whether its name or its name qualified
+// with the package path, e.g. example.com/mocks.MockShape, matches
+// opts.Include, if any, and does not match opts.Exclude, if any.
+func included(obj types.Object, opts Options) bool {
+	names := []string{obj.Name()}
+	if obj.Pkg() != nil {
+		names = append(names, obj.Pkg().Path()+"."+obj.Name())
+	}
+	match := func(re *regexp.Regexp) bool {
+		for _, name := range names {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}
+	return (opts.Include == nil || match(opts.Include)) && (opts.Exclude == nil || !match(opts.Exclude))
+}
+
+// includedTypes returns the types in typs for which cases are generated
+// with the filters of opts, see included.
+func includedTypes(typs []types.Type, opts Options) []types.Type {
+	res := typs[:0:0]
+	for _, t := range typs {
+		if n, ok := deref(t).(*types.Named); !ok || included(n.Obj(), opts) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// assertedExpr returns the expression whose type is switched on in the
+// type switch n, e.g. x in switch y := x.(type), or nil if n is invalid.
+func assertedExpr(n *ast.TypeSwitchStmt) ast.Expr {
+	switch stmt := n.Assign.(type) {
+	case *ast.AssignStmt:
+		return stmt.Rhs[0].(*ast.TypeAssertExpr).X
+	case *ast.ExprStmt:
+		return stmt.X.(*ast.TypeAssertExpr).X
+	}
+	return nil
+}
+
+// hasDefault reports whether the body of a switch has a default clause.
+func hasDefault(body *ast.BlockStmt) bool {
+	for _, cc := range body.List {
+		if cc, ok := cc.(*ast.CaseClause); ok && cc.List == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isUntyped(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Info()&types.IsUntyped != 0
+}
+
+func deref(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+type typesByString []types.Type
+
+func (t typesByString) Len() int           { return len(t) }
+func (t typesByString) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t typesByString) Less(i, j int) bool { return t[i].String() < t[j].String() }
+
+type objsByString []types.Object
+
+func (o objsByString) Len() int           { return len(o) }
+func (o objsByString) Swap(i, j int)      { o[i], o[j] = o[j], o[i] }
+func (o objsByString) Less(i, j int) bool { return o[i].String() < o[j].String() }
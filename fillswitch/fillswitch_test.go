@@ -0,0 +1,253 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fillswitch
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestClauses(t *testing.T) {
+	const src = `package p
+
+import "io"
+
+type Shape interface{ Area() float64 }
+
+type Square struct{}
+
+func (Square) Area() float64 { return 0 }
+
+type Circle struct{}
+
+func (*Circle) Area() float64 { return 0 }
+
+type MockShape struct{ Shape }
+
+type Size int
+
+const (
+	Small Size = iota
+	Medium
+	Large
+	Default = Medium
+)
+
+type Sz = Size
+
+var DefaultSize = Small
+
+func f(s Shape, size Size, r io.Reader, sz Sz) {
+	switch s.(type) {
+	case Square:
+	}
+	switch size {
+	case Small:
+	}
+	switch r.(type) {
+	}
+	switch sz {
+	case Small:
+	}
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: importer.Default()}
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var swtchs []ast.Stmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt:
+			swtchs = append(swtchs, n.(ast.Stmt))
+		}
+		return true
+	})
+	find := func(*types.Interface) ([]types.Object, error) {
+		return PackageObjects(pkg), nil
+	}
+	opts := Options{
+		Exclude: regexp.MustCompile(`^Mock`),
+		Body: func(name string) []ast.Stmt {
+			return []ast.Stmt{&ast.ExprStmt{X: &ast.CallExpr{Fun: ast.NewIdent("println"), Args: []ast.Expr{ast.NewIdent(strings.TrimPrefix(name, "*"))}}}}
+		},
+	}
+
+	tests := [...]struct {
+		swtch ast.Stmt
+		want  string
+	}{
+		{swtch: swtchs[0], want: "case *Circle:\n\tprintln(Circle)\n"},
+		{swtch: swtchs[1], want: "case Large:\n\tprintln(Large)\ncase Medium:\n\tprintln(Medium)\n"},
+		{swtch: swtchs[2], want: ""},
+		{swtch: swtchs[3], want: "case Large:\n\tprintln(Large)\ncase Medium:\n\tprintln(Medium)\n"},
+	}
+	for i, test := range tests {
+		clauses, err := Clauses(pkg, info, test.swtch, find, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		for _, cc := range clauses {
+			if err := format.Node(&buf, token.NewFileSet(), cc); err != nil {
+				t.Fatal(err)
+			}
+			buf.WriteByte('\n')
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%d: got %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestCaseTypes(t *testing.T) {
+	const src = `package p
+
+type I interface{ m() }
+
+type V struct{}
+
+func (V) m() {}
+
+type M struct{}
+
+func (M) m()    {}
+func (*M) set() {}
+
+type P struct{}
+
+func (*P) m() {}
+
+type J interface{ I }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iface := pkg.Scope().Lookup("I").Type()
+
+	tests := [...]struct {
+		receivers string
+		want      string
+	}{
+		{receivers: ReceiversAuto, want: "V *M *P J"},
+		{receivers: ReceiversPtr, want: "*V *M *P J"},
+		{receivers: ReceiversValue, want: "V M *P J"},
+		{receivers: ReceiversBoth, want: "V *V M *M *P J"},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, name := range []string{"V", "M", "P", "J"} {
+			for _, typ := range caseTypes(pkg.Scope().Lookup(name).Type().(*types.Named), iface, test.receivers) {
+				got = append(got, TypeString(pkg, typ))
+			}
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%s: got %q, want %q", test.receivers, strings.Join(got, " "), test.want)
+		}
+	}
+}
+
+func TestIncluded(t *testing.T) {
+	mocks := types.NewPackage("example.com/mocks", "mocks")
+	shapes := types.NewPackage("example.com/shapes", "shapes")
+	objs := []types.Object{
+		types.NewTypeName(token.NoPos, mocks, "MockShape", nil),
+		types.NewTypeName(token.NoPos, shapes, "Square", nil),
+		types.NewTypeName(token.NoPos, shapes, "MockSquare", nil),
+		types.Universe.Lookup("error"),
+	}
+
+	tests := [...]struct {
+		include, exclude string
+		want             string
+	}{
+		{want: "MockShape Square MockSquare error"},
+		{include: `^example\.com/shapes\.`, want: "Square MockSquare"},
+		{exclude: `^example\.com/mocks\.`, want: "Square MockSquare error"},
+		{exclude: `^Mock`, want: "Square error"},
+		{include: `Square$`, exclude: `Mock`, want: "Square"},
+	}
+	for _, test := range tests {
+		var opts Options
+		if test.include != "" {
+			opts.Include = regexp.MustCompile(test.include)
+		}
+		if test.exclude != "" {
+			opts.Exclude = regexp.MustCompile(test.exclude)
+		}
+		var got []string
+		for _, obj := range objs {
+			if included(obj, opts) {
+				got = append(got, obj.Name())
+			}
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("include %q, exclude %q: got %q, want %q", test.include, test.exclude, strings.Join(got, " "), test.want)
+		}
+	}
+}
+
+func TestUnionTypes(t *testing.T) {
+	tests := [...]struct {
+		constraint string
+		want       []string
+	}{
+		{constraint: "any"},
+		{constraint: "interface{ String() string }"},
+		{constraint: "~int | string", want: []string{"int", "string"}},
+		{constraint: "interface{ ~int | string; int | ~string }", want: []string{"int", "string"}},
+		{constraint: "interface{ ~int | ~string; MyInt | bool }", want: []string{"p.MyInt"}},
+		{constraint: "interface{ num; String() string }", want: []string{"int", "float64"}},
+		{constraint: "num | MyInt", want: []string{"int", "float64", "p.MyInt"}},
+		{constraint: "interface{ int }", want: []string{"int"}},
+	}
+	for _, test := range tests {
+		src := "package p\n\ntype MyInt int\n\ntype num interface{ ~int | ~float64 }\n\nfunc f[T " + test.constraint + "]() {}\n"
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "union.go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", test.constraint, err)
+		}
+		sig := pkg.Scope().Lookup("f").Type().(*types.Signature)
+		var got []string
+		for _, typ := range unionTypes(sig.TypeParams().At(0)) {
+			got = append(got, typ.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.constraint, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fillswitch
+
+import (
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// Orders of the cases, see Options.Order.
+const (
+	OrderAlpha = "alpha" // alphabetical by the types or values qualified with their package paths
+	OrderDecl  = "decl"  // by declaration, grouped by package
+	OrderPkg   = "pkg"   // alphabetical, grouped by package
+)
+
+// caseKey is the position of the declaration of a case.
+type caseKey struct {
+	group  int    // 0 for predeclared types, 1 for the package of the switch, 2 for others
+	path   string // the import path of the package
+	name   string
+	file   string
+	offset int
+}
+
+// orderTypes sorts typs, the cases of a type switch in pkg, by order.
+// The types are expected to be sorted alphabetically, see TypeCases.
+func orderTypes(fset *token.FileSet, pkg *types.Package, typs []types.Type, order string) {
+	if order != OrderDecl && order != OrderPkg {
+		return
+	}
+	keys := make(map[types.Type]caseKey, len(typs))
+	for _, t := range typs {
+		if n, ok := deref(t).(*types.Named); ok {
+			keys[t] = newCaseKey(fset, pkg, n.Obj())
+		}
+	}
+	sort.SliceStable(typs, func(i, j int) bool {
+		return keys[typs[i]].less(keys[typs[j]], order)
+	})
+}
+
+// orderObjects sorts objs, the cases of a switch in pkg, by order.
+// The objects are expected to be sorted alphabetically, see
+// ValueCases.
+func orderObjects(fset *token.FileSet, pkg *types.Package, objs []types.Object, order string) {
+	if order != OrderDecl && order != OrderPkg {
+		return
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		return newCaseKey(fset, pkg, objs[i]).less(newCaseKey(fset, pkg, objs[j]), order)
+	})
+}
+
+func newCaseKey(fset *token.FileSet, pkg *types.Package, obj types.Object) caseKey {
+	if obj.Pkg() == nil {
+		return caseKey{group: 0, name: obj.Name()}
+	}
+	k := caseKey{group: 2, path: obj.Pkg().Path(), name: obj.Name(), offset: int(obj.Pos())}
+	if fset != nil {
+		pos := fset.Position(obj.Pos())
+		k.file, k.offset = pos.Filename, pos.Offset
+	}
+	if obj.Pkg() == pkg {
+		k.group = 1
+	}
+	return k
+}
+
+// less reports whether the case declared at k comes before the one
+// declared at l in the given order, OrderDecl or OrderPkg.
+func (k caseKey) less(l caseKey, order string) bool {
+	switch {
+	case k.group != l.group:
+		return k.group < l.group
+	case k.path != l.path:
+		return k.path < l.path
+	case order == OrderPkg:
+		return k.name < l.name
+	case k.file != l.file:
+		return k.file < l.file
+	default:
+		return k.offset < l.offset
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fillswitch
+
+import "go/types"
+
+// Choices between a type and its pointer type for the cases of a type
+// switch, see Options.Receivers. They only matter if both implement the
+// switched interface, i.e. if the methods of the interface have value
+// receivers; otherwise the case is the one which implements it.
+const (
+	ReceiversAuto  = "auto"  // *T if T has methods with pointer receivers, T otherwise
+	ReceiversPtr   = "ptr"   // *T
+	ReceiversValue = "value" // T
+	ReceiversBoth  = "both"  // T and *T
+)
+
+// caseTypes returns the types of the cases for t in a type switch on a
+// value of type iface: t, its pointer type, both or none.
+func caseTypes(t *types.Named, iface types.Type, receivers string) []types.Type {
+	p := types.NewPointer(t)
+	val, ptr := types.AssignableTo(t, iface), types.AssignableTo(p, iface)
+	switch {
+	case !val && !ptr:
+		return nil
+	case !ptr:
+		// e.g. an interface type
+		return []types.Type{t}
+	case !val:
+		return []types.Type{p}
+	}
+
+	switch receivers {
+	case ReceiversPtr:
+		return []types.Type{p}
+	case ReceiversBoth:
+		return []types.Type{t, p}
+	case ReceiversAuto:
+		// A type with methods with pointer receivers
+		// is usually used through pointers.
+		if hasPointerReceivers(t) {
+			return []types.Type{p}
+		}
+	}
+	return []types.Type{t}
+}
+
+// hasPointerReceivers reports whether t declares a method with a pointer
+// receiver.
+func hasPointerReceivers(t *types.Named) bool {
+	for i := 0; i < t.NumMethods(); i++ {
+		recv := t.Method(i).Type().(*types.Signature).Recv()
+		if _, ok := recv.Type().(*types.Pointer); ok {
+			return true
+		}
+	}
+	return false
+}
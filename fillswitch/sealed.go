@@ -0,0 +1,57 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fillswitch
+
+import "go/types"
+
+// sealedPackage returns the package declaring an unexported method of
+// iface, or nil if all of its methods are exported. Such an interface
+// is sealed: only the types of that package can implement it, apart
+// from the types of other packages embedding one of them.
+func sealedPackage(iface *types.Interface) *types.Package {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if m := iface.Method(i); !m.Exported() {
+			return m.Pkg()
+		}
+	}
+	return nil
+}
+
+// sealedTypes returns the types of the cases of a type switch in pkg
+// on a value of the interface iface sealed by the package sealed. The
+// types are the ones declared in sealed, regardless of the scope of
+// the search, such that the cases are exhaustive.
+func sealedTypes(pkg, sealed *types.Package, iface *types.Interface, receivers string) []types.Type {
+	var typs []types.Type
+	scope := sealed.Scope()
+	for _, name := range scope.Names() {
+		typs = append(typs, candidateTypes(pkg, scope.Lookup(name), iface, receivers)...)
+	}
+	return uniqTypes(typs)
+}
+
+// sealedHidden reports whether a type of the package sealed implementing
+// iface cannot be named in pkg, i.e. it is unexported and pkg is another
+// package, such that the cases of sealedTypes are not exhaustive.
+func sealedHidden(pkg, sealed *types.Package, iface *types.Interface) bool {
+	scope := sealed.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || tn.IsAlias() || Visible(pkg, tn) {
+			continue
+		}
+		t, ok := tn.Type().(*types.Named)
+		if !ok || t.TypeParams().Len() > 0 {
+			continue
+		}
+		if i, ok := t.Underlying().(*types.Interface); ok && (iface == i || i.NumMethods() == 0) {
+			continue
+		}
+		if caseTypes(t, iface, ReceiversBoth) != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2026 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fillswitch
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// term is a term of a union, e.g. ~int or MyType.
+type term struct {
+	tilde bool
+	typ   types.Type
+}
+
+// convertedTypeParam returns the type parameter of the value converted to
+// an interface in the type switch n, e.g. T in switch any(v).(type) where
+// v has type T, which is the usual way to switch on the type of a value of
+// a type parameter. It returns nil if n does not switch on such a value.
+func convertedTypeParam(info *types.Info, n *ast.TypeSwitchStmt) *types.TypeParam {
+	call, ok := ast.Unparen(assertedExpr(n)).(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || !info.Types[call.Fun].IsType() {
+		return nil
+	}
+	tp, _ := info.TypeOf(call.Args[0]).(*types.TypeParam)
+	return tp
+}
+
+// unionTypes returns the types of the terms of the union in the interface
+// underlying t, e.g. the constraint of a type parameter, e.g. int, string
+// and MyType for ~int | ~string | MyType, in the order of the union. A
+// term ~T yields T. Embedded unions are intersected. It returns nil if the
+// interface has no union.
+func unionTypes(t types.Type) []types.Type {
+	iface, ok := t.Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	terms, ok := interfaceTerms(iface)
+	if !ok {
+		return nil
+	}
+	typs := make([]types.Type, 0, len(terms))
+	for _, t := range terms {
+		typs = append(typs, t.typ)
+	}
+	return typs
+}
+
+// interfaceTerms returns the terms of the type set of iface. It reports
+// false if the type set is not restricted by a union, e.g. for any.
+func interfaceTerms(iface *types.Interface) ([]term, bool) {
+	var terms []term
+	restricted := false
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		var embedded []term
+		switch e := iface.EmbeddedType(i).(type) {
+		case *types.Union:
+			for j := 0; j < e.Len(); j++ {
+				t := e.Term(j)
+				if tiface, ok := t.Type().Underlying().(*types.Interface); ok {
+					// A term can be an interface with a union.
+					tterms, ok := interfaceTerms(tiface)
+					if !ok {
+						return nil, false
+					}
+					embedded = append(embedded, tterms...)
+					continue
+				}
+				embedded = append(embedded, term{tilde: t.Tilde(), typ: t.Type()})
+			}
+		default:
+			eiface, ok := e.Underlying().(*types.Interface)
+			if !ok {
+				// A single type, e.g. interface{ int }.
+				embedded = []term{{typ: e}}
+				break
+			}
+			eterms, ok := interfaceTerms(eiface)
+			if !ok {
+				continue // e.g. an embedded fmt.Stringer
+			}
+			embedded = eterms
+		}
+		if restricted {
+			terms = intersect(terms, embedded)
+		} else {
+			terms, restricted = embedded, true
+		}
+	}
+	return terms, restricted
+}
+
+// intersect returns the terms of a which are also in b, e.g. int for
+// ~int and int. The terms are in the order of a.
+func intersect(a, b []term) []term {
+	var res []term
+	for _, x := range a {
+		for _, y := range b {
+			if t, ok := meet(x, y); ok {
+				res = append(res, t)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// meet returns the term whose types are in both x and y, if any.
+func meet(x, y term) (term, bool) {
+	switch {
+	case types.Identical(x.typ, y.typ):
+		return term{tilde: x.tilde && y.tilde, typ: x.typ}, true
+	case x.tilde && types.Identical(x.typ, y.typ.Underlying()):
+		return y, true
+	case y.tilde && types.Identical(y.typ, x.typ.Underlying()):
+		return x, true
+	}
+	return term{}, false
+}
@@ -34,21 +34,79 @@
 
 // This file implements printing of types.
 
-package main
+package fillswitch
 
 import (
 	"bytes"
 	"fmt"
+	"go/ast"
 	"go/types"
 )
 
-func typeString(pkg *types.Package, typ types.Type) string {
+// TypeString returns the string representation of typ as written in pkg,
+// i.e. with the types of other packages qualified with the package names.
+func TypeString(pkg *types.Package, typ types.Type) string {
+	return QualifiedTypeString(typ, packageQualifier(pkg))
+}
+
+// QualifiedTypeString returns the string representation of typ with the
+// names of packages given by qf, e.g. a FileQualifier.
+func QualifiedTypeString(typ types.Type, qf types.Qualifier) string {
 	var buf bytes.Buffer
-	writeType(&buf, pkg, typ, make([]types.Type, 0, 8))
+	writeType(&buf, qf, typ, make([]types.Type, 0, 8))
 	return buf.String()
 }
 
-func writeType(buf *bytes.Buffer, pkg *types.Package, typ types.Type, visited []types.Type) {
+// packageQualifier qualifies the packages other than pkg with their names.
+func packageQualifier(pkg *types.Package) types.Qualifier {
+	return func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		return p.Name()
+	}
+}
+
+// FileQualifier returns a qualifier for the code in the file f of pkg
+// with the type information info: the packages imported by f are
+// qualified with the names under which they are imported, e.g. the
+// renaming of the import, or not at all for dot imports. The other
+// packages are qualified with their names.
+func FileQualifier(f *ast.File, pkg *types.Package, info *types.Info) types.Qualifier {
+	names := make(map[string]string) // by import path
+	for _, imp := range f.Imports {
+		obj := info.Implicits[imp]
+		if imp.Name != nil {
+			obj = info.Defs[imp.Name]
+		}
+		pkgName, ok := obj.(*types.PkgName)
+		if !ok {
+			continue
+		}
+		path := pkgName.Imported().Path()
+		if _, ok := names[path]; ok {
+			continue
+		}
+		switch name := pkgName.Name(); name {
+		case "_":
+		case ".":
+			names[path] = ""
+		default:
+			names[path] = name
+		}
+	}
+	return func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+		if name, ok := names[p.Path()]; ok {
+			return name
+		}
+		return p.Name()
+	}
+}
+
+func writeType(buf *bytes.Buffer, qf types.Qualifier, typ types.Type, visited []types.Type) {
 	// Theoretically, this is a quadratic lookup algorithm, but in
 	// practice deeply nested composite types with unnamed component
 	// types are uncommon. This code is likely more efficient than
@@ -73,11 +131,11 @@ func writeType(buf *bytes.Buffer, pkg *types.Package, typ types.Type, visited []
 
 	case *types.Array:
 		fmt.Fprintf(buf, "[%d]", t.Len())
-		writeType(buf, pkg, t.Elem(), visited)
+		writeType(buf, qf, t.Elem(), visited)
 
 	case *types.Slice:
 		buf.WriteString("[]")
-		writeType(buf, pkg, t.Elem(), visited)
+		writeType(buf, qf, t.Elem(), visited)
 
 	case *types.Struct:
 		buf.WriteString("struct{")
@@ -90,7 +148,7 @@ func writeType(buf *bytes.Buffer, pkg *types.Package, typ types.Type, visited []
 				buf.WriteString(f.Name())
 				buf.WriteByte(' ')
 			}
-			writeType(buf, pkg, f.Type(), visited)
+			writeType(buf, qf, f.Type(), visited)
 			if tag := t.Tag(i); tag != "" {
 				fmt.Fprintf(buf, " %q", tag)
 			}
@@ -99,14 +157,14 @@ func writeType(buf *bytes.Buffer, pkg *types.Package, typ types.Type, visited []
 
 	case *types.Pointer:
 		buf.WriteByte('*')
-		writeType(buf, pkg, t.Elem(), visited)
+		writeType(buf, qf, t.Elem(), visited)
 
 	case *types.Tuple:
-		writeTuple(buf, pkg, t, false, visited)
+		writeTuple(buf, qf, t, false, visited)
 
 	case *types.Signature:
 		buf.WriteString("func")
-		writeSignature(buf, pkg, t, visited)
+		writeSignature(buf, qf, t, visited)
 
 	case *types.Interface:
 		// We write the source-level methods and embedded types rather
@@ -128,21 +186,21 @@ func writeType(buf *bytes.Buffer, pkg *types.Package, typ types.Type, visited []
 				buf.WriteString("; ")
 			}
 			buf.WriteString(m.Name())
-			writeSignature(buf, pkg, m.Type().(*types.Signature), visited)
+			writeSignature(buf, qf, m.Type().(*types.Signature), visited)
 		}
 		for i := 0; i < t.NumEmbeddeds(); i++ {
 			if i > 0 || t.NumMethods() > 0 {
 				buf.WriteString("; ")
 			}
-			writeType(buf, pkg, t.EmbeddedType(i), visited)
+			writeType(buf, qf, t.EmbeddedType(i), visited)
 		}
 		buf.WriteByte('}')
 
 	case *types.Map:
 		buf.WriteString("map[")
-		writeType(buf, pkg, t.Key(), visited)
+		writeType(buf, qf, t.Key(), visited)
 		buf.WriteByte(']')
-		writeType(buf, pkg, t.Elem(), visited)
+		writeType(buf, qf, t.Elem(), visited)
 
 	case *types.Chan:
 		var s string
@@ -165,17 +223,18 @@ func writeType(buf *bytes.Buffer, pkg *types.Package, typ types.Type, visited []
 		if parens {
 			buf.WriteByte('(')
 		}
-		writeType(buf, pkg, t.Elem(), visited)
+		writeType(buf, qf, t.Elem(), visited)
 		if parens {
 			buf.WriteByte(')')
 		}
 
 	case *types.Named:
-		if pkg != t.Obj().Pkg() && t.Obj().Pkg() != nil {
-			buf.WriteString(fmt.Sprintf("%s.%s", t.Obj().Pkg().Name(), t.Obj().Name()))
-		} else {
-			buf.WriteString(t.Obj().Name())
+		if pkg := t.Obj().Pkg(); pkg != nil {
+			if name := qf(pkg); name != "" {
+				buf.WriteString(name + ".")
+			}
 		}
+		buf.WriteString(t.Obj().Name())
 
 	default:
 		// For externally defined implementations of Type.
@@ -183,7 +242,7 @@ func writeType(buf *bytes.Buffer, pkg *types.Package, typ types.Type, visited []
 	}
 }
 
-func writeTuple(buf *bytes.Buffer, pkg *types.Package, tup *types.Tuple, variadic bool, visited []types.Type) {
+func writeTuple(buf *bytes.Buffer, qf types.Qualifier, tup *types.Tuple, variadic bool, visited []types.Type) {
 	buf.WriteByte('(')
 	if tup != nil {
 		for i := 0; i < tup.Len(); i++ {
@@ -206,19 +265,19 @@ func writeTuple(buf *bytes.Buffer, pkg *types.Package, tup *types.Tuple, variadi
 					if t, ok := typ.Underlying().(*types.Basic); !ok || t.Kind() != types.String {
 						panic("internal error: string type expected")
 					}
-					writeType(buf, pkg, typ, visited)
+					writeType(buf, qf, typ, visited)
 					buf.WriteString("...")
 					continue
 				}
 			}
-			writeType(buf, pkg, typ, visited)
+			writeType(buf, qf, typ, visited)
 		}
 	}
 	buf.WriteByte(')')
 }
 
-func writeSignature(buf *bytes.Buffer, pkg *types.Package, sig *types.Signature, visited []types.Type) {
-	writeTuple(buf, pkg, sig.Params(), sig.Variadic(), visited)
+func writeSignature(buf *bytes.Buffer, qf types.Qualifier, sig *types.Signature, visited []types.Type) {
+	writeTuple(buf, qf, sig.Params(), sig.Variadic(), visited)
 
 	n := sig.Results().Len()
 	if n == 0 {
@@ -228,10 +287,10 @@ func writeSignature(buf *bytes.Buffer, pkg *types.Package, sig *types.Signature,
 	buf.WriteByte(' ')
 	if n == 1 && sig.Results().At(0).Name() == "" {
 		// single unnamed result
-		writeType(buf, pkg, sig.Results().At(0).Type(), visited)
+		writeType(buf, qf, sig.Results().At(0).Type(), visited)
 		return
 	}
 
 	// multiple or named result(s)
-	writeTuple(buf, pkg, sig.Results(), false, visited)
+	writeTuple(buf, qf, sig.Results(), false, visited)
 }
@@ -2,9 +2,15 @@ module github.com/davidrjenni/reftools
 
 require (
 	github.com/kisielk/gotool v1.0.0
-	golang.org/x/tools v0.17.0
+	golang.org/x/tools v0.24.0
+	gopkg.in/yaml.v3 v3.0.1
+	mvdan.cc/gofumpt v0.1.0
 )
 
-require golang.org/x/mod v0.15.0 // indirect
+require (
+	github.com/google/go-cmp v0.6.0 // indirect
+	golang.org/x/mod v0.20.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+)
 
-go 1.21
+go 1.23